@@ -19,14 +19,16 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ExchangeService_RegisterService_FullMethodName = "/api.ExchangeService/RegisterService"
+	ExchangeService_Control_FullMethodName          = "/api.ExchangeService/Control"
+	ExchangeService_RejectConnection_FullMethodName = "/api.ExchangeService/RejectConnection"
 )
 
 // ExchangeServiceClient is the client API for ExchangeService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ExchangeServiceClient interface {
-	RegisterService(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ConnectCommand], error)
+	Control(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ControlMessage, ExchangeMessage], error)
+	RejectConnection(ctx context.Context, in *RejectRequest, opts ...grpc.CallOption) (*RejectResponse, error)
 }
 
 type exchangeServiceClient struct {
@@ -37,30 +39,35 @@ func NewExchangeServiceClient(cc grpc.ClientConnInterface) ExchangeServiceClient
 	return &exchangeServiceClient{cc}
 }
 
-func (c *exchangeServiceClient) RegisterService(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ConnectCommand], error) {
+func (c *exchangeServiceClient) Control(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ControlMessage, ExchangeMessage], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &ExchangeService_ServiceDesc.Streams[0], ExchangeService_RegisterService_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &ExchangeService_ServiceDesc.Streams[0], ExchangeService_Control_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &grpc.GenericClientStream[RegisterRequest, ConnectCommand]{ClientStream: stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
-		return nil, err
-	}
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
-	}
+	x := &grpc.GenericClientStream[ControlMessage, ExchangeMessage]{ClientStream: stream}
 	return x, nil
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
-type ExchangeService_RegisterServiceClient = grpc.ServerStreamingClient[ConnectCommand]
+type ExchangeService_ControlClient = grpc.BidiStreamingClient[ControlMessage, ExchangeMessage]
+
+func (c *exchangeServiceClient) RejectConnection(ctx context.Context, in *RejectRequest, opts ...grpc.CallOption) (*RejectResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RejectResponse)
+	err := c.cc.Invoke(ctx, ExchangeService_RejectConnection_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
 // ExchangeServiceServer is the server API for ExchangeService service.
 // All implementations must embed UnimplementedExchangeServiceServer
 // for forward compatibility.
 type ExchangeServiceServer interface {
-	RegisterService(*RegisterRequest, grpc.ServerStreamingServer[ConnectCommand]) error
+	Control(grpc.BidiStreamingServer[ControlMessage, ExchangeMessage]) error
+	RejectConnection(context.Context, *RejectRequest) (*RejectResponse, error)
 	mustEmbedUnimplementedExchangeServiceServer()
 }
 
@@ -71,8 +78,11 @@ type ExchangeServiceServer interface {
 // pointer dereference when methods are called.
 type UnimplementedExchangeServiceServer struct{}
 
-func (UnimplementedExchangeServiceServer) RegisterService(*RegisterRequest, grpc.ServerStreamingServer[ConnectCommand]) error {
-	return status.Errorf(codes.Unimplemented, "method RegisterService not implemented")
+func (UnimplementedExchangeServiceServer) Control(grpc.BidiStreamingServer[ControlMessage, ExchangeMessage]) error {
+	return status.Errorf(codes.Unimplemented, "method Control not implemented")
+}
+func (UnimplementedExchangeServiceServer) RejectConnection(context.Context, *RejectRequest) (*RejectResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RejectConnection not implemented")
 }
 func (UnimplementedExchangeServiceServer) mustEmbedUnimplementedExchangeServiceServer() {}
 func (UnimplementedExchangeServiceServer) testEmbeddedByValue()                         {}
@@ -95,16 +105,30 @@ func RegisterExchangeServiceServer(s grpc.ServiceRegistrar, srv ExchangeServiceS
 	s.RegisterService(&ExchangeService_ServiceDesc, srv)
 }
 
-func _ExchangeService_RegisterService_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(RegisterRequest)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
-	}
-	return srv.(ExchangeServiceServer).RegisterService(m, &grpc.GenericServerStream[RegisterRequest, ConnectCommand]{ServerStream: stream})
+func _ExchangeService_Control_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ExchangeServiceServer).Control(&grpc.GenericServerStream[ControlMessage, ExchangeMessage]{ServerStream: stream})
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
-type ExchangeService_RegisterServiceServer = grpc.ServerStreamingServer[ConnectCommand]
+type ExchangeService_ControlServer = grpc.BidiStreamingServer[ControlMessage, ExchangeMessage]
+
+func _ExchangeService_RejectConnection_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RejectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExchangeServiceServer).RejectConnection(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ExchangeService_RejectConnection_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExchangeServiceServer).RejectConnection(ctx, req.(*RejectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
 
 // ExchangeService_ServiceDesc is the grpc.ServiceDesc for ExchangeService service.
 // It's only intended for direct use with grpc.RegisterService,
@@ -112,12 +136,18 @@ type ExchangeService_RegisterServiceServer = grpc.ServerStreamingServer[ConnectC
 var ExchangeService_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "api.ExchangeService",
 	HandlerType: (*ExchangeServiceServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RejectConnection",
+			Handler:    _ExchangeService_RejectConnection_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
-			StreamName:    "RegisterService",
-			Handler:       _ExchangeService_RegisterService_Handler,
+			StreamName:    "Control",
+			Handler:       _ExchangeService_Control_Handler,
 			ServerStreams: true,
+			ClientStreams: true,
 		},
 	},
 	Metadata: "exchange.proto",
@@ -0,0 +1,173 @@
+package peer
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+type Client struct {
+	dialer    net.Dialer
+	tlsConfig *tls.Config
+	addr      string
+	token     string
+}
+
+type ClientOption func(*Client)
+
+// WithClientToken sets the cluster token that is used to authenticate the client on the peer.
+func WithClientToken(token string) ClientOption {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithClientTLS enables TLS for connections to the peer with the given configuration.
+// If the configuration is nil, the client connects over plain TCP.
+func WithClientTLS(cfg *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig = cfg
+	}
+}
+
+// NewClient creates the client of the peer listening on the given address.
+func NewClient(addr string, opts ...ClientOption) *Client {
+	c := &Client{
+		addr:   addr,
+		dialer: net.Dialer{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Addr returns the address of the peer.
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+// Members returns the node id of the peer and the services registered on it.
+func (c *Client) Members(ctx context.Context) (*Members, error) {
+	members := &Members{}
+
+	conn, err := c.do(ctx, &Request{Op: OpMembers}, members)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = conn.Close()
+
+	return members, nil
+}
+
+// Dial requests the peer to connect to the service in the name space on the given port on behalf of the caller,
+// the caller is checked against the access policy and the limits of the peer.
+// The returned connection is the byte stream to the service.
+func (c *Client) Dial(ctx context.Context, nameSpace, service string, port uint16, caller *Caller) (net.Conn, error) {
+	return c.do(ctx, &Request{Op: OpDial, NameSpace: nameSpace, Service: service, Port: port, Caller: caller}, nil)
+}
+
+// Deliver delivers the reverse connection for the connection request with the given id issued by the peer.
+// The name space is the one the reverse connection is authenticated for.
+// The returned connection is the byte stream to the requester.
+func (c *Client) Deliver(ctx context.Context, nameSpace string, id uint64) (net.Conn, error) {
+	return c.do(ctx, &Request{Op: OpDeliver, NameSpace: nameSpace, ID: id}, nil)
+}
+
+// Reject rejects the connection request with the given id issued by the peer for the reason.
+func (c *Client) Reject(ctx context.Context, nameSpace string, id uint64, reason Status, message string) error {
+	conn, err := c.do(ctx, &Request{Op: OpReject, NameSpace: nameSpace, ID: id, Status: reason, Message: message}, nil)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// do sends the request to the peer and reads the response into v.
+// The context limits the whole exchange of the request and the response, its error is kept in the returned error.
+// It returns the connection that can be used as a byte stream after the successful response.
+func (c *Client) do(ctx context.Context, req *Request, v any) (net.Conn, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to peer: %w", err)
+	}
+
+	if err := c.request(ctx, conn, req, v); err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+
+		if errC := conn.Close(); errC != nil {
+			err = errors.Join(err, errC)
+		}
+
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	if c.tlsConfig == nil {
+		return c.dialer.DialContext(ctx, "tcp", c.addr)
+	}
+
+	tlsDialer := &tls.Dialer{
+		NetDialer: &c.dialer,
+		Config:    c.tlsConfig,
+	}
+
+	return tlsDialer.DialContext(ctx, "tcp", c.addr)
+}
+
+// request signs the nonce sent by the peer together with the request, sends the request and reads the response.
+func (c *Client) request(ctx context.Context, conn net.Conn, req *Request, v any) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+
+	defer stop()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("failed to set deadline: %w", err)
+		}
+	}
+
+	nonce := make([]byte, nonceLength)
+
+	if _, err := io.ReadFull(conn, nonce); err != nil {
+		return fmt.Errorf("failed to read nonce: %w", err)
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	header := []byte{V1, byte(req.Op)}
+	header = append(header, sign(c.token, nonce, header, payload)...)
+
+	if err := writePayload(conn, header, payload); err != nil {
+		return fmt.Errorf("failed to write request: %w", err)
+	}
+
+	if err := readResponse(conn, v); err != nil {
+		return err
+	}
+
+	if !stop() {
+		return ctx.Err()
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
@@ -0,0 +1,202 @@
+package peer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// V1 is the version of the peer protocol.
+const V1 byte = 1
+
+// Op is the operation requested by the peer.
+type Op byte
+
+// OpMembers requests the services registered on the node.
+// OpDial requests the connection to the service, the connection continues as a byte stream if it succeeds.
+// OpDeliver delivers the reverse connection for the connection request issued by the node,
+// the connection continues as a byte stream.
+// OpReject rejects the connection request issued by the node.
+const (
+	OpMembers Op = 1
+	OpDial    Op = 2
+	OpDeliver Op = 3
+	OpReject  Op = 4
+)
+
+// Status is the result of the request, it's also used as the reason of rejected connection requests.
+type Status byte
+
+const (
+	StatusOK Status = iota
+	StatusFailed
+	StatusUnauthorized
+	StatusBadRequest
+	StatusRevProxyNotFound
+	StatusServiceNotFound
+	StatusRequestNotFound
+	StatusNameSpaceMismatch
+	StatusRequestExpired
+	StatusRejected
+	StatusConnRefused
+	StatusHostUnreachable
+	StatusNetUnreachable
+	StatusTimeout
+	StatusInvalidAddress
+	StatusAccessDenied
+	StatusRateLimited
+	StatusQuotaExceeded
+)
+
+const (
+	nonceLength      = 32
+	macLength        = sha256.Size
+	maxPayloadLength = 1 << 20
+)
+
+// Request is the request of the peer.
+// Dial requests are described by NameSpace, Service and Port, and carry the Caller the peer has accepted them from,
+// Deliver and Reject requests by NameSpace and ID, rejects also carry the reason in Status and Message.
+type Request struct {
+	Caller    *Caller `json:"caller,omitempty"`
+	NameSpace string  `json:"namespace,omitempty"`
+	Service   string  `json:"service,omitempty"`
+	Message   string  `json:"message,omitempty"`
+	ID        uint64  `json:"id,omitempty"`
+	Port      uint16  `json:"port,omitempty"`
+	Status    Status  `json:"status,omitempty"`
+	Op        Op      `json:"-"`
+}
+
+// Caller is the identity of the proxy client that has requested the connection from the peer.
+type Caller struct {
+	Source      string `json:"source,omitempty"`
+	User        string `json:"user,omitempty"`
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// Members describes the node and the services registered on it, keyed by name space.
+type Members struct {
+	Services map[string][]string `json:"services"`
+	Node     uint16              `json:"node"`
+}
+
+// Error is the error status replied by the peer.
+type Error struct {
+	Message string
+	Status  Status
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("peer replied with status %d: %s", e.Status, e.Message)
+}
+
+// newNonce generates the random challenge that the client signs to authenticate.
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, nonceLength)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return nonce, nil
+}
+
+// sign calculates HMAC-SHA256 of the nonce, the request header and the payload with the cluster token as a key,
+// so the request can't be altered without the token.
+func sign(token string, nonce, header, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(nonce)
+	mac.Write(header)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+// writePayload writes the header followed by the length prefixed payload.
+func writePayload(w io.Writer, header, payload []byte) error {
+	buf := make([]byte, 0, len(header)+4+len(payload))
+	buf = append(buf, header...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = append(buf, payload...)
+
+	_, err := w.Write(buf)
+
+	return err
+}
+
+// readPayload reads the length prefixed payload.
+func readPayload(r io.Reader) ([]byte, error) {
+	buf := make([]byte, 4)
+
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read payload length: %w", err)
+	}
+
+	n := binary.BigEndian.Uint32(buf)
+	if n > maxPayloadLength {
+		return nil, fmt.Errorf("payload is too large: %d bytes", n)
+	}
+
+	payload := make([]byte, n)
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("failed to read payload: %w", err)
+	}
+
+	return payload, nil
+}
+
+// writeResponse writes the status of the request with the body, that is JSON encoded for successful requests
+// and the error message otherwise.
+func writeResponse(w io.Writer, status Status, body any) error {
+	var (
+		payload []byte
+		err     error
+	)
+
+	switch b := body.(type) {
+	case nil:
+	case string:
+		payload = []byte(b)
+	default:
+		if payload, err = json.Marshal(b); err != nil {
+			return fmt.Errorf("failed to encode response: %w", err)
+		}
+	}
+
+	return writePayload(w, []byte{byte(status)}, payload)
+}
+
+// readResponse reads the status of the request and decodes the body into v if the request succeeded.
+// The error status is returned as *Error.
+func readResponse(r io.Reader, v any) error {
+	buf := make([]byte, 1)
+
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fmt.Errorf("failed to read response status: %w", err)
+	}
+
+	payload, err := readPayload(r)
+	if err != nil {
+		return err
+	}
+
+	if status := Status(buf[0]); status != StatusOK {
+		return &Error{Status: status, Message: string(payload)}
+	}
+
+	if v == nil || len(payload) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
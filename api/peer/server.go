@@ -0,0 +1,166 @@
+package peer
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const handshakeTimeout = 10 * time.Second
+
+// Handler handles the authenticated request of the peer.
+// The handler owns the connection, it must reply to the request with Reply or Fail and close the connection
+// when it's done with it.
+type Handler func(req *Request, conn *Conn)
+
+// Conn is the connection of the peer request.
+type Conn struct {
+	net.Conn
+}
+
+// Reply replies to the request with the success status and the body, that is encoded to JSON.
+// After the reply, the connection can be used as a byte stream.
+func (c *Conn) Reply(body any) error {
+	return writeResponse(c.Conn, StatusOK, body)
+}
+
+// Fail replies to the request with the error status and the message.
+func (c *Conn) Fail(status Status, message string) error {
+	return writeResponse(c.Conn, status, message)
+}
+
+// CloseWrite closes the sending direction of the connection, if it supports half-close.
+func (c *Conn) CloseWrite() error {
+	cw, ok := c.Conn.(interface{ CloseWrite() error })
+	if !ok {
+		return fmt.Errorf("half-close is not supported")
+	}
+
+	return cw.CloseWrite()
+}
+
+type Server struct {
+	handler Handler
+	token   string
+}
+
+type ServerOption func(*Server)
+
+// WithServerToken sets the cluster token that peers have to prove knowledge of.
+// Without the token all requests are refused.
+func WithServerToken(token string) ServerOption {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// NewServer creates the server of peer requests.
+func NewServer(handler Handler, opts ...ServerOption) *Server {
+	s := &Server{
+		handler: handler,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Serve accepts connections on the listener until it's closed.
+func (s *Server) Serve(lis net.Listener) error {
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.EPIPE) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	req, err := s.readRequest(conn)
+	if err != nil {
+		slog.Error("failed to read peer request", slog.Any("error", err))
+
+		var perr *Error
+		if errors.As(err, &perr) {
+			_ = writeResponse(conn, perr.Status, perr.Message)
+		}
+
+		_ = conn.Close()
+
+		return
+	}
+
+	s.handler(req, &Conn{Conn: conn})
+}
+
+// readRequest sends the nonce to the peer, reads the request and verifies that it's signed with the cluster token.
+// The handshake must be completed within the handshake timeout.
+func (s *Server) readRequest(conn net.Conn) (*Request, error) {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return nil, fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(nonce); err != nil {
+		return nil, fmt.Errorf("failed to write nonce: %w", err)
+	}
+
+	header := make([]byte, 2+macLength)
+
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, fmt.Errorf("failed to read request header: %w", err)
+	}
+
+	if header[0] != V1 {
+		return nil, &Error{Status: StatusBadRequest, Message: "unsupported protocol version"}
+	}
+
+	payload, err := readPayload(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.token == "" || !hmac.Equal(header[2:], sign(s.token, nonce, header[:2], payload)) {
+		return nil, &Error{Status: StatusUnauthorized, Message: "invalid cluster token"}
+	}
+
+	req := &Request{Op: Op(header[1])}
+
+	if err := json.Unmarshal(payload, req); err != nil {
+		return nil, &Error{Status: StatusBadRequest, Message: "invalid request"}
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("failed to reset deadline: %w", err)
+	}
+
+	return req, nil
+}
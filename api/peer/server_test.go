@@ -0,0 +1,144 @@
+package peer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer serves the peer requests on the loopback interface and returns its address.
+// The members requests are replied with the node id, other requests are replied with the request itself.
+func startServer(t *testing.T, token string) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := NewServer(func(req *Request, conn *Conn) {
+		defer conn.Close()
+
+		if req.Op == OpMembers {
+			_ = conn.Reply(&Members{Node: 1})
+			return
+		}
+
+		_ = conn.Reply(req)
+	}, WithServerToken(token))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_ = srv.Serve(lis)
+	}()
+
+	t.Cleanup(func() {
+		_ = lis.Close()
+		<-done
+	})
+
+	return lis.Addr().String()
+}
+
+func TestServer_Authentication(t *testing.T) {
+	tests := []struct {
+		name        string
+		serverToken string
+		clientToken string
+		wantErr     bool
+	}{
+		{name: "valid token", serverToken: "token", clientToken: "token"},
+		{name: "wrong token", serverToken: "token", clientToken: "wrong", wantErr: true},
+		{name: "empty token", serverToken: "token", clientToken: "", wantErr: true},
+		{name: "server without token", serverToken: "", clientToken: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(startServer(t, tt.serverToken), WithClientToken(tt.clientToken))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			members, err := client.Members(ctx)
+
+			if tt.wantErr {
+				var perr *Error
+
+				require.ErrorAs(t, err, &perr)
+				assert.Equal(t, StatusUnauthorized, perr.Status)
+				assert.Nil(t, members)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, uint16(1), members.Node)
+		})
+	}
+}
+
+func TestServer_AlteredRequest(t *testing.T) {
+	addr := startServer(t, "token")
+
+	signed, err := json.Marshal(&Request{NameSpace: "example"})
+	require.NoError(t, err)
+
+	altered, err := json.Marshal(&Request{NameSpace: "private"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		op      Op
+		wantErr bool
+	}{
+		{name: "signed request", op: OpDial, payload: signed},
+		{name: "altered payload", op: OpDial, payload: altered, wantErr: true},
+		{name: "altered operation", op: OpDeliver, payload: signed, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			require.NoError(t, err)
+
+			defer conn.Close()
+
+			require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+			nonce := make([]byte, nonceLength)
+			_, err = io.ReadFull(conn, nonce)
+			require.NoError(t, err)
+
+			// The signature is calculated for the dial request of the example name space.
+			header := []byte{V1, byte(OpDial)}
+			mac := sign("token", nonce, header, signed)
+
+			require.NoError(t, writePayload(conn, append([]byte{V1, byte(tt.op)}, mac...), tt.payload))
+
+			req := &Request{}
+
+			err = readResponse(conn, req)
+
+			if tt.wantErr {
+				var perr *Error
+
+				require.ErrorAs(t, err, &perr)
+				assert.Equal(t, StatusUnauthorized, perr.Status)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "example", req.NameSpace)
+		})
+	}
+}
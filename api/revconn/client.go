@@ -1,33 +1,162 @@
 package revconn
 
 import (
-	"encoding/binary"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"golang.org/x/net/context"
 )
 
+// errVersionRejected means that the server has closed the connection in response to the hello message,
+// that's how the servers that support only V1 and VMux reject the newer versions.
+var errVersionRejected = fmt.Errorf("protocol version is rejected by the server")
+
+// defaultFallbackInterval is the time the client uses V1 and VMux versions of the protocol
+// after the newer versions are rejected, before it tries them again.
+const defaultFallbackInterval = 5 * time.Minute
+
+// Client establishes reverse connections to the server.
+// The metadata is sent with V2 and VMux2 versions of the protocol. The server may close the connection for reasons
+// other than the unknown version, e.g. under load, so the version is retried once, and if the server closes
+// the connection again, the client falls back to V1 and VMux for the fallback interval and the metadata is not sent.
 type Client struct {
-	addr   string
-	dialer net.Dialer
+	dialer           net.Dialer
+	tlsConfig        *tls.Config
+	addr             string
+	token            string
+	sessions         []*session
+	muxSessions      int
+	handshakeTimeout time.Duration
+	fallbackInterval time.Duration
+	legacyUntil      atomic.Int64
+	mu               sync.Mutex
+}
+
+type ClientOption func(*Client)
+
+// WithClientToken sets the pre-shared token that is used to authenticate the client on the server.
+// If the token is empty, the client connects without authentication.
+func WithClientToken(token string) ClientOption {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithClientTLS enables TLS for connections to the server with the given configuration.
+// If the configuration is nil, the client connects over plain TCP.
+func WithClientTLS(cfg *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig = cfg
+	}
 }
 
-func NewClient(addr string) *Client {
-	return &Client{
-		addr:   addr,
-		dialer: net.Dialer{},
+// WithClientMux enables the multiplexed mode, connections are opened as streams of up to the given number
+// of long-lived mux sessions. New sessions are established on demand, streams are spread over them evenly.
+// If the number is zero, every connection is established separately.
+func WithClientMux(sessions int) ClientOption {
+	return func(c *Client) {
+		c.muxSessions = sessions
+	}
+}
+
+// WithClientHandshakeTimeout sets the maximum duration of the handshake, the deadline of the context
+// is used if it's earlier. Non-positive values keep the default of 10 seconds.
+func WithClientHandshakeTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		if d > 0 {
+			c.handshakeTimeout = d
+		}
+	}
+}
+
+func NewClient(addr string, opts ...ClientOption) *Client {
+	c := &Client{
+		addr:             addr,
+		dialer:           net.Dialer{},
+		handshakeTimeout: defaultHandshakeTimeout,
+		fallbackInterval: defaultFallbackInterval,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
+// Connect establishes the reverse connection for the connection request with the given id.
 func (c *Client) Connect(ctx context.Context, id uint64) (net.Conn, error) {
-	conn, err := c.dialer.DialContext(ctx, "tcp", c.addr)
+	return c.ConnectWithMetadata(ctx, id, nil)
+}
+
+// ConnectWithMetadata establishes the reverse connection for the connection request with the given id
+// and sends the metadata with it, unless the server supports only V1 and VMux versions of the protocol.
+func (c *Client) ConnectWithMetadata(ctx context.Context, id uint64, md Metadata) (net.Conn, error) {
+	if c.muxSessions > 0 {
+		return c.connectMux(ctx, id, md)
+	}
+
+	if len(md) == 0 || c.isLegacy() {
+		return c.connect(ctx, id, V1, nil)
+	}
+
+	return withFallback(c, V2, V1, func(ver Version) (net.Conn, error) {
+		if ver == V1 {
+			return c.connect(ctx, id, V1, nil)
+		}
+
+		return c.connect(ctx, id, ver, md)
+	})
+}
+
+// withFallback runs fn with the version, if the server rejects it twice in a row, fn is run with the legacy version
+// and the client falls back to the legacy versions for the fallback interval.
+func withFallback[T any](c *Client, ver, legacy Version, fn func(ver Version) (T, error)) (T, error) {
+	res, err := fn(ver)
+	if !errors.Is(err, errVersionRejected) {
+		return res, err
+	}
+
+	if res, err = fn(ver); !errors.Is(err, errVersionRejected) {
+		return res, err
+	}
+
+	c.fallback(ver)
+
+	return fn(legacy)
+}
+
+// isLegacy reports whether the client uses V1 and VMux versions of the protocol.
+func (c *Client) isLegacy() bool {
+	return time.Now().UnixNano() < c.legacyUntil.Load()
+}
+
+// fallback switches the client to V1 and VMux versions of the protocol for the fallback interval.
+func (c *Client) fallback(rejected Version) {
+	until := time.Now().Add(c.fallbackInterval)
+
+	if prev := c.legacyUntil.Swap(until.UnixNano()); time.Now().UnixNano() >= prev {
+		slog.Warn("server does not support the protocol version, metadata is not sent",
+			slog.Any("version", rejected), slog.Duration("retry_in", c.fallbackInterval))
+	}
+}
+
+// connect establishes the reverse connection with the given version of the protocol.
+func (c *Client) connect(ctx context.Context, id uint64, ver Version, md Metadata) (net.Conn, error) {
+	conn, err := c.dial(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
 
-	err = c.initialize(conn, id)
+	err = c.handshake(ctx, conn, func() error { return c.initialize(conn, id, ver, md) })
 	if err != nil {
 		if errC := conn.Close(); errC != nil {
 			err = errors.Join(err, errC)
@@ -39,31 +168,241 @@ func (c *Client) Connect(ctx context.Context, id uint64) (net.Conn, error) {
 	return conn, nil
 }
 
-func (c *Client) initialize(conn net.Conn, id uint64) error {
-	buf := []byte{byte(V1), byte(NoAuth)}
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	if c.tlsConfig == nil {
+		return c.dialer.DialContext(ctx, "tcp", c.addr)
+	}
+
+	tlsDialer := &tls.Dialer{
+		NetDialer: &c.dialer,
+		Config:    c.tlsConfig,
+	}
+
+	return tlsDialer.DialContext(ctx, "tcp", c.addr)
+}
+
+// handshake runs the handshake with the deadline of the handshake timeout or the context, whichever is earlier.
+// The deadline is cleared when the handshake succeeds.
+func (c *Client) handshake(ctx context.Context, conn net.Conn, fn func() error) error {
+	deadline := time.Now().Add(c.handshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("failed to set handshake deadline: %w", err)
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("failed to clear handshake deadline: %w", err)
+	}
+
+	return nil
+}
+
+// initialize negotiates the protocol version and the authentication method
+// and sends the connection request, which is signed if token authentication is used.
+func (c *Client) initialize(conn io.ReadWriter, id uint64, ver Version, md Metadata) error {
+	req, err := connRequest{version: ver, id: id, metadata: md}.encode(c.token)
+	if err != nil {
+		return err
+	}
+
+	if err := c.negotiate(conn, ver); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("failed to write connection id: %w", err)
+	}
+
+	return nil
+}
+
+// negotiate sends the hello message with the protocol version and the authentication method of the client
+// and checks that the server has accepted both.
+func (c *Client) negotiate(conn io.ReadWriter, ver Version) error {
+	expectedAuth := authMethodFor(c.token)
 
-	if _, err := conn.Write(buf); err != nil {
+	if _, err := conn.Write(hello{version: ver, auth: expectedAuth}.encode()); err != nil {
 		return fmt.Errorf("failed to write protocol version and authentication method: %w", err)
 	}
 
-	if _, err := conn.Read(buf); err != nil {
-		return fmt.Errorf("failed to read protocol version and authentication method: %w", err)
+	resp, err := readHello(conn)
+	if err != nil {
+		if (ver == V2 || ver == VMux2) &&
+			(errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)) {
+			return fmt.Errorf("%w: %w", errVersionRejected, err)
+		}
+
+		return err
 	}
 
-	if ver := Version(buf[0]); ver != V1 {
+	if resp.version != ver {
 		return fmt.Errorf("unsupported protocol version")
 	}
 
-	if authMethod := AuthMethod(buf[1]); authMethod != NoAuth {
+	if resp.auth != expectedAuth {
 		return fmt.Errorf("unsupported authentication method")
 	}
 
-	buf = make([]byte, connectionIDLenght+1)
-	buf[0] = byte(V1)
-	binary.BigEndian.PutUint64(buf[1:], id)
+	return nil
+}
 
-	if _, err := conn.Write(buf); err != nil {
-		return fmt.Errorf("failed to write connection id: %w", err)
+// connectMux opens the connection as a stream of one of the mux sessions.
+func (c *Client) connectMux(ctx context.Context, id uint64, md Metadata) (net.Conn, error) {
+	sess, err := c.muxSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := sess.open(id, md)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stream: %w", err)
+	}
+
+	return st, nil
+}
+
+// muxSession returns the mux session with the least number of streams.
+// A new session is established if the limit of sessions is not reached yet.
+// The session is dialed without holding the lock, so other streams are opened meanwhile,
+// if the limit is reached by the concurrent dials, the new session is closed and the existing one is used.
+func (c *Client) muxSession(ctx context.Context) (*session, error) {
+	if sess := c.leastLoadedSession(); sess != nil {
+		return sess, nil
+	}
+
+	sess, err := c.newMuxSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeDeadSessions()
+
+	if len(c.sessions) < c.muxSessions {
+		c.sessions = append(c.sessions, sess)
+		return sess, nil
+	}
+
+	if err := sess.Close(); err != nil {
+		slog.Debug("failed to close extra mux session", slog.Any("error", err))
+	}
+
+	return c.leastLoaded(), nil
+}
+
+// leastLoadedSession returns the session with the least number of streams,
+// or nil if the limit of sessions is not reached yet and a new session should be established.
+func (c *Client) leastLoadedSession() *session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeDeadSessions()
+
+	if len(c.sessions) < c.muxSessions {
+		return nil
+	}
+
+	return c.leastLoaded()
+}
+
+// removeDeadSessions removes the closed sessions, it must be called with the lock held.
+func (c *Client) removeDeadSessions() {
+	alive := c.sessions[:0]
+
+	for _, sess := range c.sessions {
+		if sess.isAlive() {
+			alive = append(alive, sess)
+		}
+	}
+
+	c.sessions = alive
+}
+
+// leastLoaded returns the session with the least number of streams, it must be called with the lock held
+// when there is at least one session.
+func (c *Client) leastLoaded() *session {
+	best := c.sessions[0]
+
+	for _, sess := range c.sessions[1:] {
+		if sess.numStreams() < best.numStreams() {
+			best = sess
+		}
+	}
+
+	return best
+}
+
+// newMuxSession establishes a new mux session, VMux2 is used unless the server supports only VMux.
+func (c *Client) newMuxSession(ctx context.Context) (*session, error) {
+	if c.isLegacy() {
+		return c.dialMuxSession(ctx, VMux)
+	}
+
+	return withFallback(c, VMux2, VMux, func(ver Version) (*session, error) {
+		return c.dialMuxSession(ctx, ver)
+	})
+}
+
+// dialMuxSession establishes a new mux session with the given version of the protocol.
+func (c *Client) dialMuxSession(ctx context.Context, ver Version) (*session, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	if err := c.handshake(ctx, conn, func() error { return c.initializeMux(conn, ver) }); err != nil {
+		if errC := conn.Close(); errC != nil {
+			err = errors.Join(err, errC)
+		}
+
+		return nil, fmt.Errorf("failed to initialize mux session: %w", err)
+	}
+
+	sess := newSession(conn, ver, nil)
+
+	go func() {
+		if err := sess.serve(); err != nil {
+			slog.Debug("mux session is closed", slog.Any("error", err))
+		}
+	}()
+
+	return sess, nil
+}
+
+// initializeMux performs the handshake of the mux session.
+// If token authentication is used, the client signs the nonce sent by the server.
+func (c *Client) initializeMux(conn io.ReadWriter, ver Version) error {
+	if err := c.negotiate(conn, ver); err != nil {
+		return err
+	}
+
+	if authMethodFor(c.token) == TokenAuth {
+		nonce := make([]byte, muxNonceLength)
+		if _, err := io.ReadFull(conn, nonce); err != nil {
+			return fmt.Errorf("failed to read nonce: %w", err)
+		}
+
+		if _, err := conn.Write(sign(c.token, nonce)); err != nil {
+			return fmt.Errorf("failed to write authentication token: %w", err)
+		}
+	}
+
+	ack := make([]byte, 1)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("failed to read session acknowledgement: %w", err)
+	}
+
+	if ack[0] != muxAccepted {
+		return fmt.Errorf("mux session is rejected")
 	}
 
 	return nil
@@ -0,0 +1,252 @@
+package revconn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+)
+
+// The handshake consists of the messages below, all of them are read in full,
+// so the handshake does not depend on how the bytes are split into TCP segments.
+//
+// hello:       version (1 byte), authentication method (1 byte)
+// V1 request:  version (1 byte), connection id (8 bytes), HMAC of the id (32 bytes, only with token authentication)
+// V2 request:  version (1 byte), connection id (8 bytes), metadata,
+//              HMAC of the id and the metadata (32 bytes, only with token authentication)
+// metadata:    number of entries (2 bytes), the key and the value of every entry as fields, sorted by the key
+//
+// Variable-length fields are encoded with the length prefix: field length (2 bytes) followed by the field.
+// In the VMux2 mode the payload of the OPEN frame is the connection id followed by the metadata of the stream.
+// Unknown versions and values are rejected before anything else is read, so no bytes beyond the handshake are consumed.
+
+var (
+	ErrInvalidHandshake = fmt.Errorf("invalid handshake")
+	ErrFieldTooLong     = fmt.Errorf("%w: field is too long", ErrInvalidHandshake)
+)
+
+const (
+	fieldLengthPrefix = 2
+	maxFieldLength    = math.MaxUint16
+
+	maxMetadataEntries     = 32
+	maxMetadataFieldLength = 1024
+	maxMetadataLength      = 8 * 1024
+)
+
+// Well-known metadata keys, the trace context is sent with the keys of the W3C Trace Context propagator.
+const (
+	MetaInstanceID  = "instance_id"
+	MetaService     = "service"
+	MetaDestination = "destination"
+)
+
+// Metadata is the set of key/value pairs that the client sends with the connection.
+type Metadata map[string]string
+
+// hello is the first message of the handshake, the client sends the protocol version and the authentication method
+// it's going to use, the server replies with the same version and the authentication method it requires.
+type hello struct {
+	version Version
+	auth    AuthMethod
+}
+
+func (h hello) encode() []byte {
+	return []byte{byte(h.version), byte(h.auth)}
+}
+
+// readHello reads the hello message, it fails with ErrInvalidHandshake if the version or the method is unknown.
+func readHello(r io.Reader) (hello, error) {
+	buf := make([]byte, connectionInitLength)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return hello{}, fmt.Errorf("failed to read protocol version and authentication method: %w", err)
+	}
+
+	h := hello{version: Version(buf[0]), auth: AuthMethod(buf[1])}
+
+	switch h.version {
+	case V1, V2, VMux, VMux2:
+	default:
+		return hello{}, fmt.Errorf("%w: unsupported protocol version %d", ErrInvalidHandshake, h.version)
+	}
+
+	switch h.auth {
+	case NoAuth, TokenAuth, NoAcceptableAuthMethod:
+	default:
+		return hello{}, fmt.Errorf("%w: unknown authentication method %d", ErrInvalidHandshake, h.auth)
+	}
+
+	return h, nil
+}
+
+// connRequest is the message that binds the reverse connection to the connection request with the id.
+// The metadata is sent only in V2, the signature is present only if the token authentication is used.
+// signed is the part of the message that is covered by the signature.
+type connRequest struct {
+	metadata Metadata
+	mac      []byte
+	signed   []byte
+	id       uint64
+	version  Version
+}
+
+// encode encodes the request and signs it with the token, the request is not signed if the token is empty.
+func (c connRequest) encode(token string) ([]byte, error) {
+	buf := make([]byte, 1+connectionIDLenght)
+	buf[0] = byte(c.version)
+	binary.BigEndian.PutUint64(buf[1:], c.id)
+
+	if c.version == V2 {
+		var err error
+		if buf, err = appendMetadata(buf, c.metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	if token == "" {
+		return buf, nil
+	}
+
+	return append(buf, sign(token, buf[1:])...), nil
+}
+
+// readConnRequest reads the connection request of the negotiated version,
+// the signature is read only for the token authentication.
+func readConnRequest(r io.Reader, ver Version, auth AuthMethod) (connRequest, error) {
+	buf := make([]byte, 1+connectionIDLenght)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return connRequest{}, fmt.Errorf("failed to read connection id: %w", err)
+	}
+
+	req := connRequest{version: Version(buf[0]), id: binary.BigEndian.Uint64(buf[1:])}
+
+	if req.version != ver || (ver != V1 && ver != V2) {
+		return connRequest{}, fmt.Errorf("%w: unsupported protocol version %d", ErrInvalidHandshake, req.version)
+	}
+
+	signed := bytes.NewBuffer(buf[1:])
+
+	if req.version == V2 {
+		md, err := readMetadata(io.TeeReader(r, signed))
+		if err != nil {
+			return connRequest{}, err
+		}
+
+		req.metadata = md
+	}
+
+	req.signed = signed.Bytes()
+
+	if auth == TokenAuth {
+		req.mac = make([]byte, tokenMACLength)
+		if _, err := io.ReadFull(r, req.mac); err != nil {
+			return connRequest{}, fmt.Errorf("failed to read authentication token: %w", err)
+		}
+	}
+
+	return req, nil
+}
+
+// appendField appends the length-prefixed field to the buffer.
+func appendField(buf, field []byte) ([]byte, error) {
+	if len(field) > maxFieldLength {
+		return nil, ErrFieldTooLong
+	}
+
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(field))) //nolint:gosec // the length is checked above
+
+	return append(buf, field...), nil
+}
+
+// readField reads the length-prefixed field, it fails with ErrFieldTooLong if the field is longer than maxLen.
+func readField(r io.Reader, maxLen int) ([]byte, error) {
+	prefix := make([]byte, fieldLengthPrefix)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("failed to read field length: %w", err)
+	}
+
+	n := int(binary.BigEndian.Uint16(prefix))
+	if n > maxLen {
+		return nil, ErrFieldTooLong
+	}
+
+	field := make([]byte, n)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, fmt.Errorf("failed to read field: %w", err)
+	}
+
+	return field, nil
+}
+
+// appendMetadata appends the encoded metadata to the buffer.
+// It fails with ErrInvalidHandshake if the metadata exceeds the limits of the protocol.
+func appendMetadata(buf []byte, md Metadata) ([]byte, error) {
+	if len(md) > maxMetadataEntries {
+		return nil, fmt.Errorf("%w: too many metadata entries", ErrInvalidHandshake)
+	}
+
+	start := len(buf)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(md))) //nolint:gosec // the number is checked above
+
+	keys := make([]string, 0, len(md))
+	for key := range md {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		if key == "" || len(key) > maxMetadataFieldLength || len(md[key]) > maxMetadataFieldLength {
+			return nil, fmt.Errorf("%w: invalid metadata entry %q", ErrInvalidHandshake, key)
+		}
+
+		buf, _ = appendField(buf, []byte(key))
+		buf, _ = appendField(buf, []byte(md[key]))
+	}
+
+	if len(buf)-start > maxMetadataLength {
+		return nil, fmt.Errorf("%w: metadata is too long", ErrInvalidHandshake)
+	}
+
+	return buf, nil
+}
+
+// readMetadata reads the metadata, no more than maxMetadataLength bytes are consumed.
+// It fails with ErrInvalidHandshake if the metadata exceeds the limits of the protocol, a key is empty or repeated.
+func readMetadata(r io.Reader) (Metadata, error) {
+	r = io.LimitReader(r, maxMetadataLength)
+
+	prefix := make([]byte, fieldLengthPrefix)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	n := int(binary.BigEndian.Uint16(prefix))
+	if n > maxMetadataEntries {
+		return nil, fmt.Errorf("%w: too many metadata entries", ErrInvalidHandshake)
+	}
+
+	md := make(Metadata, n)
+
+	for range n {
+		key, err := readField(r, maxMetadataFieldLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata key: %w", err)
+		}
+
+		value, err := readField(r, maxMetadataFieldLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata value: %w", err)
+		}
+
+		if _, ok := md[string(key)]; ok || len(key) == 0 {
+			return nil, fmt.Errorf("%w: invalid metadata key %q", ErrInvalidHandshake, key)
+		}
+
+		md[string(key)] = string(value)
+	}
+
+	return md, nil
+}
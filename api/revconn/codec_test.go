@@ -0,0 +1,517 @@
+package revconn
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHello_RoundTrip(t *testing.T) {
+	for _, ver := range []Version{V1, VMux} {
+		for _, auth := range []AuthMethod{NoAuth, TokenAuth, NoAcceptableAuthMethod} {
+			h := hello{version: ver, auth: auth}
+
+			got, err := readHello(iotest.OneByteReader(bytes.NewReader(h.encode())))
+
+			require.NoError(t, err)
+			assert.Equal(t, h, got)
+		}
+	}
+}
+
+func TestReadHello_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "unknown version", data: []byte{3, byte(NoAuth)}},
+		{name: "unknown auth method", data: []byte{byte(V1), 7}},
+		{name: "short", data: []byte{byte(V1)}},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readHello(bytes.NewReader(tt.data))
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConnRequest_RoundTrip(t *testing.T) {
+	md := Metadata{MetaService: "service1", MetaDestination: "127.0.0.1:8080", "empty": ""}
+
+	tests := []struct {
+		name  string
+		token string
+		req   connRequest
+	}{
+		{name: "V1 no auth", req: connRequest{version: V1, id: 42}},
+		{name: "V1 token auth", req: connRequest{version: V1, id: 42}, token: "token"},
+		{name: "V2 no auth", req: connRequest{version: V2, id: 42, metadata: md}},
+		{name: "V2 token auth", req: connRequest{version: V2, id: 42, metadata: md}, token: "token"},
+		{name: "V2 no metadata", req: connRequest{version: V2, id: 42, metadata: Metadata{}}, token: "token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.req.encode(tt.token)
+			require.NoError(t, err)
+
+			payload := []byte("payload")
+			r := bytes.NewReader(append(data, payload...))
+
+			got, err := readConnRequest(iotest.OneByteReader(r), tt.req.version, authMethodFor(tt.token))
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.req.id, got.id)
+			assert.Equal(t, tt.req.metadata, got.metadata)
+
+			if tt.token != "" {
+				assert.Equal(t, sign(tt.token, got.signed), got.mac)
+			}
+
+			// The bytes that follow the handshake are not consumed.
+			assert.Equal(t, len(payload), r.Len())
+		})
+	}
+}
+
+func TestConnRequest_V1Signature(t *testing.T) {
+	// The signature of V1 requests is compatible with the clients that sign only the connection id.
+	data, err := connRequest{version: V1, id: 42}.encode("token")
+
+	require.NoError(t, err)
+	assert.Equal(t, signConnectionID("token", 42), data[1+connectionIDLenght:])
+}
+
+func TestReadConnRequest_Invalid(t *testing.T) {
+	v2, err := connRequest{version: V2, id: 42, metadata: Metadata{"key": "value"}}.encode("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+		ver  Version
+	}{
+		{name: "version mismatch", data: v2, ver: V1},
+		{name: "mux version", data: []byte{byte(VMux), 0, 0, 0, 0, 0, 0, 0, 42}, ver: VMux},
+		{name: "truncated metadata", data: v2[:len(v2)-1], ver: V2},
+		{name: "duplicate key", data: []byte{byte(V2), 0, 0, 0, 0, 0, 0, 0, 42, 0, 2, 0, 1, 'k', 0, 0, 0, 1, 'k', 0, 0}, ver: V2},
+		{name: "empty key", data: []byte{byte(V2), 0, 0, 0, 0, 0, 0, 0, 42, 0, 1, 0, 0, 0, 0}, ver: V2},
+		{name: "too many entries", data: []byte{byte(V2), 0, 0, 0, 0, 0, 0, 0, 42, 0xff, 0xff}, ver: V2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readConnRequest(bytes.NewReader(tt.data), tt.ver, NoAuth)
+
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAppendMetadata_Limits(t *testing.T) {
+	tooMany := make(Metadata, maxMetadataEntries+1)
+	for i := range maxMetadataEntries + 1 {
+		tooMany[string(rune('a'+i))] = ""
+	}
+
+	tooLong := make(Metadata, maxMetadataEntries)
+	for i := range maxMetadataEntries {
+		tooLong[string(rune('a'+i))] = string(make([]byte, maxMetadataFieldLength))
+	}
+
+	for _, md := range []Metadata{tooMany, tooLong, {"": "value"}, {"key": string(make([]byte, maxMetadataFieldLength+1))}} {
+		_, err := appendMetadata(nil, md)
+
+		assert.ErrorIs(t, err, ErrInvalidHandshake)
+	}
+}
+
+func TestField_RoundTrip(t *testing.T) {
+	buf, err := appendField(nil, []byte("first"))
+	require.NoError(t, err)
+
+	buf, err = appendField(buf, nil)
+	require.NoError(t, err)
+
+	r := iotest.OneByteReader(bytes.NewReader(buf))
+
+	field, err := readField(r, maxFieldLength)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), field)
+
+	field, err = readField(r, maxFieldLength)
+	require.NoError(t, err)
+	assert.Empty(t, field)
+
+	_, err = appendField(nil, make([]byte, maxFieldLength+1))
+	assert.ErrorIs(t, err, ErrFieldTooLong)
+
+	_, err = readField(bytes.NewReader([]byte{0, 5, 'a', 'b', 'c', 'd', 'e'}), 4)
+	assert.ErrorIs(t, err, ErrFieldTooLong)
+}
+
+// splitConn delivers the written bytes one by one, as if every byte was sent in a separate TCP segment.
+type splitConn struct {
+	net.Conn
+}
+
+func (c splitConn) Read(p []byte) (int, error) {
+	return iotest.OneByteReader(c.Conn).Read(p)
+}
+
+func TestHandshake_SplitSegments(t *testing.T) {
+	md := Metadata{MetaService: "service1"}
+
+	tests := []struct {
+		metadata Metadata
+		name     string
+		token    string
+		ver      Version
+	}{
+		{name: "V1 no auth", ver: V1},
+		{name: "V1 token auth", token: "token", ver: V1},
+		{name: "V2 token auth", token: "token", ver: V2, metadata: md},
+		{name: "VMux no auth", ver: VMux},
+		{name: "VMux token auth", token: "token", ver: VMux},
+		{name: "VMux2 token auth", token: "token", ver: VMux2, metadata: md},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accepted := make(chan ConnInfo, 1)
+
+			srv := NewServer(func(info ConnInfo, _ net.Conn) error {
+				accepted <- info
+				return nil
+			}, WithServerTokens(map[string]string{"example": tt.token}))
+			defer srv.closeSessions()
+
+			client := NewClient("", WithClientToken(tt.token))
+
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+
+			go srv.handleConn(splitConn{serverConn})
+
+			if tt.ver.isMux() {
+				require.NoError(t, client.initializeMux(splitConn{clientConn}, tt.ver))
+
+				sess := newSession(splitConn{clientConn}, tt.ver, nil)
+				defer sess.Close()
+
+				go func() { _ = sess.serve() }()
+
+				_, err := sess.open(42, md)
+				require.NoError(t, err)
+			} else {
+				require.NoError(t, client.initialize(splitConn{clientConn}, 42, tt.ver, md))
+			}
+
+			info := <-accepted
+			assert.Equal(t, uint64(42), info.ID)
+
+			// The metadata is sent only with the versions that support it.
+			assert.Equal(t, tt.metadata, info.Metadata)
+
+			if tt.token != "" {
+				assert.Equal(t, "example", info.Identity)
+			}
+		})
+	}
+}
+
+func TestClient_FallbackToLegacyServer(t *testing.T) {
+	for _, mux := range []int{0, 1} {
+		accepted := make(chan ConnInfo, 1)
+
+		srv := NewServer(func(info ConnInfo, _ net.Conn) error {
+			accepted <- info
+			return nil
+		})
+		defer srv.closeSessions()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		defer lis.Close()
+
+		// The legacy server closes the connection if the version is unknown to it.
+		go func() {
+			for {
+				conn, err := lis.Accept()
+				if err != nil {
+					return
+				}
+
+				buf := make([]byte, connectionInitLength)
+				if _, err := io.ReadFull(conn, buf); err != nil || (Version(buf[0]) != V1 && Version(buf[0]) != VMux) {
+					conn.Close()
+					continue
+				}
+
+				go srv.handleConn(&prefixConn{Conn: conn, r: io.MultiReader(bytes.NewReader(buf), conn)})
+			}
+		}()
+
+		client := NewClient(lis.Addr().String(), WithClientMux(mux))
+
+		conn, err := client.ConnectWithMetadata(context.Background(), 42, Metadata{MetaService: "service1"})
+		require.NoError(t, err)
+
+		defer conn.Close()
+
+		info := <-accepted
+		assert.Equal(t, uint64(42), info.ID)
+		assert.Nil(t, info.Metadata)
+		assert.True(t, client.isLegacy())
+	}
+}
+
+func TestClient_RetriesRejectedVersion(t *testing.T) {
+	for _, mux := range []int{0, 1} {
+		accepted := make(chan ConnInfo, 2)
+
+		srv := NewServer(func(info ConnInfo, _ net.Conn) error {
+			accepted <- info
+			return nil
+		})
+		defer srv.closeSessions()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		defer lis.Close()
+
+		// The server closes the first connection after the hello message, e.g. when it's overloaded.
+		go func() {
+			for i := 0; ; i++ {
+				conn, err := lis.Accept()
+				if err != nil {
+					return
+				}
+
+				if i == 0 {
+					_, _ = io.ReadFull(conn, make([]byte, connectionInitLength))
+					conn.Close()
+
+					continue
+				}
+
+				go srv.handleConn(conn)
+			}
+		}()
+
+		client := NewClient(lis.Addr().String(), WithClientMux(mux))
+		md := Metadata{MetaService: "service1"}
+
+		// Both the retried connection and the next one use the version with the metadata.
+		for id := range uint64(2) {
+			conn, err := client.ConnectWithMetadata(context.Background(), id, md)
+			require.NoError(t, err)
+
+			defer conn.Close()
+
+			info := <-accepted
+			assert.Equal(t, id, info.ID)
+			assert.Equal(t, md, info.Metadata)
+			assert.False(t, client.isLegacy())
+		}
+	}
+}
+
+func TestClient_FallbackExpires(t *testing.T) {
+	client := NewClient("")
+	client.fallbackInterval = 50 * time.Millisecond
+
+	assert.False(t, client.isLegacy())
+
+	client.fallback(V2)
+
+	assert.True(t, client.isLegacy())
+	assert.Eventually(t, func() bool { return !client.isLegacy() }, time.Second, 10*time.Millisecond)
+}
+
+func TestClient_MuxSessionsDialedConcurrently(t *testing.T) {
+	accepted := make(chan ConnInfo, 2)
+
+	srv := NewServer(func(info ConnInfo, _ net.Conn) error {
+		accepted <- info
+		return nil
+	})
+	defer srv.closeSessions()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer lis.Close()
+
+	// The server doesn't serve the sessions until both of them are dialed,
+	// so the connections succeed only if the sessions are dialed concurrently.
+	go func() {
+		conns := make([]net.Conn, 0, 2)
+
+		for len(conns) < 2 {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+
+			conns = append(conns, conn)
+		}
+
+		for _, conn := range conns {
+			go srv.handleConn(conn)
+		}
+	}()
+
+	client := NewClient(lis.Addr().String(), WithClientMux(1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := make(chan error, 2)
+
+	for id := range uint64(2) {
+		go func() {
+			conn, err := client.ConnectWithMetadata(ctx, id, nil)
+			if err == nil {
+				t.Cleanup(func() { _ = conn.Close() })
+			}
+
+			errs <- err
+		}()
+	}
+
+	for range 2 {
+		require.NoError(t, <-errs)
+		<-accepted
+	}
+
+	// The session dialed over the limit is closed.
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	assert.Len(t, client.sessions, 1)
+}
+
+// prefixConn returns the bytes that are already read from the connection before the rest of its data.
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func FuzzReadHello(f *testing.F) {
+	f.Add([]byte{byte(V1), byte(NoAuth)})
+	f.Add([]byte{byte(VMux), byte(TokenAuth)})
+	f.Add([]byte{0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h, err := readHello(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		assert.Equal(t, data[:connectionInitLength], h.encode())
+	})
+}
+
+func FuzzReadConnRequest(f *testing.F) {
+	v1, _ := connRequest{version: V1, id: 1}.encode("token")
+	v2, _ := connRequest{version: V2, id: 1, metadata: Metadata{MetaService: "service1", "key": ""}}.encode("token")
+
+	f.Add(v1, true)
+	f.Add(v2, true)
+	f.Add(v2, false)
+	f.Add([]byte{byte(VMux), 0, 0}, true)
+
+	f.Fuzz(func(t *testing.T, data []byte, tokenAuth bool) {
+		auth := NoAuth
+		if tokenAuth {
+			auth = TokenAuth
+		}
+
+		for _, ver := range []Version{V1, V2} {
+			r := bytes.NewReader(data)
+
+			req, err := readConnRequest(r, ver, auth)
+			if err != nil {
+				continue
+			}
+
+			consumed := data[:len(data)-r.Len()]
+
+			// The signed part is the message without the version and the signature.
+			assert.Equal(t, consumed[1:1+len(req.signed)], req.signed)
+			assert.Len(t, consumed, 1+len(req.signed)+len(req.mac))
+
+			// The metadata is decoded into the same entries after encoding.
+			encoded, err := connRequest{version: ver, id: req.id, metadata: req.metadata}.encode("")
+			require.NoError(t, err)
+
+			got, err := readConnRequest(bytes.NewReader(encoded), ver, NoAuth)
+			require.NoError(t, err)
+			assert.Equal(t, req.metadata, got.metadata)
+		}
+	})
+}
+
+func FuzzReadField(f *testing.F) {
+	f.Add([]byte{0, 3, 'a', 'b', 'c'}, 16)
+	f.Add([]byte{0xff, 0xff}, maxFieldLength)
+	f.Add([]byte{0}, 0)
+
+	f.Fuzz(func(t *testing.T, data []byte, maxLen int) {
+		r := bytes.NewReader(data)
+
+		field, err := readField(r, maxLen)
+		if err != nil {
+			return
+		}
+
+		assert.LessOrEqual(t, len(field), maxLen)
+
+		encoded, err := appendField(nil, field)
+		require.NoError(t, err)
+		assert.Equal(t, data[:len(encoded)], encoded)
+		assert.Equal(t, len(data)-len(encoded), r.Len())
+	})
+}
+
+func FuzzReadOpenMetadata(f *testing.F) {
+	md, _ := appendMetadata(nil, Metadata{MetaService: "service1"})
+
+	f.Add(md)
+	f.Add([]byte{0, 0})
+	f.Add([]byte{0, 1, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		sess := newSession(nil, VMux2, nil)
+
+		md, err := sess.readOpenMetadata(data)
+		if err != nil {
+			return
+		}
+
+		encoded, err := appendMetadata(nil, md)
+		require.NoError(t, err)
+
+		got, err := sess.readOpenMetadata(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, md, got)
+	})
+}
@@ -0,0 +1,325 @@
+package revconn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"syscall"
+)
+
+// In the multiplexed mode many logical streams share one connection.
+// After the handshake both sides exchange frames, every frame starts with the header:
+// frame type (1 byte), stream id (4 bytes) and payload length (4 bytes), followed by the payload.
+// The client opens streams with the OPEN frame, its payload is the id of the connection request.
+// The data is sent with DATA frames, the sender may send only as much data as the receiver has allowed,
+// initially it's initialStreamWindow bytes, and the receiver extends it with WINDOW frames as it consumes the data.
+// FIN closes the sending direction of the stream, RESET aborts the stream in both directions.
+// The server refuses the OPEN frames above its limit of the open streams with RESET, the session stays usable.
+type frameType byte
+
+const (
+	frameOpen   frameType = 1
+	frameData   frameType = 2
+	frameWindow frameType = 3
+	frameFin    frameType = 4
+	frameReset  frameType = 5
+)
+
+const (
+	frameHeaderLength   = 9
+	maxFramePayload     = 16 * 1024
+	initialStreamWindow = 256 * 1024
+	windowUpdateLength  = 4
+	muxNonceLength      = 8
+	muxAccepted         = 0
+)
+
+var (
+	ErrSessionClosed  = fmt.Errorf("mux session is closed: %w", net.ErrClosed)
+	ErrStreamReset    = fmt.Errorf("stream is reset: %w", syscall.ECONNRESET)
+	ErrProtocol       = fmt.Errorf("mux protocol error")
+	ErrWindowExceeded = fmt.Errorf("%w: flow control window exceeded", ErrProtocol)
+)
+
+type frame struct {
+	payload  []byte
+	streamID uint32
+	typ      frameType
+}
+
+// session multiplexes streams over a single connection.
+// The client side opens streams, the server side accepts them and passes them to onOpen.
+// The streams carry the metadata only if the session is established with VMux2.
+// If maxStreams is positive, the streams that the peer opens above that number are reset.
+type session struct {
+	conn       net.Conn
+	onOpen     func(st *stream, id uint64, md Metadata)
+	streams    map[uint32]*stream
+	closed     chan struct{}
+	maxStreams int
+	nextID     uint32
+	mu         sync.Mutex
+	writeMu    sync.Mutex
+	version    Version
+	isClosed   bool
+}
+
+func newSession(conn net.Conn, ver Version, onOpen func(st *stream, id uint64, md Metadata)) *session {
+	return &session{
+		conn:    conn,
+		version: ver,
+		onOpen:  onOpen,
+		streams: make(map[uint32]*stream),
+		closed:  make(chan struct{}),
+	}
+}
+
+// open opens a new stream for the connection request with the given id.
+// The metadata is sent only if the session supports it.
+func (s *session) open(id uint64, md Metadata) (*stream, error) {
+	payload := make([]byte, connectionIDLenght)
+	binary.BigEndian.PutUint64(payload, id)
+
+	if s.version == VMux2 {
+		var err error
+		if payload, err = appendMetadata(payload, md); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+
+	if s.isClosed {
+		s.mu.Unlock()
+		return nil, ErrSessionClosed
+	}
+
+	s.nextID++
+	st := newStream(s, s.nextID)
+	s.streams[st.id] = st
+	s.mu.Unlock()
+
+	if err := s.writeFrame(frameOpen, st.id, payload); err != nil {
+		s.remove(st.id)
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// numStreams returns the number of open streams.
+func (s *session) numStreams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.streams)
+}
+
+// isAlive reports whether the session is not closed.
+func (s *session) isAlive() bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+// serve reads frames from the connection and dispatches them to streams until the connection fails.
+// When it returns, the session is closed and all its streams are reset with the error wrapped with ErrSessionClosed.
+func (s *session) serve() error {
+	err := s.readFrames()
+	s.closeWithError(fmt.Errorf("%w: %w", ErrSessionClosed, err))
+
+	return err
+}
+
+func (s *session) readFrames() error {
+	hdr := make([]byte, frameHeaderLength)
+
+	for {
+		if _, err := io.ReadFull(s.conn, hdr); err != nil {
+			return fmt.Errorf("failed to read frame header: %w", err)
+		}
+
+		f := frame{
+			typ:      frameType(hdr[0]),
+			streamID: binary.BigEndian.Uint32(hdr[1:5]),
+		}
+
+		length := binary.BigEndian.Uint32(hdr[5:])
+		if length > maxFramePayload {
+			return fmt.Errorf("%w: frame payload is too large: %d", ErrProtocol, length)
+		}
+
+		f.payload = make([]byte, length)
+		if _, err := io.ReadFull(s.conn, f.payload); err != nil {
+			return fmt.Errorf("failed to read frame payload: %w", err)
+		}
+
+		if err := s.handleFrame(f); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) handleFrame(f frame) error {
+	if f.typ == frameOpen {
+		return s.handleOpen(f)
+	}
+
+	s.mu.Lock()
+	st, ok := s.streams[f.streamID]
+	s.mu.Unlock()
+
+	if !ok {
+		// The stream is already closed locally, the peer is told to stop sending data to it.
+		if f.typ == frameData {
+			return s.writeFrame(frameReset, f.streamID, nil)
+		}
+
+		return nil
+	}
+
+	switch f.typ {
+	case frameData:
+		if err := st.pushData(f.payload); err != nil {
+			st.reset(err)
+			return s.writeFrame(frameReset, f.streamID, nil)
+		}
+	case frameWindow:
+		if len(f.payload) != windowUpdateLength {
+			return fmt.Errorf("%w: invalid window update", ErrProtocol)
+		}
+
+		st.extendWindow(binary.BigEndian.Uint32(f.payload))
+	case frameFin:
+		st.pushFin()
+	case frameReset:
+		st.reset(ErrStreamReset)
+	default:
+		return fmt.Errorf("%w: unknown frame type %d", ErrProtocol, f.typ)
+	}
+
+	return nil
+}
+
+func (s *session) handleOpen(f frame) error {
+	if s.onOpen == nil || len(f.payload) < connectionIDLenght {
+		return fmt.Errorf("%w: unexpected open frame", ErrProtocol)
+	}
+
+	md, err := s.readOpenMetadata(f.payload[connectionIDLenght:])
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+
+	if _, ok := s.streams[f.streamID]; ok {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: stream %d is already open", ErrProtocol, f.streamID)
+	}
+
+	if s.maxStreams > 0 && len(s.streams) >= s.maxStreams {
+		s.mu.Unlock()
+
+		slog.Warn("mux stream is refused, too many open streams", slog.Int("max_streams", s.maxStreams))
+
+		return s.writeFrame(frameReset, f.streamID, nil)
+	}
+
+	st := newStream(s, f.streamID)
+	s.streams[st.id] = st
+	s.mu.Unlock()
+
+	go s.onOpen(st, binary.BigEndian.Uint64(f.payload), md)
+
+	return nil
+}
+
+// readOpenMetadata decodes the metadata that follows the connection id in the payload of the OPEN frame.
+// The payload must not contain anything else, and it has no metadata unless the session is established with VMux2.
+func (s *session) readOpenMetadata(data []byte) (Metadata, error) {
+	if s.version != VMux2 {
+		if len(data) != 0 {
+			return nil, fmt.Errorf("%w: unexpected open frame", ErrProtocol)
+		}
+
+		return nil, nil
+	}
+
+	r := bytes.NewReader(data)
+
+	md, err := readMetadata(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid stream metadata: %w", ErrProtocol, err)
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%w: unexpected data after stream metadata", ErrProtocol)
+	}
+
+	return md, nil
+}
+
+// writeFrame writes the frame to the connection, frames of different streams are serialized.
+func (s *session) writeFrame(typ frameType, streamID uint32, payload []byte) error {
+	buf := make([]byte, frameHeaderLength+len(payload))
+	buf[0] = byte(typ)
+	binary.BigEndian.PutUint32(buf[1:5], streamID)
+	binary.BigEndian.PutUint32(buf[5:9], uint32(len(payload))) //nolint:gosec // payload never exceeds maxFramePayload
+	copy(buf[frameHeaderLength:], payload)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if !s.isAlive() {
+		return ErrSessionClosed
+	}
+
+	if _, err := s.conn.Write(buf); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+
+	return nil
+}
+
+// remove removes the stream from the session.
+func (s *session) remove(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.streams, id)
+}
+
+// Close closes the session and all its streams.
+func (s *session) Close() error {
+	s.closeWithError(ErrSessionClosed)
+	return nil
+}
+
+func (s *session) closeWithError(err error) {
+	s.mu.Lock()
+
+	if s.isClosed {
+		s.mu.Unlock()
+		return
+	}
+
+	s.isClosed = true
+	streams := s.streams
+	s.streams = make(map[uint32]*stream)
+	close(s.closed)
+	s.mu.Unlock()
+
+	_ = s.conn.Close()
+
+	for _, st := range streams {
+		st.reset(err)
+	}
+}
@@ -0,0 +1,360 @@
+package revconn
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type acceptedStream struct {
+	st *stream
+	md Metadata
+	id uint64
+}
+
+// newSessionPair connects the client and the server sessions over the pipe,
+// the streams opened by the client are passed to the returned channel.
+func newSessionPair(t *testing.T, ver Version, maxStreams int) (client, server *session, accepted <-chan acceptedStream) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+
+	streams := make(chan acceptedStream, 10)
+
+	client = newSession(clientConn, ver, nil)
+	server = newSession(serverConn, ver, func(st *stream, id uint64, md Metadata) {
+		streams <- acceptedStream{st: st, id: id, md: md}
+	})
+	server.maxStreams = maxStreams
+
+	go func() { _ = client.serve() }()
+	go func() { _ = server.serve() }()
+
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return client, server, streams
+}
+
+// openStream opens the stream on the client session and waits for the server to accept it.
+func openStream(t *testing.T, client *session, accepted <-chan acceptedStream, id uint64) (local, remote *stream) {
+	t.Helper()
+
+	local, err := client.open(id, nil)
+	require.NoError(t, err)
+
+	select {
+	case a := <-accepted:
+		require.Equal(t, id, a.id)
+		return local, a.st
+	case <-time.After(time.Second):
+		require.FailNow(t, "stream is not accepted")
+		return nil, nil
+	}
+}
+
+func TestSession_Open(t *testing.T) {
+	client, _, accepted := newSessionPair(t, VMux2, 0)
+
+	md := Metadata{MetaService: "service1", MetaInstanceID: "revproxy-1"}
+
+	local, err := client.open(42, md)
+	require.NoError(t, err)
+
+	a := <-accepted
+	assert.Equal(t, uint64(42), a.id)
+	assert.Equal(t, md, a.md)
+
+	go func() {
+		_, _ = local.Write([]byte("ping"))
+	}()
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(a.st, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf))
+
+	go func() {
+		_, _ = a.st.Write([]byte("pong"))
+	}()
+
+	_, err = io.ReadFull(local, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(buf))
+}
+
+func TestSession_Open_NoMetadataInVMux(t *testing.T) {
+	client, _, accepted := newSessionPair(t, VMux, 0)
+
+	_, err := client.open(42, Metadata{MetaService: "service1"})
+	require.NoError(t, err)
+
+	a := <-accepted
+	assert.Equal(t, uint64(42), a.id)
+	assert.Nil(t, a.md)
+}
+
+func TestStream_WindowExhaustionAndResume(t *testing.T) {
+	client, _, accepted := newSessionPair(t, VMux, 0)
+	local, remote := openStream(t, client, accepted, 1)
+
+	data := bytes.Repeat([]byte{1}, initialStreamWindow+maxFramePayload)
+
+	// The peer doesn't read, so the writer blocks once the window is exhausted.
+	require.NoError(t, local.SetWriteDeadline(time.Now().Add(100*time.Millisecond)))
+
+	n, err := local.Write(data)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Equal(t, initialStreamWindow, n)
+
+	require.NoError(t, local.SetWriteDeadline(time.Time{}))
+
+	written := make(chan error, 1)
+
+	go func() {
+		_, err := local.Write(data[n:])
+		written <- err
+	}()
+
+	// Reading the data extends the window, so the rest of the data is sent.
+	received := make([]byte, len(data))
+	_, err = io.ReadFull(remote, received)
+	require.NoError(t, err)
+
+	assert.NoError(t, <-written)
+	assert.Equal(t, data, received)
+}
+
+func TestStream_WindowExceeded(t *testing.T) {
+	client, server, accepted := newSessionPair(t, VMux, 0)
+	local, remote := openStream(t, client, accepted, 1)
+
+	// The peer that ignores the window gets the stream reset.
+	local.mu.Lock()
+	local.sendWindow = initialStreamWindow + maxFramePayload
+	local.mu.Unlock()
+
+	_, err := local.Write(bytes.Repeat([]byte{1}, initialStreamWindow+maxFramePayload))
+	require.NoError(t, err)
+
+	// Reading extends the window, so the data is read only after the stream is reset.
+	assert.Eventually(t, func() bool { return server.numStreams() == 0 }, time.Second, 10*time.Millisecond)
+
+	_, err = io.ReadAll(remote)
+	assert.ErrorIs(t, err, ErrWindowExceeded)
+
+	assert.Eventually(t, func() bool {
+		_, err := local.Read(make([]byte, 1))
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = local.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrStreamReset)
+}
+
+func TestStream_HalfClose(t *testing.T) {
+	client, server, accepted := newSessionPair(t, VMux, 0)
+	local, remote := openStream(t, client, accepted, 1)
+
+	go func() {
+		_, _ = local.Write([]byte("request"))
+		_ = local.CloseWrite()
+	}()
+
+	// The peer reads the data sent before FIN and then io.EOF.
+	request, err := io.ReadAll(remote)
+	require.NoError(t, err)
+	assert.Equal(t, "request", string(request))
+
+	_, err = local.Write([]byte("more"))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+	// The other direction is still open.
+	go func() {
+		_, _ = remote.Write([]byte("response"))
+		_ = remote.CloseWrite()
+	}()
+
+	response, err := io.ReadAll(local)
+	require.NoError(t, err)
+	assert.Equal(t, "response", string(response))
+
+	// The stream is removed from both sessions when both directions are closed.
+	assert.Eventually(t, func() bool {
+		return client.numStreams() == 0 && server.numStreams() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStream_Reset(t *testing.T) {
+	client, server, accepted := newSessionPair(t, VMux, 0)
+	local, remote := openStream(t, client, accepted, 1)
+
+	require.NoError(t, remote.abort())
+
+	_, err := remote.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrStreamReset)
+
+	assert.Eventually(t, func() bool {
+		_, err := local.Read(make([]byte, 1))
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = local.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrStreamReset)
+
+	_, err = local.Write([]byte("data"))
+	assert.ErrorIs(t, err, ErrStreamReset)
+
+	assert.Equal(t, 0, client.numStreams())
+	assert.Equal(t, 0, server.numStreams())
+
+	// The session is still usable.
+	openStream(t, client, accepted, 2)
+}
+
+func TestStream_Close(t *testing.T) {
+	client, _, accepted := newSessionPair(t, VMux, 0)
+	local, remote := openStream(t, client, accepted, 1)
+
+	readErr := make(chan error, 1)
+
+	go func() {
+		_, err := local.Read(make([]byte, 1))
+		readErr <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, local.Close())
+
+	assert.ErrorIs(t, <-readErr, net.ErrClosed)
+
+	// The peer reads EOF, the data that it sends after that is rejected with RESET.
+	_, err := remote.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = remote.Write([]byte("data"))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, err := remote.Write([]byte("data"))
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSession_CloseUnblocksStreams(t *testing.T) {
+	client, _, accepted := newSessionPair(t, VMux, 0)
+
+	reader, _ := openStream(t, client, accepted, 1)
+	writer, _ := openStream(t, client, accepted, 2)
+
+	readErr := make(chan error, 1)
+	writeErr := make(chan error, 1)
+
+	go func() {
+		_, err := reader.Read(make([]byte, 1))
+		readErr <- err
+	}()
+
+	// The peer doesn't read, so the write blocks on the exhausted window.
+	go func() {
+		_, err := writer.Write(make([]byte, 2*initialStreamWindow))
+		writeErr <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, client.Close())
+
+	assert.ErrorIs(t, <-readErr, ErrSessionClosed)
+	assert.ErrorIs(t, <-writeErr, ErrSessionClosed)
+
+	_, err := client.open(3, nil)
+	assert.ErrorIs(t, err, ErrSessionClosed)
+	assert.False(t, client.isAlive())
+}
+
+func TestSession_PeerCloseResetsStreams(t *testing.T) {
+	client, server, accepted := newSessionPair(t, VMux, 0)
+	local, remote := openStream(t, client, accepted, 1)
+
+	require.NoError(t, server.Close())
+
+	_, err := remote.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrSessionClosed)
+
+	_, err = local.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrSessionClosed)
+
+	assert.Eventually(t, func() bool { return !client.isAlive() }, time.Second, 10*time.Millisecond)
+}
+
+func TestStream_Deadlines(t *testing.T) {
+	client, _, accepted := newSessionPair(t, VMux, 0)
+	local, remote := openStream(t, client, accepted, 1)
+
+	// The deadline in the past fails the read right away.
+	require.NoError(t, local.SetReadDeadline(time.Now().Add(-time.Second)))
+
+	_, err := local.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// The deadline can be changed while the read is blocked.
+	require.NoError(t, local.SetReadDeadline(time.Time{}))
+
+	readErr := make(chan error, 1)
+	start := time.Now()
+
+	go func() {
+		_, err := local.Read(make([]byte, 1))
+		readErr <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, local.SetDeadline(time.Now().Add(50*time.Millisecond)))
+
+	assert.ErrorIs(t, <-readErr, os.ErrDeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// Clearing the deadline makes the stream usable again.
+	require.NoError(t, local.SetDeadline(time.Time{}))
+
+	go func() {
+		_, _ = remote.Write([]byte("x"))
+	}()
+
+	buf := make([]byte, 1)
+	_, err = local.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "x", string(buf))
+}
+
+func TestSession_MaxStreams(t *testing.T) {
+	client, server, accepted := newSessionPair(t, VMux, 2)
+
+	_, first := openStream(t, client, accepted, 1)
+	openStream(t, client, accepted, 2)
+
+	// The stream above the limit is reset, it's never passed to the server.
+	refused, err := client.open(3, nil)
+	require.NoError(t, err)
+
+	_, err = refused.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrStreamReset)
+	assert.Empty(t, accepted)
+	assert.Equal(t, 2, server.numStreams())
+	assert.True(t, client.isAlive())
+
+	// The closed stream frees the slot.
+	require.NoError(t, first.abort())
+
+	assert.Eventually(t, func() bool { return server.numStreams() == 1 }, time.Second, 10*time.Millisecond)
+
+	openStream(t, client, accepted, 4)
+}
@@ -0,0 +1,79 @@
+package revconn
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+type Version byte
+
+// V2 extends V1 with the metadata of the connection.
+// VMux is the multiplexed mode of the protocol, many connections share one long-lived connection as streams.
+// VMux2 extends VMux with the metadata of the streams.
+const (
+	V1    Version = 1
+	V2    Version = 2
+	VMux  Version = 128
+	VMux2 Version = 129
+)
+
+type AuthMethod byte
+
+const (
+	NoAuth                 AuthMethod = 0
+	TokenAuth              AuthMethod = 1
+	NoAcceptableAuthMethod AuthMethod = 255
+)
+
+const (
+	connectionIDLenght   = 8
+	connectionInitLength = 2
+	tokenMACLength       = sha256.Size
+)
+
+// authMethodFor returns the authentication method that is used for the given token.
+// Empty token means that authentication is disabled.
+func authMethodFor(token string) AuthMethod {
+	if token == "" {
+		return NoAuth
+	}
+
+	return TokenAuth
+}
+
+// isMux reports whether the version is one of the multiplexed modes.
+func (v Version) isMux() bool {
+	return v == VMux || v == VMux2
+}
+
+// signConnectionID calculates HMAC-SHA256 of the connection id with the pre-shared token as a key.
+// The client proves knowledge of the token by sending the signature along with the connection id,
+// so the token itself never goes over the wire.
+func signConnectionID(token string, id uint64) []byte {
+	buf := make([]byte, connectionIDLenght)
+	binary.BigEndian.PutUint64(buf, id)
+
+	return sign(token, buf)
+}
+
+// sign calculates HMAC-SHA256 of the data with the pre-shared token as a key.
+func sign(token string, data []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// newMuxNonce generates the random challenge that the client signs to authenticate the mux session.
+func newMuxNonce() ([]byte, error) {
+	nonce := make([]byte, muxNonceLength)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return nonce, nil
+}
@@ -1,7 +1,7 @@
 package revconn
 
 import (
-	"encoding/binary"
+	"crypto/hmac"
 	"errors"
 	"fmt"
 	"io"
@@ -9,35 +9,143 @@ import (
 	"net"
 	"sync"
 	"syscall"
+	"time"
 )
 
 const (
-	maxConcurency = 25
+	defaultMaxConcurrency    = 25
+	defaultHandshakeTimeout  = 10 * time.Second
+	defaultMaxSessionStreams = 1024
 )
 
-type token struct{}
+// ConnInfo describes an accepted reverse connection.
+// Identity is the name of the token the client has authenticated with,
+// it's empty if the client is authenticated with the shared token or authentication is disabled.
+// Metadata is sent by the clients that use V2 or VMux2 versions of the protocol, it's nil otherwise.
+type ConnInfo struct {
+	Metadata Metadata
+	Identity string
+	ID       uint64
+}
 
-type OnConnectCB func(id uint64, conn net.Conn) error
+type OnConnectCB func(info ConnInfo, conn net.Conn) error
 
+// Server accepts reverse connections, the handshakes are performed by the bounded pool of workers.
+// Accepted connections wait for a worker in the queue of the same size as the pool,
+// the connections above that are closed right away, so the accept loop is never blocked by slow clients.
+// The handshake has to be completed within the handshake timeout counted from the accept.
 type Server struct {
-	onConnect OnConnectCB
-	sem       chan struct{}
+	onConnect        OnConnectCB
+	handshakes       chan net.Conn
+	tokens           map[string]string
+	sessions         map[*session]struct{}
+	pending          map[net.Conn]struct{}
+	maxConcurrency   int
+	maxStreams       int
+	handshakeTimeout time.Duration
+	mu               sync.Mutex
+}
+
+type ServerOption func(*Server)
+
+// WithServerToken sets the shared pre-shared token that clients have to prove knowledge of.
+// If any token is set, clients without token authentication are rejected.
+func WithServerToken(token string) ServerOption {
+	return func(s *Server) {
+		if token != "" {
+			s.tokens[""] = token
+		}
+	}
+}
+
+// WithServerTokens sets pre-shared tokens keyed by client identity.
+// The identity of the token that the client has proven knowledge of is passed to OnConnectCB.
+// If any token is set, clients without token authentication are rejected.
+func WithServerTokens(tokens map[string]string) ServerOption {
+	return func(s *Server) {
+		for identity, token := range tokens {
+			if token != "" {
+				s.tokens[identity] = token
+			}
+		}
+	}
+}
+
+// WithMaxConcurrency sets the number of handshakes that are performed concurrently and the number of
+// accepted connections that can wait for a handshake worker. Non-positive values keep the default of 25.
+func WithMaxConcurrency(n int) ServerOption {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxConcurrency = n
+		}
+	}
+}
+
+// WithHandshakeTimeout sets the time that the client has to complete the handshake after the connection is accepted.
+// Non-positive values keep the default of 10 seconds.
+func WithHandshakeTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		if d > 0 {
+			s.handshakeTimeout = d
+		}
+	}
+}
+
+// WithMaxSessionStreams sets the number of streams that can be open in a single mux session,
+// the streams above that are reset. Non-positive values keep the default of 1024.
+func WithMaxSessionStreams(n int) ServerOption {
+	return func(s *Server) {
+		if n > 0 {
+			s.maxStreams = n
+		}
+	}
 }
 
-func NewServer(cb OnConnectCB) *Server {
-	return &Server{
-		onConnect: cb,
-		sem:       make(chan struct{}, maxConcurency),
+func NewServer(cb OnConnectCB, opts ...ServerOption) *Server {
+	s := &Server{
+		onConnect:        cb,
+		tokens:           make(map[string]string),
+		sessions:         make(map[*session]struct{}),
+		pending:          make(map[net.Conn]struct{}),
+		maxConcurrency:   defaultMaxConcurrency,
+		maxStreams:       defaultMaxSessionStreams,
+		handshakeTimeout: defaultHandshakeTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	s.handshakes = make(chan net.Conn, s.maxConcurrency)
+
+	return s
 }
 
+// Serve accepts connections on the listener until it's closed, it can be called only once.
+// Pending handshakes and mux sessions are closed when Serve returns.
 func (s *Server) Serve(lis net.Listener) error {
 	wg := sync.WaitGroup{}
-	defer wg.Wait()
 
-	for {
-		s.sem <- token{}
+	for range s.maxConcurrency {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for conn := range s.handshakes {
+				s.handleConn(conn)
+			}
+		}()
+	}
 
+	defer func() {
+		close(s.handshakes)
+		s.closePending()
+		wg.Wait()
+		s.closeSessions()
+	}()
+
+	for {
 		conn, err := lis.Accept()
 		if err != nil {
 			if errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.EPIPE) {
@@ -47,21 +155,69 @@ func (s *Server) Serve(lis net.Listener) error {
 			return fmt.Errorf("failed to accept connection: %w", err)
 		}
 
-		wg.Add(1)
+		s.enqueue(conn)
+	}
+}
 
-		go func() {
-			defer func() {
-				<-s.sem
-				wg.Done()
-			}()
+// enqueue sets the handshake deadline and passes the connection to the handshake workers.
+// If all workers are busy and the queue is full, the connection is closed.
+func (s *Server) enqueue(conn net.Conn) {
+	if err := conn.SetDeadline(time.Now().Add(s.handshakeTimeout)); err != nil {
+		slog.Error("failed to set handshake deadline", slog.Any("error", err))
+		conn.Close()
 
-			s.handleConn(conn)
-		}()
+		return
+	}
+
+	s.mu.Lock()
+	s.pending[conn] = struct{}{}
+	s.mu.Unlock()
+
+	select {
+	case s.handshakes <- conn:
+	default:
+		slog.Warn("handshake queue is full, connection is rejected", slog.String("remote", conn.RemoteAddr().String()))
+		s.untrack(conn)
+		conn.Close()
+	}
+}
+
+// untrack removes the connection from the pending handshakes.
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.pending, conn)
+	s.mu.Unlock()
+}
+
+// handshakeDone removes the connection from the pending handshakes and clears its deadline
+// before the connection is handed off.
+func (s *Server) handshakeDone(conn net.Conn) error {
+	s.untrack(conn)
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("failed to clear handshake deadline: %w", err)
+	}
+
+	return nil
+}
+
+// closePending closes the connections that are waiting for the handshake or in the middle of it.
+func (s *Server) closePending() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.pending {
+		_ = conn.Close()
 	}
 }
 
+// handleConn performs the handshake and passes the connection to OnConnectCB,
+// the handshake deadline is cleared before the connection is handed off.
+// The callback runs in its own goroutine, so the handshake worker is free for the next connection.
 func (s *Server) handleConn(conn net.Conn) {
-	err := s.initialize(conn)
+	defer s.untrack(conn)
+
+	ver, err := s.initialize(conn)
 	if err != nil {
 		slog.Error("failed to initialize connection", slog.Any("error", err))
 		conn.Close()
@@ -69,7 +225,16 @@ func (s *Server) handleConn(conn net.Conn) {
 		return
 	}
 
-	id, err := s.getConnectionID(conn)
+	if ver.isMux() {
+		s.handleMux(conn, ver)
+		return
+	}
+
+	info, err := s.getConnectionInfo(conn, ver)
+	if err == nil {
+		err = s.handshakeDone(conn)
+	}
+
 	if err != nil {
 		slog.Error("failed to get connection id", slog.Any("error", err))
 		conn.Close()
@@ -77,67 +242,165 @@ func (s *Server) handleConn(conn net.Conn) {
 		return
 	}
 
-	if err = s.onConnect(id, conn); err != nil {
-		slog.Error("failed to handle connection", slog.Any("error", err))
-		conn.Close()
+	go func() {
+		if err := s.onConnect(info, conn); err != nil {
+			slog.Error("failed to handle connection", slog.Any("error", err))
+			conn.Close()
+		}
+	}()
+}
 
-		return
+// initialize reads the protocol version and the authentication method requested by the client
+// and replies with the same version and the authentication method of the server.
+// It returns the protocol version of the connection.
+func (s *Server) initialize(conn io.ReadWriter) (Version, error) {
+	req, err := readHello(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	expectedAuth := s.authMethod()
+
+	if req.auth != expectedAuth {
+		if _, err = conn.Write(hello{version: req.version, auth: NoAcceptableAuthMethod}.encode()); err != nil {
+			return 0, fmt.Errorf("failed to write protocol version and authentication method: %w", err)
+		}
+
+		return 0, fmt.Errorf("unsupported authentication method")
 	}
-}
 
-func (s *Server) initialize(conn io.ReadWriter) error {
-	buf := make([]byte, connectionInitLength)
-	n, err := conn.Read(buf)
+	if _, err = conn.Write(hello{version: req.version, auth: expectedAuth}.encode()); err != nil {
+		return 0, fmt.Errorf("failed to write protocol version and authentication method: %w", err)
+	}
+
+	return req.version, nil
+}
 
+// handleMux authenticates the mux session and serves it in the background.
+// Every stream opened by the client is passed to OnConnectCB as a separate connection,
+// the stream is reset if the callback fails.
+func (s *Server) handleMux(conn net.Conn, ver Version) {
+	identity, err := s.authenticateMux(conn)
 	if err != nil {
-		return fmt.Errorf("failed to read protocol version and authentication method: %w", err)
+		slog.Error("failed to authenticate mux session", slog.Any("error", err))
+		conn.Close()
+
+		return
 	}
 
-	if n != connectionInitLength {
-		return fmt.Errorf("invalid protocol version and authentication method")
+	if _, err = conn.Write([]byte{muxAccepted}); err == nil {
+		err = s.handshakeDone(conn)
 	}
 
-	ver := Version(buf[0])
-	authMethod := AuthMethod(buf[1])
+	if err != nil {
+		slog.Error("failed to accept mux session", slog.Any("error", err))
+		conn.Close()
 
-	if ver != V1 {
-		return fmt.Errorf("unsupported protocol version")
+		return
 	}
 
-	if authMethod != NoAuth {
-		if _, err = conn.Write([]byte{byte(V1), byte(NoAcceptableAuthMethod)}); err != nil {
-			return fmt.Errorf("failed to write protocol version and authentication method: %w", err)
+	sess := newSession(conn, ver, func(st *stream, id uint64, md Metadata) {
+		if err := s.onConnect(ConnInfo{Identity: identity, ID: id, Metadata: md}, st); err != nil {
+			slog.Error("failed to handle connection", slog.Any("error", err))
+
+			_ = st.abort()
 		}
+	})
+	sess.maxStreams = s.maxStreams
+
+	s.mu.Lock()
+	s.sessions[sess] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.sessions, sess)
+			s.mu.Unlock()
+		}()
 
-		return fmt.Errorf("unsupported authentication method")
-	}
+		if err := sess.serve(); err != nil {
+			slog.Debug("mux session is closed", slog.Any("error", err))
+		}
+	}()
+}
 
-	_, err = conn.Write([]byte{byte(V1), byte(NoAuth)})
+// authenticateMux sends the random nonce to the client and verifies its signature with one of the pre-shared tokens.
+// It returns the identity of the token. If token authentication is disabled, authenticateMux does nothing.
+func (s *Server) authenticateMux(conn io.ReadWriter) (string, error) {
+	if s.authMethod() != TokenAuth {
+		return "", nil
+	}
 
+	nonce, err := newMuxNonce()
 	if err != nil {
-		return fmt.Errorf("failed to write protocol version and authentication method: %w", err)
+		return "", err
 	}
 
-	return nil
+	if _, err := conn.Write(nonce); err != nil {
+		return "", fmt.Errorf("failed to write nonce: %w", err)
+	}
+
+	mac := make([]byte, tokenMACLength)
+	if _, err := io.ReadFull(conn, mac); err != nil {
+		return "", fmt.Errorf("failed to read authentication token: %w", err)
+	}
+
+	return s.authenticate(mac, nonce)
 }
 
-func (s *Server) getConnectionID(conn net.Conn) (uint64, error) {
-	buf := make([]byte, connectionIDLenght+1)
+// closeSessions closes all mux sessions.
+func (s *Server) closeSessions() {
+	s.mu.Lock()
+	sessions := make([]*session, 0, len(s.sessions))
+
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+
+	s.mu.Unlock()
 
-	n, err := conn.Read(buf)
+	for _, sess := range sessions {
+		_ = sess.Close()
+	}
+}
+
+// getConnectionInfo reads the connection request and authenticates the client.
+func (s *Server) getConnectionInfo(conn io.Reader, ver Version) (ConnInfo, error) {
+	req, err := readConnRequest(conn, ver, s.authMethod())
+	if err != nil {
+		return ConnInfo{}, err
+	}
 
+	identity, err := s.authenticate(req.mac, req.signed)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read connection id: %w", err)
+		return ConnInfo{}, err
 	}
 
-	if n != connectionIDLenght+1 {
-		return 0, fmt.Errorf("invalid connection id")
+	return ConnInfo{ID: req.id, Identity: identity, Metadata: req.metadata}, nil
+}
+
+// authMethod returns the authentication method that clients are required to use.
+func (s *Server) authMethod() AuthMethod {
+	if len(s.tokens) == 0 {
+		return NoAuth
 	}
 
-	ver := Version(buf[0])
-	if ver != V1 {
-		return 0, fmt.Errorf("unsupported protocol version")
+	return TokenAuth
+}
+
+// authenticate verifies that the client knows one of the pre-shared tokens and returns identity of that token.
+// mac is HMAC of the signed data that the client has sent. If token authentication is disabled, authenticate does nothing.
+func (s *Server) authenticate(mac, data []byte) (string, error) {
+	if s.authMethod() != TokenAuth {
+		return "", nil
+	}
+
+	for identity, token := range s.tokens {
+		if hmac.Equal(mac, sign(token, data)) {
+			return identity, nil
+		}
 	}
 
-	return binary.BigEndian.Uint64(buf[1:]), nil
+	return "", fmt.Errorf("invalid authentication token")
 }
@@ -0,0 +1,263 @@
+package revconn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Authenticate(t *testing.T) {
+	data := []byte("connection request")
+
+	srv := NewServer(nil, WithServerToken("shared"), WithServerTokens(map[string]string{"example": "token"}))
+
+	tests := []struct {
+		name     string
+		identity string
+		mac      []byte
+		wantErr  bool
+	}{
+		{name: "name space token", mac: sign("token", data), identity: "example"},
+		{name: "shared token", mac: sign("shared", data), identity: ""},
+		{name: "wrong token", mac: sign("wrong", data), wantErr: true},
+		{name: "signature of other data", mac: sign("token", []byte("other")), wantErr: true},
+		{name: "missing signature", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := srv.authenticate(tt.mac, data)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.identity, identity)
+		})
+	}
+}
+
+func TestServer_Authenticate_NoTokens(t *testing.T) {
+	srv := NewServer(nil, WithServerToken(""), WithServerTokens(map[string]string{"example": ""}))
+
+	assert.Equal(t, NoAuth, srv.authMethod())
+
+	identity, err := srv.authenticate(nil, []byte("connection request"))
+
+	assert.NoError(t, err)
+	assert.Empty(t, identity)
+}
+
+func TestSignConnectionID(t *testing.T) {
+	assert.Equal(t, sign("token", []byte{0, 0, 0, 0, 0, 0, 0, 42}), signConnectionID("token", 42))
+	assert.NotEqual(t, signConnectionID("token", 42), signConnectionID("other", 42))
+	assert.NotEqual(t, signConnectionID("token", 42), signConnectionID("token", 43))
+	assert.Len(t, signConnectionID("token", 42), tokenMACLength)
+}
+
+func TestHandshake_TokenAuth(t *testing.T) {
+	tests := []struct {
+		name         string
+		serverToken  string
+		clientToken  string
+		identity     string
+		ver          Version
+		wantAccepted bool
+		wantErr      bool
+	}{
+		{name: "correct token", serverToken: "token", clientToken: "token", ver: V1, wantAccepted: true, identity: "example"},
+		{name: "wrong token", serverToken: "token", clientToken: "wrong", ver: V1},
+		{name: "no auth to token server", serverToken: "token", ver: V1, wantErr: true},
+		{name: "token auth to no auth server", clientToken: "token", ver: V1, wantErr: true},
+		{name: "no auth to no auth server", ver: V1, wantAccepted: true},
+		{name: "mux correct token", serverToken: "token", clientToken: "token", ver: VMux, wantAccepted: true, identity: "example"},
+		{name: "mux wrong token", serverToken: "token", clientToken: "wrong", ver: VMux, wantErr: true},
+		{name: "mux no auth to token server", serverToken: "token", ver: VMux, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accepted := make(chan ConnInfo, 1)
+
+			srv := NewServer(func(info ConnInfo, _ net.Conn) error {
+				accepted <- info
+				return nil
+			}, WithServerTokens(map[string]string{"example": tt.serverToken}))
+			defer srv.closeSessions()
+
+			client := NewClient("", WithClientToken(tt.clientToken))
+
+			clientConn, serverConn := net.Pipe()
+			defer clientConn.Close()
+
+			go srv.handleConn(serverConn)
+
+			var err error
+
+			if tt.ver.isMux() {
+				if err = client.initializeMux(clientConn, tt.ver); err == nil {
+					sess := newSession(clientConn, tt.ver, nil)
+					defer sess.Close()
+
+					go func() { _ = sess.serve() }()
+
+					_, err = sess.open(42, nil)
+				}
+			} else {
+				err = client.initialize(clientConn, 42, tt.ver, nil)
+			}
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			if !tt.wantAccepted {
+				// V1 has no acknowledgement, the server closes the connection if the signature is invalid.
+				_ = clientConn.SetReadDeadline(time.Now().Add(time.Second))
+				_, err = clientConn.Read(make([]byte, 1))
+
+				assert.ErrorIs(t, err, io.EOF)
+				assert.Empty(t, accepted)
+
+				return
+			}
+
+			info := <-accepted
+			assert.Equal(t, uint64(42), info.ID)
+			assert.Equal(t, tt.identity, info.Identity)
+		})
+	}
+}
+
+// serve runs the server on the loopback interface and returns its address.
+func serve(t *testing.T, srv *Server) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_ = srv.Serve(lis)
+	}()
+
+	t.Cleanup(func() {
+		_ = lis.Close()
+		<-done
+	})
+
+	return lis.Addr().String()
+}
+
+// dialStalled connects to the server without sending the handshake.
+func dialStalled(t *testing.T, addr string) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+// closedWithin reports whether the server closes the connection within the timeout.
+func closedWithin(t *testing.T, conn net.Conn, timeout time.Duration) bool {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(timeout)))
+
+	_, err := conn.Read(make([]byte, 1))
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return false
+	}
+
+	require.ErrorIs(t, err, io.EOF)
+
+	return true
+}
+
+func TestServer_HandshakeTimeout(t *testing.T) {
+	srv := NewServer(func(ConnInfo, net.Conn) error { return nil }, WithHandshakeTimeout(100*time.Millisecond))
+	conn := dialStalled(t, serve(t, srv))
+
+	start := time.Now()
+
+	assert.True(t, closedWithin(t, conn, 5*time.Second))
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestServer_QueueFull(t *testing.T) {
+	srv := NewServer(func(ConnInfo, net.Conn) error { return nil }, WithMaxConcurrency(1))
+	addr := serve(t, srv)
+
+	// The stalled client occupies the only worker, the next one waits in the queue.
+	first := dialStalled(t, addr)
+
+	assert.Eventually(t, func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+
+		return len(srv.pending) == 1 && len(srv.handshakes) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	second := dialStalled(t, addr)
+
+	assert.Eventually(t, func() bool { return len(srv.handshakes) == 1 }, time.Second, 10*time.Millisecond)
+
+	// The connection above the capacity is closed right away instead of blocking the accept loop.
+	third := dialStalled(t, addr)
+	assert.True(t, closedWithin(t, third, time.Second))
+
+	assert.False(t, closedWithin(t, first, 50*time.Millisecond))
+	assert.False(t, closedWithin(t, second, 50*time.Millisecond))
+}
+
+func TestServer_OnConnectReleasesWorker(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	accepted := make(chan uint64, 2)
+
+	srv := NewServer(func(info ConnInfo, _ net.Conn) error {
+		accepted <- info.ID
+		<-release
+
+		return nil
+	}, WithMaxConcurrency(1))
+
+	client := NewClient(serve(t, srv))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The callback of the first connection blocks, the only worker still performs the next handshake.
+	for id := range uint64(2) {
+		conn, err := client.Connect(ctx, id)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { _ = conn.Close() })
+
+		select {
+		case got := <-accepted:
+			assert.Equal(t, id, got)
+		case <-ctx.Done():
+			require.FailNow(t, "connection is not passed to the callback")
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package revconn
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// stream is a logical connection of the mux session.
+// It implements net.Conn, CloseWrite closes only the sending direction of the stream.
+type stream struct {
+	sess          *session
+	err           error
+	readReady     chan struct{}
+	writeReady    chan struct{}
+	readDeadline  *deadline
+	writeDeadline *deadline
+	buf           []byte
+	sendWindow    int
+	recvWindow    int
+	consumed      int
+	mu            sync.Mutex
+	writeMu       sync.Mutex
+	id            uint32
+	finRecv       bool
+	finSent       bool
+	closed        bool
+}
+
+func newStream(sess *session, id uint32) *stream {
+	return &stream{
+		sess:          sess,
+		id:            id,
+		readReady:     make(chan struct{}, 1),
+		writeReady:    make(chan struct{}, 1),
+		readDeadline:  newDeadline(),
+		writeDeadline: newDeadline(),
+		sendWindow:    initialStreamWindow,
+		recvWindow:    initialStreamWindow,
+	}
+}
+
+// Read reads data from the stream.
+// The data that is received before the stream is reset or the session is closed can still be read.
+// It returns io.EOF after the peer has closed its sending direction.
+func (st *stream) Read(b []byte) (int, error) {
+	for {
+		st.mu.Lock()
+
+		if len(st.buf) > 0 {
+			n := copy(b, st.buf)
+			st.buf = st.buf[n:]
+			st.consumed += n
+
+			var update uint32
+			if st.consumed >= initialStreamWindow/2 && !st.finRecv && st.err == nil {
+				update = uint32(st.consumed) //nolint:gosec // consumed never exceeds initialStreamWindow
+				st.recvWindow += st.consumed
+				st.consumed = 0
+			}
+
+			st.mu.Unlock()
+
+			if update > 0 {
+				payload := make([]byte, windowUpdateLength)
+				binary.BigEndian.PutUint32(payload, update)
+
+				_ = st.sess.writeFrame(frameWindow, st.id, payload)
+			}
+
+			return n, nil
+		}
+
+		switch {
+		case st.closed:
+			st.mu.Unlock()
+			return 0, net.ErrClosed
+		case st.err != nil:
+			err := st.err
+			st.mu.Unlock()
+
+			return 0, err
+		case st.finRecv:
+			st.mu.Unlock()
+			return 0, io.EOF
+		}
+
+		st.mu.Unlock()
+
+		select {
+		case <-st.readReady:
+		case <-st.readDeadline.wait():
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// Write writes data to the stream, blocking while the flow control window of the peer is exhausted.
+func (st *stream) Write(b []byte) (int, error) {
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+
+	written := 0
+
+	for written < len(b) {
+		st.mu.Lock()
+
+		switch {
+		case st.closed:
+			st.mu.Unlock()
+			return written, net.ErrClosed
+		case st.err != nil:
+			err := st.err
+			st.mu.Unlock()
+
+			return written, err
+		case st.finSent:
+			st.mu.Unlock()
+			return written, io.ErrClosedPipe
+		}
+
+		n := min(len(b)-written, st.sendWindow, maxFramePayload)
+		st.sendWindow -= n
+		st.mu.Unlock()
+
+		if n == 0 {
+			select {
+			case <-st.writeReady:
+				continue
+			case <-st.writeDeadline.wait():
+				return written, os.ErrDeadlineExceeded
+			}
+		}
+
+		if err := st.sess.writeFrame(frameData, st.id, b[written:written+n]); err != nil {
+			return written, err
+		}
+
+		written += n
+	}
+
+	return written, nil
+}
+
+// CloseWrite closes the sending direction of the stream, the peer reads io.EOF after the remaining data.
+func (st *stream) CloseWrite() error {
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+
+	st.mu.Lock()
+
+	if st.finSent || st.closed || st.err != nil {
+		st.mu.Unlock()
+		return nil
+	}
+
+	st.finSent = true
+	done := st.finRecv
+	st.mu.Unlock()
+
+	if done {
+		st.sess.remove(st.id)
+	}
+
+	return st.sess.writeFrame(frameFin, st.id, nil)
+}
+
+// Close closes the stream.
+// The sending direction is closed gracefully, the data that the peer sends after that is rejected with RESET.
+// Pending reads and writes are unblocked and return net.ErrClosed.
+func (st *stream) Close() error {
+	st.mu.Lock()
+
+	if st.closed {
+		st.mu.Unlock()
+		return nil
+	}
+
+	st.closed = true
+	st.buf = nil
+	sendFin := !st.finSent && st.err == nil
+	st.finSent = true
+	st.mu.Unlock()
+
+	st.sess.remove(st.id)
+	st.notify()
+
+	if !sendFin {
+		return nil
+	}
+
+	// Wait for the pending write to give up, so FIN is the last frame of the stream.
+	st.writeMu.Lock()
+	defer st.writeMu.Unlock()
+
+	return st.sess.writeFrame(frameFin, st.id, nil)
+}
+
+// pushData appends the received data to the read buffer.
+// It returns an error if the peer has sent more data than the flow control window allows.
+func (st *stream) pushData(data []byte) error {
+	st.mu.Lock()
+
+	if len(data) > st.recvWindow {
+		st.mu.Unlock()
+		return ErrWindowExceeded
+	}
+
+	st.recvWindow -= len(data)
+
+	if !st.closed {
+		st.buf = append(st.buf, data...)
+	}
+
+	st.mu.Unlock()
+
+	st.notify()
+
+	return nil
+}
+
+// pushFin marks that the peer has closed its sending direction.
+func (st *stream) pushFin() {
+	st.mu.Lock()
+	st.finRecv = true
+	done := st.finSent
+	st.mu.Unlock()
+
+	if done {
+		st.sess.remove(st.id)
+	}
+
+	st.notify()
+}
+
+// extendWindow extends the flow control window of the peer.
+func (st *stream) extendWindow(n uint32) {
+	st.mu.Lock()
+	st.sendWindow += int(n)
+	st.mu.Unlock()
+
+	st.notify()
+}
+
+// reset aborts the stream with the given error.
+func (st *stream) reset(err error) {
+	st.mu.Lock()
+
+	if st.err == nil {
+		st.err = err
+	}
+
+	st.mu.Unlock()
+
+	st.sess.remove(st.id)
+	st.notify()
+}
+
+// abort resets the stream and tells the peer to reset it too.
+func (st *stream) abort() error {
+	st.reset(ErrStreamReset)
+	return st.sess.writeFrame(frameReset, st.id, nil)
+}
+
+// notify wakes up pending reads and writes.
+func (st *stream) notify() {
+	select {
+	case st.readReady <- struct{}{}:
+	default:
+	}
+
+	select {
+	case st.writeReady <- struct{}{}:
+	default:
+	}
+}
+
+func (st *stream) LocalAddr() net.Addr {
+	return st.sess.conn.LocalAddr()
+}
+
+func (st *stream) RemoteAddr() net.Addr {
+	return st.sess.conn.RemoteAddr()
+}
+
+func (st *stream) SetDeadline(t time.Time) error {
+	st.readDeadline.set(t)
+	st.writeDeadline.set(t)
+
+	return nil
+}
+
+func (st *stream) SetReadDeadline(t time.Time) error {
+	st.readDeadline.set(t)
+	return nil
+}
+
+func (st *stream) SetWriteDeadline(t time.Time) error {
+	st.writeDeadline.set(t)
+	return nil
+}
+
+// deadline is a deadline of stream reads or writes that can be changed while they are blocked.
+type deadline struct {
+	timer  *time.Timer
+	cancel chan struct{}
+	mu     sync.Mutex
+}
+
+func newDeadline() *deadline {
+	return &deadline{
+		cancel: make(chan struct{}),
+	}
+}
+
+// set sets the deadline, zero time means no deadline.
+func (d *deadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.cancel // Wait for the timer callback to close the channel
+	}
+
+	d.timer = nil
+
+	closed := isClosedChan(d.cancel)
+
+	if t.IsZero() {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+
+		return
+	}
+
+	if dur := time.Until(t); dur > 0 {
+		if closed {
+			d.cancel = make(chan struct{})
+		}
+
+		cancel := d.cancel
+		d.timer = time.AfterFunc(dur, func() {
+			close(cancel)
+		})
+
+		return
+	}
+
+	if !closed {
+		close(d.cancel)
+	}
+}
+
+// wait returns a channel that is closed when the deadline is exceeded.
+func (d *deadline) wait() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.cancel
+}
+
+func isClosedChan(c <-chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
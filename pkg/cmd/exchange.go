@@ -6,31 +6,109 @@ import (
 	"fmt"
 
 	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
 	"github.com/ksysoev/oneway/pkg/repo"
 	"github.com/ksysoev/oneway/pkg/svc/ctrlapi"
+	"github.com/ksysoev/oneway/pkg/svc/httpproxy"
+	"github.com/ksysoev/oneway/pkg/svc/peerapi"
 	"github.com/ksysoev/oneway/pkg/svc/proxy"
 	"github.com/ksysoev/oneway/pkg/svc/revconnapi"
 )
 
+// ExchaneConfig is the configuration of the exchange.
+// HTTPProxyAPI is optional, the HTTP proxy server is started only if it's configured.
+// PolicyFile is the path to the access control policy, if it's empty, all connection requests are allowed.
+// Addressing configures how the hosts requested by proxy clients are parsed into service addresses.
+// Cluster is optional, if it's configured, the exchange runs as a node of the cluster with other exchanges.
+// The clients of HTTPProxyAPI are authenticated as the users of ProxyAPI, if they are configured.
 type ExchaneConfig struct {
-	CtrlAPI  *ctrlapi.Config    `mapstructure:"ctrl_api"`
-	ConnAPI  *revconnapi.Config `mapstructure:"conn_api"`
-	ProxyAPI *proxy.Config      `mapstructure:"proxy_server"`
+	CtrlAPI       *ctrlapi.Config       `mapstructure:"ctrl_api"`
+	ConnAPI       *revconnapi.Config    `mapstructure:"conn_api"`
+	ProxyAPI      *proxy.Config         `mapstructure:"proxy_server"`
+	HTTPProxyAPI  *httpproxy.Config     `mapstructure:"http_proxy_server"`
+	Cluster       *peerapi.Config       `mapstructure:"cluster"`
+	LoadBalancing string                `mapstructure:"load_balancing"`
+	PolicyFile    string                `mapstructure:"policy_file"`
+	Addressing    network.AddressConfig `mapstructure:"addressing"`
+	Service       exchange.Config       `mapstructure:"service"`
 }
 
 func runExchange(ctx context.Context, cfg *ExchaneConfig) error {
-	ctx, cancel := context.WithCancel(ctx)
+	strategy, err := repo.ParseBalancingStrategy(cfg.LoadBalancing)
+	if err != nil {
+		return fmt.Errorf("failed to parse load balancing strategy: %w", err)
+	}
 
 	connQueue := repo.NewConnectionQueue()
-	revProxyRegistry := repo.NewRevProxyRegistry()
+	revProxyRegistry := repo.NewRevProxyRegistry(strategy)
+
+	var (
+		peers        exchange.PeerRepo
+		peerRegistry *repo.PeerRegistry
+	)
+
+	if cfg.Cluster != nil {
+		connQueue = repo.NewNodeConnectionQueue(cfg.Cluster.NodeID)
+		peerRegistry = repo.NewPeerRegistry()
+		peers = peerRegistry
+	}
+
+	var (
+		policies   exchange.PolicyRepo
+		policyFile *repo.PolicyFile
+	)
+
+	if cfg.PolicyFile != "" {
+		if policyFile, err = repo.NewPolicyFile(cfg.PolicyFile); err != nil {
+			return fmt.Errorf("failed to load policy: %w", err)
+		}
+
+		policies = policyFile
+	}
 
-	exchangeSvc := exchange.New(revProxyRegistry, connQueue)
+	exchangeSvc := exchange.New(&cfg.Service, revProxyRegistry, connQueue, policies, peers)
 
 	ctrlAPI := ctrlapi.New(cfg.CtrlAPI, exchangeSvc)
-	connAPI := revconnapi.New(cfg.ConnAPI, exchangeSvc)
-	sock5 := proxy.New(cfg.ProxyAPI, exchangeSvc)
 
-	const expectedErrs = 3
+	connAPI, err := revconnapi.New(cfg.ConnAPI, exchangeSvc)
+	if err != nil {
+		return fmt.Errorf("failed to create connection API: %w", err)
+	}
+
+	addrParser := network.NewAddressParser(&cfg.Addressing)
+
+	sock5, err := proxy.New(cfg.ProxyAPI, exchangeSvc, addrParser)
+	if err != nil {
+		return fmt.Errorf("failed to create proxy server: %w", err)
+	}
+
+	var peerAPI *peerapi.API
+
+	if cfg.Cluster != nil {
+		if peerAPI, err = peerapi.New(cfg.Cluster, exchangeSvc, peerRegistry); err != nil {
+			return fmt.Errorf("failed to create peer API: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// The connection APIs keep running while the exchange is drained,
+	// so the pending connection requests can still be fulfilled.
+	connCtx, cancelConn := context.WithCancel(context.Background())
+
+	expectedErrs := 4
+	if cfg.HTTPProxyAPI != nil {
+		expectedErrs++
+	}
+
+	if policyFile != nil {
+		expectedErrs++
+	}
+
+	if peerAPI != nil {
+		expectedErrs++
+	}
+
 	errs := make(chan error, expectedErrs)
 
 	go func() {
@@ -39,13 +117,48 @@ func runExchange(ctx context.Context, cfg *ExchaneConfig) error {
 	}()
 	go func() {
 		defer cancel()
-		errs <- connAPI.Run(ctx)
+		errs <- connAPI.Run(connCtx)
+	}()
+	go func() {
+		defer cancelConn()
+		<-ctx.Done()
+		exchangeSvc.Drain(context.Background())
+		errs <- nil
 	}()
 	go func() {
 		defer cancel()
 		errs <- sock5.Run(ctx)
 	}()
 
+	if cfg.HTTPProxyAPI != nil {
+		// The store is set only if it's not nil, so the HTTP proxy doesn't get the typed nil interface.
+		var users httpproxy.UserStore
+		if u := sock5.Users(); u != nil {
+			users = u
+		}
+
+		httpProxy := httpproxy.New(cfg.HTTPProxyAPI, exchangeSvc, addrParser, users)
+
+		go func() {
+			defer cancel()
+			errs <- httpProxy.Run(ctx)
+		}()
+	}
+
+	if policyFile != nil {
+		go func() {
+			defer cancel()
+			errs <- policyFile.Watch(ctx)
+		}()
+	}
+
+	if peerAPI != nil {
+		go func() {
+			defer cancel()
+			errs <- peerAPI.Run(connCtx)
+		}()
+	}
+
 	return collectErrs(errs, expectedErrs)
 }
 
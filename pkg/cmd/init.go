@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+const otelShutdownTimeout = 5 * time.Second
+
 func InitCommands() *cobra.Command {
 	var configPath string
 
@@ -34,12 +39,9 @@ func ExchangeCommand(cfgPath *string) *cobra.Command {
 				return fmt.Errorf("failed to inititialize config: %w", err)
 			}
 
-			err = InitOtel(cmd.Context(), cfg.Otel)
-			if err != nil {
-				return fmt.Errorf("failed to inititialize otel: %w", err)
-			}
-
-			return runExchange(cmd.Context(), cfg.Exchange)
+			return runWithOtel(cmd.Context(), cfg.Otel, func(ctx context.Context) error {
+				return runExchange(ctx, cfg.Exchange)
+			})
 		},
 	}
 }
@@ -55,12 +57,32 @@ func RevProxyCommand(cfgPath *string) *cobra.Command {
 				return fmt.Errorf("failed to inititialize config: %w", err)
 			}
 
-			err = InitOtel(cmd.Context(), cfg.Otel)
-			if err != nil {
-				return fmt.Errorf("failed to inititialize otel: %w", err)
-			}
-
-			return runRevProxy(cmd.Context(), cfg.RevProxy)
+			return runWithOtel(cmd.Context(), cfg.Otel, func(ctx context.Context) error {
+				return runRevProxy(ctx, cfg.RevProxy)
+			})
 		},
 	}
 }
+
+// runWithOtel runs the command with OpenTelemetry initialized.
+// The telemetry outlives the context of the command, so the logs and metrics of the shutdown are exported too.
+func runWithOtel(ctx context.Context, cfg *OtelConfig, run func(ctx context.Context) error) error {
+	otelCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	shutdown, err := InitOtel(otelCtx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to inititialize otel: %w", err)
+	}
+
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), otelShutdownTimeout)
+		defer cancel()
+
+		if err := shutdown(shutdownCtx); err != nil {
+			slog.Error("failed to shutdown otel", slog.Any("error", err))
+		}
+	}()
+
+	return run(ctx)
+}
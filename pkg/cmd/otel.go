@@ -48,7 +48,9 @@ type OtelConfig struct {
 	ServiceName string        `mapstructure:"service_name"`
 }
 
-func InitOtel(ctx context.Context, cfg *OtelConfig) error {
+// InitOtel sets up the OpenTelemetry providers, metrics are served until the context is done.
+// It returns the function that flushes and shuts down the providers.
+func InitOtel(ctx context.Context, cfg *OtelConfig) (func(context.Context) error, error) {
 	var (
 		shutdownFuncs []func(context.Context) error
 		err           error
@@ -81,7 +83,7 @@ func InitOtel(ctx context.Context, cfg *OtelConfig) error {
 	tracerProvider, err := newTraceProvider(cfg)
 	if err != nil {
 		handleErr(err)
-		return err
+		return nil, err
 	}
 
 	shutdownFuncs = append(shutdownFuncs, tracerProvider.Shutdown)
@@ -91,7 +93,7 @@ func InitOtel(ctx context.Context, cfg *OtelConfig) error {
 	meterProvider, err := newMeterProvider(cfg.Meter)
 	if err != nil {
 		handleErr(err)
-		return err
+		return nil, err
 	}
 
 	shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
@@ -109,21 +111,13 @@ func InitOtel(ctx context.Context, cfg *OtelConfig) error {
 	loggerProvider, err := newLoggerProvider(cfg)
 	if err != nil {
 		handleErr(err)
-		return err
+		return nil, err
 	}
 
 	shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
 	global.SetLoggerProvider(loggerProvider)
 
-	go func() {
-		<-ctx.Done()
-
-		if err := shutdown(ctx); err != nil {
-			slog.Error("failed to shutdown otel", slog.Any("error", err))
-		}
-	}()
-
-	return err
+	return shutdown, err
 }
 
 func newPropagator() propagation.TextMapPropagator {
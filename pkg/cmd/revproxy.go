@@ -2,23 +2,44 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/ksysoev/oneway/pkg/core/revconproxy"
 	"github.com/ksysoev/oneway/pkg/prov/bridge"
 	revsvc "github.com/ksysoev/oneway/pkg/svc/revconproxy"
 )
 
+var ErrInvalidConfig = fmt.Errorf("invalid config")
+
+// RevProxyConfig is the configuration of the revproxy.
+// CtrlAPI and ConnAPI are required, the address of the control API was moved
+// from revproxy.service.ctrl_api to revproxy.ctrl_api.address.
 type RevProxyConfig struct {
 	ConnAPI *bridge.Config `mapstructure:"conn_api"`
+	CtrlAPI *revsvc.Config `mapstructure:"ctrl_api"`
 	Service revconproxy.Config
 }
 
 func runRevProxy(ctx context.Context, cfg *RevProxyConfig) error {
-	bridgeProvider := bridge.New(cfg.ConnAPI)
+	if cfg.CtrlAPI == nil || cfg.CtrlAPI.Address == "" {
+		return fmt.Errorf(
+			"%w: revproxy.ctrl_api.address is required, the address of the control API was moved from revproxy.service.ctrl_api",
+			ErrInvalidConfig,
+		)
+	}
+
+	if cfg.ConnAPI == nil || cfg.ConnAPI.Address == "" {
+		return fmt.Errorf("%w: revproxy.conn_api.address is required", ErrInvalidConfig)
+	}
+
+	bridgeProvider, err := bridge.New(cfg.ConnAPI)
+	if err != nil {
+		return fmt.Errorf("failed to create bridge provider: %w", err)
+	}
 
 	svc := revconproxy.New(&cfg.Service, bridgeProvider)
 
-	revproxy := revsvc.New(svc, cfg.Service.CtrlAPI)
+	revproxy := revsvc.New(svc, cfg.CtrlAPI)
 
 	return revproxy.Run(ctx)
 }
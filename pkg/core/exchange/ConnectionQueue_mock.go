@@ -19,17 +19,17 @@ func (_m *MockConnectionQueue) EXPECT() *MockConnectionQueue_Expecter {
 	return &MockConnectionQueue_Expecter{mock: &_m.Mock}
 }
 
-// AddConnection provides a mock function with given fields: id, conn
-func (_m *MockConnectionQueue) AddConnection(id uint64, conn ConnResult) error {
-	ret := _m.Called(id, conn)
+// AddConnection provides a mock function with given fields: nameSpace, id, conn
+func (_m *MockConnectionQueue) AddConnection(nameSpace string, id uint64, conn ConnResult) error {
+	ret := _m.Called(nameSpace, id, conn)
 
 	if len(ret) == 0 {
 		panic("no return value specified for AddConnection")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(uint64, ConnResult) error); ok {
-		r0 = rf(id, conn)
+	if rf, ok := ret.Get(0).(func(string, uint64, ConnResult) error); ok {
+		r0 = rf(nameSpace, id, conn)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -43,15 +43,16 @@ type MockConnectionQueue_AddConnection_Call struct {
 }
 
 // AddConnection is a helper method to define mock.On call
+//   - nameSpace string
 //   - id uint64
 //   - conn ConnResult
-func (_e *MockConnectionQueue_Expecter) AddConnection(id interface{}, conn interface{}) *MockConnectionQueue_AddConnection_Call {
-	return &MockConnectionQueue_AddConnection_Call{Call: _e.mock.On("AddConnection", id, conn)}
+func (_e *MockConnectionQueue_Expecter) AddConnection(nameSpace interface{}, id interface{}, conn interface{}) *MockConnectionQueue_AddConnection_Call {
+	return &MockConnectionQueue_AddConnection_Call{Call: _e.mock.On("AddConnection", nameSpace, id, conn)}
 }
 
-func (_c *MockConnectionQueue_AddConnection_Call) Run(run func(id uint64, conn ConnResult)) *MockConnectionQueue_AddConnection_Call {
+func (_c *MockConnectionQueue_AddConnection_Call) Run(run func(nameSpace string, id uint64, conn ConnResult)) *MockConnectionQueue_AddConnection_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(uint64), args[1].(ConnResult))
+		run(args[0].(string), args[1].(uint64), args[2].(ConnResult))
 	})
 	return _c
 }
@@ -61,27 +62,37 @@ func (_c *MockConnectionQueue_AddConnection_Call) Return(_a0 error) *MockConnect
 	return _c
 }
 
-func (_c *MockConnectionQueue_AddConnection_Call) RunAndReturn(run func(uint64, ConnResult) error) *MockConnectionQueue_AddConnection_Call {
+func (_c *MockConnectionQueue_AddConnection_Call) RunAndReturn(run func(string, uint64, ConnResult) error) *MockConnectionQueue_AddConnection_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
-// AddRequest provides a mock function with given fields: connChan
-func (_m *MockConnectionQueue) AddRequest(connChan chan ConnResult) uint64 {
-	ret := _m.Called(connChan)
+// AddRequest provides a mock function with given fields: nameSpace, connChan
+func (_m *MockConnectionQueue) AddRequest(nameSpace string, connChan chan ConnResult) (uint64, error) {
+	ret := _m.Called(nameSpace, connChan)
 
 	if len(ret) == 0 {
 		panic("no return value specified for AddRequest")
 	}
 
 	var r0 uint64
-	if rf, ok := ret.Get(0).(func(chan ConnResult) uint64); ok {
-		r0 = rf(connChan)
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, chan ConnResult) (uint64, error)); ok {
+		return rf(nameSpace, connChan)
+	}
+	if rf, ok := ret.Get(0).(func(string, chan ConnResult) uint64); ok {
+		r0 = rf(nameSpace, connChan)
 	} else {
 		r0 = ret.Get(0).(uint64)
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(string, chan ConnResult) error); ok {
+		r1 = rf(nameSpace, connChan)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // MockConnectionQueue_AddRequest_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AddRequest'
@@ -90,24 +101,71 @@ type MockConnectionQueue_AddRequest_Call struct {
 }
 
 // AddRequest is a helper method to define mock.On call
+//   - nameSpace string
 //   - connChan chan ConnResult
-func (_e *MockConnectionQueue_Expecter) AddRequest(connChan interface{}) *MockConnectionQueue_AddRequest_Call {
-	return &MockConnectionQueue_AddRequest_Call{Call: _e.mock.On("AddRequest", connChan)}
+func (_e *MockConnectionQueue_Expecter) AddRequest(nameSpace interface{}, connChan interface{}) *MockConnectionQueue_AddRequest_Call {
+	return &MockConnectionQueue_AddRequest_Call{Call: _e.mock.On("AddRequest", nameSpace, connChan)}
+}
+
+func (_c *MockConnectionQueue_AddRequest_Call) Run(run func(nameSpace string, connChan chan ConnResult)) *MockConnectionQueue_AddRequest_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(chan ConnResult))
+	})
+	return _c
+}
+
+func (_c *MockConnectionQueue_AddRequest_Call) Return(_a0 uint64, _a1 error) *MockConnectionQueue_AddRequest_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockConnectionQueue_AddRequest_Call) RunAndReturn(run func(string, chan ConnResult) (uint64, error)) *MockConnectionQueue_AddRequest_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RemoveRequest provides a mock function with given fields: id
+func (_m *MockConnectionQueue) RemoveRequest(id uint64) bool {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveRequest")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(uint64) bool); ok {
+		r0 = rf(id)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// MockConnectionQueue_RemoveRequest_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RemoveRequest'
+type MockConnectionQueue_RemoveRequest_Call struct {
+	*mock.Call
+}
+
+// RemoveRequest is a helper method to define mock.On call
+//   - id uint64
+func (_e *MockConnectionQueue_Expecter) RemoveRequest(id interface{}) *MockConnectionQueue_RemoveRequest_Call {
+	return &MockConnectionQueue_RemoveRequest_Call{Call: _e.mock.On("RemoveRequest", id)}
 }
 
-func (_c *MockConnectionQueue_AddRequest_Call) Run(run func(connChan chan ConnResult)) *MockConnectionQueue_AddRequest_Call {
+func (_c *MockConnectionQueue_RemoveRequest_Call) Run(run func(id uint64)) *MockConnectionQueue_RemoveRequest_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(chan ConnResult))
+		run(args[0].(uint64))
 	})
 	return _c
 }
 
-func (_c *MockConnectionQueue_AddRequest_Call) Return(_a0 uint64) *MockConnectionQueue_AddRequest_Call {
+func (_c *MockConnectionQueue_RemoveRequest_Call) Return(_a0 bool) *MockConnectionQueue_RemoveRequest_Call {
 	_c.Call.Return(_a0)
 	return _c
 }
 
-func (_c *MockConnectionQueue_AddRequest_Call) RunAndReturn(run func(chan ConnResult) uint64) *MockConnectionQueue_AddRequest_Call {
+func (_c *MockConnectionQueue_RemoveRequest_Call) RunAndReturn(run func(uint64) bool) *MockConnectionQueue_RemoveRequest_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -0,0 +1,151 @@
+// Code generated by mockery v2.45.0. DO NOT EDIT.
+
+//go:build !compile
+
+package exchange
+
+import mock "github.com/stretchr/testify/mock"
+
+// MockPeerRepo is an autogenerated mock type for the PeerRepo type
+type MockPeerRepo struct {
+	mock.Mock
+}
+
+type MockPeerRepo_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockPeerRepo) EXPECT() *MockPeerRepo_Expecter {
+	return &MockPeerRepo_Expecter{mock: &_m.Mock}
+}
+
+// Find provides a mock function with given fields: nameSpace, service
+func (_m *MockPeerRepo) Find(nameSpace string, service string) (Peer, error) {
+	ret := _m.Called(nameSpace, service)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Find")
+	}
+
+	var r0 Peer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (Peer, error)); ok {
+		return rf(nameSpace, service)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) Peer); ok {
+		r0 = rf(nameSpace, service)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(Peer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(nameSpace, service)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPeerRepo_Find_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Find'
+type MockPeerRepo_Find_Call struct {
+	*mock.Call
+}
+
+// Find is a helper method to define mock.On call
+//   - nameSpace string
+//   - service string
+func (_e *MockPeerRepo_Expecter) Find(nameSpace interface{}, service interface{}) *MockPeerRepo_Find_Call {
+	return &MockPeerRepo_Find_Call{Call: _e.mock.On("Find", nameSpace, service)}
+}
+
+func (_c *MockPeerRepo_Find_Call) Run(run func(nameSpace string, service string)) *MockPeerRepo_Find_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockPeerRepo_Find_Call) Return(_a0 Peer, _a1 error) *MockPeerRepo_Find_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPeerRepo_Find_Call) RunAndReturn(run func(string, string) (Peer, error)) *MockPeerRepo_Find_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Node provides a mock function with given fields: id
+func (_m *MockPeerRepo) Node(id uint16) (Peer, bool) {
+	ret := _m.Called(id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Node")
+	}
+
+	var r0 Peer
+	var r1 bool
+	if rf, ok := ret.Get(0).(func(uint16) (Peer, bool)); ok {
+		return rf(id)
+	}
+	if rf, ok := ret.Get(0).(func(uint16) Peer); ok {
+		r0 = rf(id)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(Peer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(uint16) bool); ok {
+		r1 = rf(id)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
+// MockPeerRepo_Node_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Node'
+type MockPeerRepo_Node_Call struct {
+	*mock.Call
+}
+
+// Node is a helper method to define mock.On call
+//   - id uint16
+func (_e *MockPeerRepo_Expecter) Node(id interface{}) *MockPeerRepo_Node_Call {
+	return &MockPeerRepo_Node_Call{Call: _e.mock.On("Node", id)}
+}
+
+func (_c *MockPeerRepo_Node_Call) Run(run func(id uint16)) *MockPeerRepo_Node_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(uint16))
+	})
+	return _c
+}
+
+func (_c *MockPeerRepo_Node_Call) Return(_a0 Peer, _a1 bool) *MockPeerRepo_Node_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPeerRepo_Node_Call) RunAndReturn(run func(uint16) (Peer, bool)) *MockPeerRepo_Node_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockPeerRepo creates a new instance of MockPeerRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockPeerRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockPeerRepo {
+	mock := &MockPeerRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
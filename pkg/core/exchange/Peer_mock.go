@@ -0,0 +1,197 @@
+// Code generated by mockery v2.45.0. DO NOT EDIT.
+
+//go:build !compile
+
+package exchange
+
+import (
+	context "context"
+	net "net"
+
+	network "github.com/ksysoev/oneway/pkg/core/network"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockPeer is an autogenerated mock type for the Peer type
+type MockPeer struct {
+	mock.Mock
+}
+
+type MockPeer_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockPeer) EXPECT() *MockPeer_Expecter {
+	return &MockPeer_Expecter{mock: &_m.Mock}
+}
+
+// AddConnection provides a mock function with given fields: ctx, nameSpace, id, conn
+func (_m *MockPeer) AddConnection(ctx context.Context, nameSpace string, id uint64, conn net.Conn) error {
+	ret := _m.Called(ctx, nameSpace, id, conn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddConnection")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64, net.Conn) error); ok {
+		r0 = rf(ctx, nameSpace, id, conn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPeer_AddConnection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AddConnection'
+type MockPeer_AddConnection_Call struct {
+	*mock.Call
+}
+
+// AddConnection is a helper method to define mock.On call
+//   - ctx context.Context
+//   - nameSpace string
+//   - id uint64
+//   - conn net.Conn
+func (_e *MockPeer_Expecter) AddConnection(ctx interface{}, nameSpace interface{}, id interface{}, conn interface{}) *MockPeer_AddConnection_Call {
+	return &MockPeer_AddConnection_Call{Call: _e.mock.On("AddConnection", ctx, nameSpace, id, conn)}
+}
+
+func (_c *MockPeer_AddConnection_Call) Run(run func(ctx context.Context, nameSpace string, id uint64, conn net.Conn)) *MockPeer_AddConnection_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(uint64), args[3].(net.Conn))
+	})
+	return _c
+}
+
+func (_c *MockPeer_AddConnection_Call) Return(_a0 error) *MockPeer_AddConnection_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPeer_AddConnection_Call) RunAndReturn(run func(context.Context, string, uint64, net.Conn) error) *MockPeer_AddConnection_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewConnection provides a mock function with given fields: ctx, addr
+func (_m *MockPeer) NewConnection(ctx context.Context, addr *network.Address) (net.Conn, error) {
+	ret := _m.Called(ctx, addr)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewConnection")
+	}
+
+	var r0 net.Conn
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *network.Address) (net.Conn, error)); ok {
+		return rf(ctx, addr)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *network.Address) net.Conn); ok {
+		r0 = rf(ctx, addr)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(net.Conn)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *network.Address) error); ok {
+		r1 = rf(ctx, addr)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockPeer_NewConnection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'NewConnection'
+type MockPeer_NewConnection_Call struct {
+	*mock.Call
+}
+
+// NewConnection is a helper method to define mock.On call
+//   - ctx context.Context
+//   - addr *network.Address
+func (_e *MockPeer_Expecter) NewConnection(ctx interface{}, addr interface{}) *MockPeer_NewConnection_Call {
+	return &MockPeer_NewConnection_Call{Call: _e.mock.On("NewConnection", ctx, addr)}
+}
+
+func (_c *MockPeer_NewConnection_Call) Run(run func(ctx context.Context, addr *network.Address)) *MockPeer_NewConnection_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*network.Address))
+	})
+	return _c
+}
+
+func (_c *MockPeer_NewConnection_Call) Return(_a0 net.Conn, _a1 error) *MockPeer_NewConnection_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockPeer_NewConnection_Call) RunAndReturn(run func(context.Context, *network.Address) (net.Conn, error)) *MockPeer_NewConnection_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RejectConnection provides a mock function with given fields: ctx, nameSpace, id, reason
+func (_m *MockPeer) RejectConnection(ctx context.Context, nameSpace string, id uint64, reason error) error {
+	ret := _m.Called(ctx, nameSpace, id, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RejectConnection")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64, error) error); ok {
+		r0 = rf(ctx, nameSpace, id, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockPeer_RejectConnection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RejectConnection'
+type MockPeer_RejectConnection_Call struct {
+	*mock.Call
+}
+
+// RejectConnection is a helper method to define mock.On call
+//   - ctx context.Context
+//   - nameSpace string
+//   - id uint64
+//   - reason error
+func (_e *MockPeer_Expecter) RejectConnection(ctx interface{}, nameSpace interface{}, id interface{}, reason interface{}) *MockPeer_RejectConnection_Call {
+	return &MockPeer_RejectConnection_Call{Call: _e.mock.On("RejectConnection", ctx, nameSpace, id, reason)}
+}
+
+func (_c *MockPeer_RejectConnection_Call) Run(run func(ctx context.Context, nameSpace string, id uint64, reason error)) *MockPeer_RejectConnection_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(uint64), args[3].(error))
+	})
+	return _c
+}
+
+func (_c *MockPeer_RejectConnection_Call) Return(_a0 error) *MockPeer_RejectConnection_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPeer_RejectConnection_Call) RunAndReturn(run func(context.Context, string, uint64, error) error) *MockPeer_RejectConnection_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockPeer creates a new instance of MockPeer. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockPeer(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockPeer {
+	mock := &MockPeer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
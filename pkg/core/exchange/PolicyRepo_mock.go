@@ -0,0 +1,81 @@
+// Code generated by mockery v2.45.0. DO NOT EDIT.
+
+//go:build !compile
+
+package exchange
+
+import mock "github.com/stretchr/testify/mock"
+
+// MockPolicyRepo is an autogenerated mock type for the PolicyRepo type
+type MockPolicyRepo struct {
+	mock.Mock
+}
+
+type MockPolicyRepo_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockPolicyRepo) EXPECT() *MockPolicyRepo_Expecter {
+	return &MockPolicyRepo_Expecter{mock: &_m.Mock}
+}
+
+// Policy provides a mock function with given fields:
+func (_m *MockPolicyRepo) Policy() *Policy {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Policy")
+	}
+
+	var r0 *Policy
+	if rf, ok := ret.Get(0).(func() *Policy); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Policy)
+		}
+	}
+
+	return r0
+}
+
+// MockPolicyRepo_Policy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Policy'
+type MockPolicyRepo_Policy_Call struct {
+	*mock.Call
+}
+
+// Policy is a helper method to define mock.On call
+func (_e *MockPolicyRepo_Expecter) Policy() *MockPolicyRepo_Policy_Call {
+	return &MockPolicyRepo_Policy_Call{Call: _e.mock.On("Policy")}
+}
+
+func (_c *MockPolicyRepo_Policy_Call) Run(run func()) *MockPolicyRepo_Policy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockPolicyRepo_Policy_Call) Return(_a0 *Policy) *MockPolicyRepo_Policy_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockPolicyRepo_Policy_Call) RunAndReturn(run func() *Policy) *MockPolicyRepo_Policy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockPolicyRepo creates a new instance of MockPolicyRepo. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockPolicyRepo(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockPolicyRepo {
+	mock := &MockPolicyRepo{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
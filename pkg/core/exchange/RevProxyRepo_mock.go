@@ -19,9 +19,9 @@ func (_m *MockRevProxyRepo) EXPECT() *MockRevProxyRepo_Expecter {
 	return &MockRevProxyRepo_Expecter{mock: &_m.Mock}
 }
 
-// Find provides a mock function with given fields: nameSpace
-func (_m *MockRevProxyRepo) Find(nameSpace string) (*RevProxy, error) {
-	ret := _m.Called(nameSpace)
+// Find provides a mock function with given fields: nameSpace, service
+func (_m *MockRevProxyRepo) Find(nameSpace string, service string) (*RevProxy, error) {
+	ret := _m.Called(nameSpace, service)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Find")
@@ -29,19 +29,19 @@ func (_m *MockRevProxyRepo) Find(nameSpace string) (*RevProxy, error) {
 
 	var r0 *RevProxy
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (*RevProxy, error)); ok {
-		return rf(nameSpace)
+	if rf, ok := ret.Get(0).(func(string, string) (*RevProxy, error)); ok {
+		return rf(nameSpace, service)
 	}
-	if rf, ok := ret.Get(0).(func(string) *RevProxy); ok {
-		r0 = rf(nameSpace)
+	if rf, ok := ret.Get(0).(func(string, string) *RevProxy); ok {
+		r0 = rf(nameSpace, service)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*RevProxy)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(nameSpace)
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(nameSpace, service)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -56,13 +56,14 @@ type MockRevProxyRepo_Find_Call struct {
 
 // Find is a helper method to define mock.On call
 //   - nameSpace string
-func (_e *MockRevProxyRepo_Expecter) Find(nameSpace interface{}) *MockRevProxyRepo_Find_Call {
-	return &MockRevProxyRepo_Find_Call{Call: _e.mock.On("Find", nameSpace)}
+//   - service string
+func (_e *MockRevProxyRepo_Expecter) Find(nameSpace interface{}, service interface{}) *MockRevProxyRepo_Find_Call {
+	return &MockRevProxyRepo_Find_Call{Call: _e.mock.On("Find", nameSpace, service)}
 }
 
-func (_c *MockRevProxyRepo_Find_Call) Run(run func(nameSpace string)) *MockRevProxyRepo_Find_Call {
+func (_c *MockRevProxyRepo_Find_Call) Run(run func(nameSpace string, service string)) *MockRevProxyRepo_Find_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(string))
+		run(args[0].(string), args[1].(string))
 	})
 	return _c
 }
@@ -72,7 +73,7 @@ func (_c *MockRevProxyRepo_Find_Call) Return(_a0 *RevProxy, _a1 error) *MockRevP
 	return _c
 }
 
-func (_c *MockRevProxyRepo_Find_Call) RunAndReturn(run func(string) (*RevProxy, error)) *MockRevProxyRepo_Find_Call {
+func (_c *MockRevProxyRepo_Find_Call) RunAndReturn(run func(string, string) (*RevProxy, error)) *MockRevProxyRepo_Find_Call {
 	_c.Call.Return(run)
 	return _c
 }
@@ -110,6 +111,53 @@ func (_c *MockRevProxyRepo_Register_Call) RunAndReturn(run func(*RevProxy)) *Moc
 	return _c
 }
 
+// Services provides a mock function with given fields:
+func (_m *MockRevProxyRepo) Services() map[string][]string {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Services")
+	}
+
+	var r0 map[string][]string
+	if rf, ok := ret.Get(0).(func() map[string][]string); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]string)
+		}
+	}
+
+	return r0
+}
+
+// MockRevProxyRepo_Services_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Services'
+type MockRevProxyRepo_Services_Call struct {
+	*mock.Call
+}
+
+// Services is a helper method to define mock.On call
+func (_e *MockRevProxyRepo_Expecter) Services() *MockRevProxyRepo_Services_Call {
+	return &MockRevProxyRepo_Services_Call{Call: _e.mock.On("Services")}
+}
+
+func (_c *MockRevProxyRepo_Services_Call) Run(run func()) *MockRevProxyRepo_Services_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockRevProxyRepo_Services_Call) Return(_a0 map[string][]string) *MockRevProxyRepo_Services_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRevProxyRepo_Services_Call) RunAndReturn(run func() map[string][]string) *MockRevProxyRepo_Services_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Unregister provides a mock function with given fields: proxy
 func (_m *MockRevProxyRepo) Unregister(proxy *RevProxy) {
 	_m.Called(proxy)
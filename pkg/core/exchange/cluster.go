@@ -0,0 +1,169 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// nodeShift is the position of the node id in the connection request id.
+const nodeShift = 48
+
+// Peer is the other exchange node of the cluster.
+type Peer interface {
+	NewConnection(ctx context.Context, addr *network.Address) (net.Conn, error)
+	AddConnection(ctx context.Context, nameSpace string, id uint64, conn net.Conn) error
+	RejectConnection(ctx context.Context, nameSpace string, id uint64, reason error) error
+}
+
+// NodeRequestID returns the connection request id issued by the node, the node id is kept in the upper 16 bits.
+func NodeRequestID(node uint16, id uint64) uint64 {
+	return id&(1<<nodeShift-1) | uint64(node)<<nodeShift
+}
+
+// RequestNode returns the id of the node that has issued the connection request.
+func RequestNode(id uint64) uint16 {
+	return uint16(id >> nodeShift)
+}
+
+// NewPeerConnection creates the connection requested by the other node of the cluster
+// on behalf of the caller from the context.
+// The request is checked against the access control policy, the rate limits and the quotas of this node
+// the same way as in NewConnection, because the node can't rely on the checks done by the peer.
+// It's served only by the reverse proxies registered on this node, it's never forwarded further.
+// It fails with ErrDraining once the exchange is being drained.
+// It returns a net.Conn and an error.
+func (s *Service) NewPeerConnection(ctx context.Context, addr *network.Address) (net.Conn, error) {
+	ctx, span := tracer.Start(ctx, "Exchange.NewPeerConnection")
+	defer span.End()
+
+	if s.draining.Load() {
+		return nil, ErrDraining
+	}
+
+	if err := s.authorize(ctx, addr); err != nil {
+		return nil, err
+	}
+
+	limiters, cancel, err := s.rateLimit(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := s.acquireQuota(ctx, addr)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	proxy, err := s.revProxyRepo.Find(addr.NameSpace, addr.Service)
+	if err != nil {
+		release()
+		cancel()
+
+		return nil, fmt.Errorf("failed to get reverse connection proxy: %w", err)
+	}
+
+	conn, err := s.connect(ctx, proxy, addr)
+	if err != nil {
+		release()
+		cancel()
+
+		return nil, err
+	}
+
+	conn.limiters = limiters
+	conn.closeWith(release)
+
+	return conn, nil
+}
+
+// AddPeerConnection adds the reverse connection delivered by the other node of the cluster to the connection queue.
+// Unlike AddConnection, the connection is never delivered further.
+func (s *Service) AddPeerConnection(nameSpace string, id uint64, conn net.Conn) error {
+	return s.connQueue.AddConnection(nameSpace, id, ConnResult{
+		Conn: conn,
+	})
+}
+
+// RejectPeerConnection rejects the pending connection request on behalf of the other node of the cluster.
+// Unlike RejectConnection, the rejection is never delivered further.
+func (s *Service) RejectPeerConnection(nameSpace string, id uint64, reason error) error {
+	return s.connQueue.AddConnection(nameSpace, id, ConnResult{
+		Err: fmt.Errorf("%w: %w", ErrConnRejected, reason),
+	})
+}
+
+// Services returns the services of the reverse proxies registered on this node, keyed by name space.
+// The other nodes of the cluster route connection requests for these services to this node.
+func (s *Service) Services() map[string][]string {
+	return s.revProxyRepo.Services()
+}
+
+// forwardConnection requests the connection from the other node of the cluster,
+// that has the reverse proxy serving the service registered.
+// It waits for the connection up to the request TTL, the connection is counted as active until it's closed.
+func (s *Service) forwardConnection(ctx context.Context, peer Peer, addr *network.Address) (*trackedConn, error) {
+	trace.SpanFromContext(ctx).AddEvent("Forwarded to peer")
+
+	forwarded, _ := meter.Int64Counter("peer_forwarded_connections", metric.WithDescription("Number of connection requests forwarded to other nodes of the cluster"))
+	forwarded.Add(ctx, 1, metric.WithAttributes(attribute.String("namespace", addr.NameSpace)))
+
+	reqCtx, cancel := context.WithTimeout(ctx, s.requestTTL)
+	defer cancel()
+
+	conn, err := peer.NewConnection(reqCtx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request connection from peer: %w", s.requestError(ctx, err))
+	}
+
+	return s.track(conn, addr.NameSpace, addr.Service, nil), nil
+}
+
+// requestPeer returns the node of the cluster that has issued the connection request,
+// if the request is not found in the local connection queue.
+func (s *Service) requestPeer(id uint64, err error) (Peer, bool) {
+	if s.peers == nil || !errors.Is(err, ErrConnReqNotFound) {
+		return nil, false
+	}
+
+	return s.peers.Node(RequestNode(id))
+}
+
+// deliverConnection delivers the reverse connection to the node of the cluster that has issued the request.
+// The connection is counted as active until it's closed.
+func (s *Service) deliverConnection(peer Peer, nameSpace string, id uint64, conn net.Conn) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.requestTTL)
+	defer cancel()
+
+	tracked := s.track(conn, nameSpace, "", nil)
+
+	if err := peer.AddConnection(ctx, nameSpace, id, tracked); err != nil {
+		s.untrack(tracked)
+
+		return fmt.Errorf("failed to deliver connection to peer: %w", err)
+	}
+
+	slog.Debug("connection is delivered to peer", slog.Uint64("id", id), slog.Any("node", RequestNode(id)))
+
+	return nil
+}
+
+// rejectConnection delivers the rejection to the node of the cluster that has issued the request.
+func (s *Service) rejectConnection(peer Peer, nameSpace string, id uint64, reason error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.requestTTL)
+	defer cancel()
+
+	if err := peer.RejectConnection(ctx, nameSpace, id, reason); err != nil {
+		return fmt.Errorf("failed to deliver rejection to peer: %w", err)
+	}
+
+	return nil
+}
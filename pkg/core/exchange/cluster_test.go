@@ -0,0 +1,228 @@
+package exchange
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeRequestID(t *testing.T) {
+	id := NodeRequestID(7, 0xffff_1234_5678_9abc)
+
+	assert.Equal(t, uint64(0x0007_1234_5678_9abc), id)
+	assert.Equal(t, uint16(7), RequestNode(id))
+}
+
+func TestNewConnection_ForwardedToPeer(t *testing.T) {
+	addr := network.NewAddress("service1", "example")
+
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+	peers := NewMockPeerRepo(t)
+	peer := NewMockPeer(t)
+
+	service := New(&Config{}, revProxyRepo, connQueue, nil, peers)
+
+	mockConn, _ := net.Pipe()
+	defer mockConn.Close()
+
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(nil, ErrRevProxyNotFound)
+	peers.EXPECT().Find(addr.NameSpace, addr.Service).Return(peer, nil)
+	peer.EXPECT().NewConnection(mock.Anything, addr).Return(mockConn, nil)
+
+	conn, err := service.NewConnection(context.Background(), addr)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, service.ActiveConnections())
+
+	assert.NoError(t, conn.Close())
+	assert.Equal(t, 0, service.ActiveConnections())
+}
+
+func TestNewConnection_NotFoundInCluster(t *testing.T) {
+	addr := network.NewAddress("service1", "example")
+
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+	peers := NewMockPeerRepo(t)
+
+	service := New(&Config{}, revProxyRepo, connQueue, nil, peers)
+
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(nil, ErrServiceNotFound)
+	peers.EXPECT().Find(addr.NameSpace, addr.Service).Return(nil, ErrRevProxyNotFound)
+
+	conn, err := service.NewConnection(context.Background(), addr)
+
+	assert.ErrorIs(t, err, ErrServiceNotFound)
+	assert.Nil(t, conn)
+}
+
+func TestNewPeerConnection_NotForwarded(t *testing.T) {
+	addr := network.NewAddress("service1", "example")
+
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+	peers := NewMockPeerRepo(t)
+
+	service := New(&Config{}, revProxyRepo, connQueue, nil, peers)
+
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(nil, ErrRevProxyNotFound)
+
+	conn, err := service.NewPeerConnection(context.Background(), addr)
+
+	assert.ErrorIs(t, err, ErrRevProxyNotFound)
+	assert.Nil(t, conn)
+}
+
+func TestAddConnection_DeliveredToPeer(t *testing.T) {
+	id := NodeRequestID(2, 123)
+
+	tests := []struct {
+		queueErr error
+		wantErr  error
+		name     string
+		known    bool
+	}{
+		{
+			name:     "delivered to the node of the request",
+			queueErr: ErrConnReqNotFound,
+			known:    true,
+		},
+		{
+			name:     "unknown node",
+			queueErr: ErrConnReqNotFound,
+			wantErr:  ErrConnReqNotFound,
+		},
+		{
+			name:     "name space mismatch",
+			queueErr: ErrNameSpaceMismatch,
+			wantErr:  ErrNameSpaceMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revProxyRepo := NewMockRevProxyRepo(t)
+			connQueue := NewMockConnectionQueue(t)
+			peers := NewMockPeerRepo(t)
+			peer := NewMockPeer(t)
+
+			service := New(&Config{}, revProxyRepo, connQueue, nil, peers)
+
+			mockConn, _ := net.Pipe()
+			defer mockConn.Close()
+
+			connQueue.EXPECT().AddConnection("example", id, ConnResult{Conn: mockConn}).Return(tt.queueErr)
+
+			if tt.queueErr == ErrConnReqNotFound {
+				if tt.known {
+					peers.EXPECT().Node(uint16(2)).Return(peer, true)
+					peer.EXPECT().AddConnection(mock.Anything, "example", id, mock.Anything).Return(nil)
+				} else {
+					peers.EXPECT().Node(uint16(2)).Return(nil, false)
+				}
+			}
+
+			err := service.AddConnection("example", id, mockConn)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRejectConnection_DeliveredToPeer(t *testing.T) {
+	id := NodeRequestID(2, 123)
+
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+	peers := NewMockPeerRepo(t)
+	peer := NewMockPeer(t)
+
+	service := New(&Config{}, revProxyRepo, connQueue, nil, peers)
+
+	connQueue.EXPECT().AddConnection("example", id, mock.Anything).Return(ErrConnReqNotFound)
+	peers.EXPECT().Node(uint16(2)).Return(peer, true)
+	peer.EXPECT().RejectConnection(mock.Anything, "example", id, network.ErrConnRefused).Return(nil)
+
+	err := service.RejectConnection("example", id, network.ErrConnRefused)
+
+	assert.NoError(t, err)
+}
+
+func TestNewPeerConnection_AccessDenied(t *testing.T) {
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+	policies := NewMockPolicyRepo(t)
+
+	policy, err := NewPolicy(&PolicyConfig{
+		Rules: []RuleConfig{{Action: ActionAllow, Users: []string{"alice"}}},
+	})
+	require.NoError(t, err)
+
+	policies.EXPECT().Policy().Return(policy)
+
+	service := New(&Config{}, revProxyRepo, connQueue, policies, nil)
+
+	// The request of the peer is checked against the policy of this node.
+	conn, err := service.NewPeerConnection(WithCaller(context.Background(), Caller{User: "bob"}), network.NewAddress("restapi", "example"))
+
+	assert.ErrorIs(t, err, ErrAccessDenied)
+	assert.Nil(t, conn)
+
+	conn, err = service.NewPeerConnection(context.Background(), network.NewAddress("restapi", "example"))
+
+	assert.ErrorIs(t, err, ErrAccessDenied)
+	assert.Nil(t, conn)
+}
+
+func TestNewPeerConnection_Limits(t *testing.T) {
+	addr := network.NewAddress("service1", "example")
+
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+
+	cfg := &Config{
+		RateLimits: RateLimitConfig{
+			NameSpaces: map[string]NameSpaceRateLimit{
+				"example": {Services: map[string]network.RateLimit{"service1": {Connections: 1}}},
+			},
+		},
+		Quotas: QuotaConfig{
+			NameSpaces: map[string]NameSpaceQuota{
+				"other": {MaxConnections: 1},
+			},
+		},
+	}
+
+	service := New(cfg, revProxyRepo, connQueue, nil, nil)
+
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(nil, ErrRevProxyNotFound).Twice()
+
+	// The connection is returned to the limit when the request fails.
+	_, err := service.NewPeerConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrRevProxyNotFound)
+
+	_, err = service.NewPeerConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrRevProxyNotFound)
+
+	_, _, err = service.rateLimit(context.Background(), addr)
+	require.NoError(t, err)
+
+	_, err = service.NewPeerConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrRateLimited)
+
+	other := network.NewAddress("service1", "other")
+
+	release, err := service.acquireQuota(context.Background(), other)
+	require.NoError(t, err)
+
+	defer release()
+
+	_, err = service.NewPeerConnection(context.Background(), other)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+}
@@ -0,0 +1,55 @@
+package exchange
+
+import (
+	"net"
+	"sync"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+)
+
+// trackedConn is a connection that notifies about its closing.
+// The name space and the service describe the destination of the connection for the drain report.
+// The bandwidth limiters are applied by the bridge of the connection.
+type trackedConn struct {
+	net.Conn
+	onClose   func()
+	nameSpace string
+	service   string
+	limiters  []*network.RateLimiter
+	once      sync.Once
+}
+
+// newTrackedConn wraps the connection, onClose is called once when the connection is closed.
+func newTrackedConn(conn net.Conn, onClose func()) *trackedConn {
+	return &trackedConn{
+		Conn:    conn,
+		onClose: onClose,
+	}
+}
+
+// closeWith adds the callback that is called once when the connection is closed.
+// It must be called before the connection is used.
+func (c *trackedConn) closeWith(fn func()) {
+	onClose := c.onClose
+	c.onClose = func() {
+		onClose()
+		fn()
+	}
+}
+
+// Close closes the underlying connection and calls onClose callback on the first call.
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+
+	return c.Conn.Close()
+}
+
+// CloseWrite closes the sending direction of the underlying connection, if it supports half-close.
+func (c *trackedConn) CloseWrite() error {
+	return network.CloseWrite(c.Conn)
+}
+
+// RateLimiters returns the bandwidth limiters of the connection.
+func (c *trackedConn) RateLimiters() []*network.RateLimiter {
+	return c.limiters
+}
@@ -0,0 +1,114 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var ErrDraining = fmt.Errorf("exchange is draining")
+
+const drainPollInterval = 100 * time.Millisecond
+
+// track wraps the connection, so it's counted as active until it's closed and it can be cut when the exchange is drained.
+// onClose is called once when the connection is closed, it can be nil.
+func (s *Service) track(conn net.Conn, nameSpace, service string, onClose func()) *trackedConn {
+	tracked := newTrackedConn(conn, nil)
+	tracked.nameSpace = nameSpace
+	tracked.service = service
+	tracked.onClose = func() {
+		s.untrack(tracked)
+
+		if onClose != nil {
+			onClose()
+		}
+	}
+
+	s.connsMu.Lock()
+	s.conns[tracked] = struct{}{}
+	s.connsMu.Unlock()
+
+	return tracked
+}
+
+// untrack stops counting the connection as active.
+func (s *Service) untrack(conn *trackedConn) {
+	s.connsMu.Lock()
+	delete(s.conns, conn)
+	s.connsMu.Unlock()
+}
+
+// ActiveConnections returns the number of connections that are established through the exchange and not closed yet.
+func (s *Service) ActiveConnections() int {
+	s.connsMu.Lock()
+	defer s.connsMu.Unlock()
+
+	return len(s.conns)
+}
+
+// Drain stops accepting new connection requests and waits for the active connections to be closed
+// up to the grace period or until the context is done, then it closes the remaining connections.
+// The cut connections are logged and counted by the drain_cut_connections metric.
+// It returns the number of cut connections.
+func (s *Service) Drain(ctx context.Context) int {
+	s.draining.Store(true)
+
+	ctx, cancel := context.WithTimeout(ctx, s.gracePeriod)
+	defer cancel()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	slog.InfoContext(ctx, "exchange is draining", slog.Int("active_connections", s.ActiveConnections()), slog.Duration("grace_period", s.gracePeriod))
+
+	for s.ActiveConnections() > 0 {
+		select {
+		case <-ctx.Done():
+			return s.cutConnections()
+		case <-ticker.C:
+		}
+	}
+
+	slog.Info("exchange is drained")
+
+	return 0
+}
+
+// cutConnections closes all active connections and reports them by their destinations.
+func (s *Service) cutConnections() int {
+	s.connsMu.Lock()
+	conns := make([]*trackedConn, 0, len(s.conns))
+
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+
+	s.connsMu.Unlock()
+
+	cutCounter, _ := meter.Int64Counter("drain_cut_connections", metric.WithDescription("Number of connections closed after the drain grace period"))
+	cut := make(map[string]int)
+
+	for _, conn := range conns {
+		if err := conn.Close(); err != nil {
+			slog.Debug("failed to close connection", slog.Any("error", err))
+		}
+
+		cutCounter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("namespace", conn.nameSpace), attribute.String("service", conn.service)))
+
+		dest := conn.nameSpace
+		if conn.service != "" {
+			dest = conn.service + "." + conn.nameSpace
+		}
+
+		cut[dest]++
+	}
+
+	slog.Warn("connections are cut after the grace period", slog.Int("connections", len(conns)), slog.Any("destinations", cut))
+
+	return len(conns)
+}
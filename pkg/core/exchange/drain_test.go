@@ -0,0 +1,63 @@
+package exchange
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrain_NoConnections(t *testing.T) {
+	service := New(&Config{}, NewMockRevProxyRepo(t), NewMockConnectionQueue(t), nil, nil)
+
+	start := time.Now()
+
+	assert.Equal(t, 0, service.Drain(context.Background()))
+	assert.Less(t, time.Since(start), time.Second)
+
+	conn, err := service.NewConnection(context.Background(), network.NewAddress("service1", "example"))
+
+	assert.ErrorIs(t, err, ErrDraining)
+	assert.Nil(t, conn)
+}
+
+func TestDrain_ConnectionClosed(t *testing.T) {
+	service := New(&Config{GracePeriod: 5 * time.Second}, NewMockRevProxyRepo(t), NewMockConnectionQueue(t), nil, nil)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	var released atomic.Bool
+
+	conn := service.track(local, "example", "service1", func() { released.Store(true) })
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		conn.Close()
+	}()
+
+	assert.Equal(t, 0, service.Drain(context.Background()))
+	assert.True(t, released.Load())
+}
+
+func TestDrain_ConnectionCut(t *testing.T) {
+	service := New(&Config{GracePeriod: 200 * time.Millisecond}, NewMockRevProxyRepo(t), NewMockConnectionQueue(t), nil, nil)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	var released atomic.Bool
+
+	service.track(local, "example", "service1", func() { released.Store(true) })
+
+	assert.Equal(t, 1, service.Drain(context.Background()))
+	assert.True(t, released.Load())
+	assert.Equal(t, 0, service.ActiveConnections())
+
+	_, err := remote.Write([]byte("data"))
+	assert.Error(t, err)
+}
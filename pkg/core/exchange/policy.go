@@ -0,0 +1,423 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	ErrAccessDenied  = fmt.Errorf("access denied by policy")
+	ErrInvalidPolicy = fmt.Errorf("invalid policy")
+)
+
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+	anyTarget   = "*"
+	clockFormat = "15:04"
+)
+
+// PolicyConfig is the access control policy for connection requests.
+// Rules are evaluated in order and the first matching rule decides, if no rule matches, the Default action is applied,
+// it's deny if it's not set.
+type PolicyConfig struct {
+	Default string       `mapstructure:"default"`
+	Rules   []RuleConfig `mapstructure:"rules"`
+}
+
+// RuleConfig is the rule of the access control policy.
+// The rule matches the connection request if the caller matches all non-empty caller criteria,
+// the target matches one of Targets and the request is made within the Schedule.
+// Users are the names of the proxy users, Certificates are the common names of client certificates,
+// Sources are the CIDRs or IP addresses of the callers.
+// Targets are namespaces, service.namespace or "*" for any service, empty Targets match any service.
+type RuleConfig struct {
+	Schedule     *ScheduleConfig `mapstructure:"schedule"`
+	Name         string          `mapstructure:"name"`
+	Action       string          `mapstructure:"action"`
+	Users        []string        `mapstructure:"users"`
+	Certificates []string        `mapstructure:"certificates"`
+	Sources      []string        `mapstructure:"sources"`
+	Targets      []string        `mapstructure:"targets"`
+}
+
+// ScheduleConfig is the time window when the rule is active.
+// Days are the days of the week (mon, tue, ...), empty Days mean every day.
+// From and To are the time of the day in the HH:MM format, the window may wrap around midnight,
+// if both are empty, the rule is active all day. Timezone is the IANA name of the time zone, UTC by default.
+type ScheduleConfig struct {
+	From     string   `mapstructure:"from"`
+	To       string   `mapstructure:"to"`
+	Timezone string   `mapstructure:"timezone"`
+	Days     []string `mapstructure:"days"`
+}
+
+// Caller is the identity of the client that requests the connection.
+type Caller struct {
+	Source      netip.Addr
+	User        string
+	Certificate string
+}
+
+type callerKey struct{}
+
+// WithCaller returns the context that carries the identity of the caller.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the identity of the caller from the context.
+// The second return value is false if the context carries no caller.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+// Decision is the result of the policy evaluation, Rule is the name of the matched rule,
+// it's empty if the default action is applied.
+type Decision struct {
+	Rule    string
+	Allowed bool
+}
+
+// Policy is the compiled access control policy.
+type Policy struct {
+	rules        []rule
+	defaultAllow bool
+}
+
+type rule struct {
+	schedule     *schedule
+	users        map[string]struct{}
+	certificates map[string]struct{}
+	targets      map[string]struct{}
+	name         string
+	sources      []netip.Prefix
+	allow        bool
+}
+
+type schedule struct {
+	location *time.Location
+	days     map[time.Weekday]struct{}
+	from     time.Duration
+	to       time.Duration
+	allDay   bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// NewPolicy compiles the access control policy from the configuration.
+// It returns ErrInvalidPolicy if the configuration is invalid.
+func NewPolicy(cfg *PolicyConfig) (*Policy, error) {
+	defaultAllow, err := parseAction(cfg.Default, ActionDeny)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Policy{
+		defaultAllow: defaultAllow,
+		rules:        make([]rule, 0, len(cfg.Rules)),
+	}
+
+	for i, rc := range cfg.Rules {
+		r, err := newRule(&rc)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d %q: %w", i, rc.Name, err)
+		}
+
+		p.rules = append(p.rules, r)
+	}
+
+	return p, nil
+}
+
+func newRule(cfg *RuleConfig) (rule, error) {
+	allow, err := parseAction(cfg.Action, "")
+	if err != nil {
+		return rule{}, err
+	}
+
+	r := rule{
+		name:         cfg.Name,
+		allow:        allow,
+		users:        toSet(cfg.Users),
+		certificates: toSet(cfg.Certificates),
+		targets:      toSet(cfg.Targets),
+	}
+
+	for _, src := range cfg.Sources {
+		prefix, err := parseSource(src)
+		if err != nil {
+			return rule{}, err
+		}
+
+		r.sources = append(r.sources, prefix)
+	}
+
+	if cfg.Schedule != nil {
+		if r.schedule, err = newSchedule(cfg.Schedule); err != nil {
+			return rule{}, err
+		}
+	}
+
+	return r, nil
+}
+
+func parseAction(action, defaultAction string) (bool, error) {
+	if action == "" {
+		action = defaultAction
+	}
+
+	switch strings.ToLower(action) {
+	case ActionAllow:
+		return true, nil
+	case ActionDeny:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: unknown action %q", ErrInvalidPolicy, action)
+	}
+}
+
+// parseSource parses the CIDR or the single IP address.
+func parseSource(src string) (netip.Prefix, error) {
+	if strings.Contains(src, "/") {
+		prefix, err := netip.ParsePrefix(src)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%w: invalid source %q: %w", ErrInvalidPolicy, src, err)
+		}
+
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(src)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%w: invalid source %q: %w", ErrInvalidPolicy, src, err)
+	}
+
+	addr = addr.Unmap()
+
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func newSchedule(cfg *ScheduleConfig) (*schedule, error) {
+	s := &schedule{
+		location: time.UTC,
+		days:     make(map[time.Weekday]struct{}, len(cfg.Days)),
+		allDay:   cfg.From == "" && cfg.To == "",
+	}
+
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid timezone %q: %w", ErrInvalidPolicy, cfg.Timezone, err)
+		}
+
+		s.location = loc
+	}
+
+	for _, day := range cfg.Days {
+		wd, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid day %q", ErrInvalidPolicy, day)
+		}
+
+		s.days[wd] = struct{}{}
+	}
+
+	if s.allDay {
+		return s, nil
+	}
+
+	var err error
+
+	if s.from, err = parseClock(cfg.From); err != nil {
+		return nil, err
+	}
+
+	if s.to, err = parseClock(cfg.To); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// parseClock parses the time of the day in the HH:MM format and returns it as the duration since midnight.
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse(clockFormat, clock)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid time of the day %q: %w", ErrInvalidPolicy, clock, err)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func toSet(items []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+
+	return set
+}
+
+// Evaluate evaluates the policy for the connection request of the caller to the address at the given time.
+func (p *Policy) Evaluate(caller Caller, addr *network.Address, now time.Time) Decision {
+	for i := range p.rules {
+		if p.rules[i].matches(caller, addr, now) {
+			return Decision{Allowed: p.rules[i].allow, Rule: p.rules[i].name}
+		}
+	}
+
+	return Decision{Allowed: p.defaultAllow}
+}
+
+func (r *rule) matches(caller Caller, addr *network.Address, now time.Time) bool {
+	return matchSet(r.users, caller.User) &&
+		matchSet(r.certificates, caller.Certificate) &&
+		r.matchSource(caller.Source) &&
+		r.matchTarget(addr) &&
+		r.schedule.active(now)
+}
+
+func matchSet(set map[string]struct{}, value string) bool {
+	if len(set) == 0 {
+		return true
+	}
+
+	_, ok := set[value]
+
+	return ok
+}
+
+func (r *rule) matchSource(src netip.Addr) bool {
+	if len(r.sources) == 0 {
+		return true
+	}
+
+	src = src.Unmap()
+
+	for _, prefix := range r.sources {
+		if prefix.Contains(src) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *rule) matchTarget(addr *network.Address) bool {
+	if len(r.targets) == 0 {
+		return true
+	}
+
+	for _, target := range []string{anyTarget, addr.NameSpace, addr.String()} {
+		if _, ok := r.targets[target]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// active reports whether the time is within the schedule, nil schedule is always active.
+func (s *schedule) active(now time.Time) bool {
+	if s == nil {
+		return true
+	}
+
+	now = now.In(s.location)
+
+	day := now.Weekday()
+	clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+
+	if !s.allDay && s.from > s.to && clock < s.to {
+		// The window wraps around midnight, the time after midnight belongs to the window of the previous day.
+		day = now.AddDate(0, 0, -1).Weekday()
+	}
+
+	if len(s.days) > 0 {
+		if _, ok := s.days[day]; !ok {
+			return false
+		}
+	}
+
+	switch {
+	case s.allDay:
+		return true
+	case s.from <= s.to:
+		return clock >= s.from && clock < s.to
+	default:
+		return clock >= s.from || clock < s.to
+	}
+}
+
+// authorize evaluates the access control policy for the connection request of the caller from the context.
+// Every decision is written to the audit log.
+// It returns ErrAccessDenied if the request is denied, all requests are allowed if there is no policy.
+func (s *Service) authorize(ctx context.Context, addr *network.Address) error {
+	if s.policies == nil {
+		return nil
+	}
+
+	policy := s.policies.Policy()
+	if policy == nil {
+		return nil
+	}
+
+	caller, _ := CallerFromContext(ctx)
+	decision := policy.Evaluate(caller, addr, time.Now())
+
+	action := ActionDeny
+	if decision.Allowed {
+		action = ActionAllow
+	}
+
+	decisions, _ := meter.Int64Counter("policy_decisions", metric.WithDescription("Number of access policy decisions"))
+	decisions.Add(ctx, 1, metric.WithAttributes(attribute.String("decision", action), attribute.String("namespace", addr.NameSpace)))
+
+	slog.InfoContext(ctx, "access policy decision",
+		slog.String("audit", "policy"),
+		slog.String("decision", action),
+		slog.String("rule", decision.Rule),
+		slog.String("target", addr.String()),
+		slog.String("user", caller.User),
+		slog.String("certificate", caller.Certificate),
+		slog.String("source", caller.Source.String()),
+	)
+
+	if !decision.Allowed {
+		return fmt.Errorf("%w: %s is not allowed to connect to %s", ErrAccessDenied, callerName(caller), addr)
+	}
+
+	return nil
+}
+
+// callerName returns the most specific identity of the caller for error messages.
+func callerName(caller Caller) string {
+	switch {
+	case caller.User != "":
+		return caller.User
+	case caller.Certificate != "":
+		return caller.Certificate
+	case caller.Source.IsValid():
+		return caller.Source.String()
+	default:
+		return "unknown caller"
+	}
+}
@@ -0,0 +1,244 @@
+package exchange
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Evaluate(t *testing.T) {
+	policy, err := NewPolicy(&PolicyConfig{
+		Rules: []RuleConfig{
+			{
+				Name:    "deny-blocked-network",
+				Action:  ActionDeny,
+				Sources: []string{"192.168.100.0/24"},
+			},
+			{
+				Name:    "alice-example",
+				Action:  ActionAllow,
+				Users:   []string{"alice"},
+				Targets: []string{"example"},
+			},
+			{
+				Name:         "backend-restapi",
+				Action:       ActionAllow,
+				Certificates: []string{"backend"},
+				Sources:      []string{"10.0.0.0/8", "127.0.0.1"},
+				Targets:      []string{"restapi.other"},
+			},
+			{
+				Name:    "night-jobs",
+				Action:  ActionAllow,
+				Users:   []string{"batch"},
+				Targets: []string{"*"},
+				Schedule: &ScheduleConfig{
+					Days: []string{"mon", "Tue"},
+					From: "22:00",
+					To:   "06:00",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	monday := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		now      time.Time
+		addr     *network.Address
+		caller   Caller
+		name     string
+		rule     string
+		expected bool
+	}{
+		{
+			name:     "user allowed to namespace",
+			caller:   Caller{User: "alice"},
+			addr:     network.NewAddress("restapi", "example"),
+			now:      monday,
+			expected: true,
+			rule:     "alice-example",
+		},
+		{
+			name:   "user denied to other namespace",
+			caller: Caller{User: "alice"},
+			addr:   network.NewAddress("restapi", "other"),
+			now:    monday,
+		},
+		{
+			name:   "first matching rule wins",
+			caller: Caller{User: "alice", Source: netip.MustParseAddr("192.168.100.5")},
+			addr:   network.NewAddress("restapi", "example"),
+			now:    monday,
+			rule:   "deny-blocked-network",
+		},
+		{
+			name:     "certificate and source match",
+			caller:   Caller{Certificate: "backend", Source: netip.MustParseAddr("10.1.2.3")},
+			addr:     network.NewAddress("restapi", "other"),
+			now:      monday,
+			expected: true,
+			rule:     "backend-restapi",
+		},
+		{
+			name:     "single address source",
+			caller:   Caller{Certificate: "backend", Source: netip.MustParseAddr("::ffff:127.0.0.1")},
+			addr:     network.NewAddress("restapi", "other"),
+			now:      monday,
+			expected: true,
+			rule:     "backend-restapi",
+		},
+		{
+			name:   "certificate from unknown source",
+			caller: Caller{Certificate: "backend", Source: netip.MustParseAddr("172.16.0.1")},
+			addr:   network.NewAddress("restapi", "other"),
+			now:    monday,
+		},
+		{
+			name:   "other service of the namespace",
+			caller: Caller{Certificate: "backend", Source: netip.MustParseAddr("10.1.2.3")},
+			addr:   network.NewAddress("grpc", "other"),
+			now:    monday,
+		},
+		{
+			name:     "within schedule",
+			caller:   Caller{User: "batch"},
+			addr:     network.NewAddress("restapi", "other"),
+			now:      time.Date(2024, time.January, 1, 23, 0, 0, 0, time.UTC),
+			expected: true,
+			rule:     "night-jobs",
+		},
+		{
+			name:     "within schedule after midnight",
+			caller:   Caller{User: "batch"},
+			addr:     network.NewAddress("restapi", "other"),
+			now:      time.Date(2024, time.January, 3, 5, 59, 0, 0, time.UTC),
+			expected: true,
+			rule:     "night-jobs",
+		},
+		{
+			name:   "after midnight of day out of schedule",
+			caller: Caller{User: "batch"},
+			addr:   network.NewAddress("restapi", "other"),
+			now:    time.Date(2024, time.January, 1, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "out of schedule",
+			caller: Caller{User: "batch"},
+			addr:   network.NewAddress("restapi", "other"),
+			now:    time.Date(2024, time.January, 2, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "unknown caller",
+			addr: network.NewAddress("restapi", "example"),
+			now:  monday,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := policy.Evaluate(tt.caller, tt.addr, tt.now)
+
+			assert.Equal(t, tt.expected, decision.Allowed)
+			assert.Equal(t, tt.rule, decision.Rule)
+		})
+	}
+}
+
+func TestPolicy_DefaultAction(t *testing.T) {
+	policy, err := NewPolicy(&PolicyConfig{Default: "Allow"})
+	require.NoError(t, err)
+
+	decision := policy.Evaluate(Caller{}, network.NewAddress("restapi", "example"), time.Now())
+	assert.True(t, decision.Allowed)
+	assert.Empty(t, decision.Rule)
+}
+
+func TestNewPolicy_Invalid(t *testing.T) {
+	tests := []struct {
+		cfg  *PolicyConfig
+		name string
+	}{
+		{
+			name: "unknown default action",
+			cfg:  &PolicyConfig{Default: "maybe"},
+		},
+		{
+			name: "missing rule action",
+			cfg:  &PolicyConfig{Rules: []RuleConfig{{Name: "rule"}}},
+		},
+		{
+			name: "invalid source",
+			cfg:  &PolicyConfig{Rules: []RuleConfig{{Action: ActionAllow, Sources: []string{"10.0.0.0/33"}}}},
+		},
+		{
+			name: "invalid day",
+			cfg:  &PolicyConfig{Rules: []RuleConfig{{Action: ActionAllow, Schedule: &ScheduleConfig{Days: []string{"someday"}}}}},
+		},
+		{
+			name: "invalid time",
+			cfg:  &PolicyConfig{Rules: []RuleConfig{{Action: ActionAllow, Schedule: &ScheduleConfig{From: "25:00", To: "06:00"}}}},
+		},
+		{
+			name: "invalid timezone",
+			cfg:  &PolicyConfig{Rules: []RuleConfig{{Action: ActionAllow, Schedule: &ScheduleConfig{Timezone: "Nowhere/Never"}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPolicy(tt.cfg)
+			assert.ErrorIs(t, err, ErrInvalidPolicy)
+		})
+	}
+}
+
+func TestNewConnection_AccessDenied(t *testing.T) {
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+	policies := NewMockPolicyRepo(t)
+
+	policy, err := NewPolicy(&PolicyConfig{
+		Rules: []RuleConfig{{Action: ActionAllow, Users: []string{"alice"}}},
+	})
+	require.NoError(t, err)
+
+	policies.EXPECT().Policy().Return(policy)
+
+	service := New(&Config{}, revProxyRepo, connQueue, policies, nil)
+
+	ctx := WithCaller(context.Background(), Caller{User: "bob"})
+
+	conn, err := service.NewConnection(ctx, network.NewAddress("restapi", "example"))
+
+	assert.ErrorIs(t, err, ErrAccessDenied)
+	assert.Nil(t, conn)
+}
+
+func TestNewConnection_AccessAllowed(t *testing.T) {
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+	policies := NewMockPolicyRepo(t)
+
+	policy, err := NewPolicy(&PolicyConfig{
+		Rules: []RuleConfig{{Action: ActionAllow, Users: []string{"alice"}}},
+	})
+	require.NoError(t, err)
+
+	policies.EXPECT().Policy().Return(policy)
+	revProxyRepo.EXPECT().Find("example", "restapi").Return(nil, ErrRevProxyNotFound)
+
+	service := New(&Config{}, revProxyRepo, connQueue, policies, nil)
+
+	ctx := WithCaller(context.Background(), Caller{User: "alice"})
+
+	_, err = service.NewConnection(ctx, network.NewAddress("restapi", "example"))
+
+	assert.ErrorIs(t, err, ErrRevProxyNotFound)
+}
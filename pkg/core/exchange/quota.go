@@ -0,0 +1,104 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var ErrQuotaExceeded = fmt.Errorf("concurrent connections quota exceeded")
+
+// QuotaConfig is the configuration of the quotas of concurrent connections requested by proxy clients.
+type QuotaConfig struct {
+	NameSpaces map[string]NameSpaceQuota `mapstructure:"namespaces"`
+}
+
+// NameSpaceQuota is the maximum number of concurrent connections to the name space
+// and the maximum numbers of concurrent connections to its services, zero disables the quota.
+type NameSpaceQuota struct {
+	Services       map[string]int `mapstructure:"services"`
+	MaxConnections int            `mapstructure:"max_connections"`
+}
+
+// quota counts the concurrent connections up to the limit.
+type quota struct {
+	limit  int64
+	active atomic.Int64
+}
+
+// acquire takes the slot of the quota, it reports false if all slots are taken.
+func (q *quota) acquire() bool {
+	if q.active.Add(1) > q.limit {
+		q.active.Add(-1)
+		return false
+	}
+
+	return true
+}
+
+// release returns the slot of the quota.
+func (q *quota) release() {
+	q.active.Add(-1)
+}
+
+// newQuotas creates the quotas for the configured limits.
+func newQuotas(cfg *QuotaConfig) map[limitKey]*quota {
+	quotas := make(map[limitKey]*quota)
+
+	for nameSpace, nsQuota := range cfg.NameSpaces {
+		if nsQuota.MaxConnections > 0 {
+			quotas[newLimitKey(scopeNameSpace, nameSpace)] = &quota{limit: int64(nsQuota.MaxConnections)}
+		}
+
+		for service, limit := range nsQuota.Services {
+			if limit > 0 {
+				quotas[newLimitKey(scopeService, service+"."+nameSpace)] = &quota{limit: int64(limit)}
+			}
+		}
+	}
+
+	return quotas
+}
+
+// acquireQuota takes the slots of the quotas of the name space and the service for the new connection.
+// It fails with ErrQuotaExceeded if any of the quotas is exhausted, the rejected requests are counted
+// by the quota_rejected_connections metric.
+// It returns the function that releases the taken slots.
+func (s *Service) acquireQuota(ctx context.Context, addr *network.Address) (func(), error) {
+	if len(s.quotas) == 0 {
+		return func() {}, nil
+	}
+
+	keys := addrLimitKeys(addr)
+
+	taken := make([]*quota, 0, len(keys))
+	release := func() {
+		for _, q := range taken {
+			q.release()
+		}
+	}
+
+	for _, key := range keys {
+		q, ok := s.quotas[key]
+		if !ok {
+			continue
+		}
+
+		if !q.acquire() {
+			release()
+
+			rejected, _ := meter.Int64Counter("quota_rejected_connections", metric.WithDescription("Number of connection requests rejected by the concurrent connections quotas"))
+			rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("scope", key.scope), attribute.String("name", key.name)))
+
+			return nil, fmt.Errorf("%w: %s %s", ErrQuotaExceeded, key.scope, key.name)
+		}
+
+		taken = append(taken, q)
+	}
+
+	return release, nil
+}
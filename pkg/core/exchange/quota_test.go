@@ -0,0 +1,112 @@
+package exchange
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConnection_QuotaExceeded(t *testing.T) {
+	addr := network.NewAddress("service1", "example")
+
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+
+	cfg := &Config{
+		Quotas: QuotaConfig{
+			NameSpaces: map[string]NameSpaceQuota{
+				"example": {MaxConnections: 1},
+			},
+		},
+	}
+
+	service := New(cfg, revProxyRepo, connQueue, nil, nil)
+
+	release, err := service.acquireQuota(context.Background(), addr)
+	require.NoError(t, err)
+
+	_, err = service.NewConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	release()
+
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(nil, ErrRevProxyNotFound).Twice()
+
+	// The slot is returned when the connection fails, so the next request is not rejected by the quota.
+	_, err = service.NewConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrRevProxyNotFound)
+
+	_, err = service.NewConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrRevProxyNotFound)
+}
+
+func TestService_AcquireQuota(t *testing.T) {
+	cfg := &QuotaConfig{
+		NameSpaces: map[string]NameSpaceQuota{
+			"example": {
+				MaxConnections: 2,
+				Services: map[string]int{
+					"Service1": 1,
+				},
+			},
+		},
+	}
+
+	service := New(&Config{Quotas: *cfg}, NewMockRevProxyRepo(t), NewMockConnectionQueue(t), nil, nil)
+
+	service1 := network.NewAddress("service1", "example")
+	service2 := network.NewAddress("service2", "example")
+
+	release1, err := service.acquireQuota(context.Background(), service1)
+	require.NoError(t, err)
+
+	_, err = service.acquireQuota(context.Background(), service1)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	release2, err := service.acquireQuota(context.Background(), service2)
+	require.NoError(t, err)
+
+	_, err = service.acquireQuota(context.Background(), service2)
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	// The names are matched case-insensitively.
+	_, err = service.acquireQuota(context.Background(), network.NewAddress("Service1", "Example"))
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	// Other name spaces are not affected.
+	releaseOther, err := service.acquireQuota(context.Background(), network.NewAddress("service1", "other"))
+	require.NoError(t, err)
+
+	releaseOther()
+	release1()
+
+	release3, err := service.acquireQuota(context.Background(), service2)
+	require.NoError(t, err)
+
+	release2()
+	release3()
+
+	assert.Equal(t, int64(0), service.quotas[limitKey{scope: scopeNameSpace, name: "example"}].active.Load())
+	assert.Equal(t, int64(0), service.quotas[limitKey{scope: scopeService, name: "service1.example"}].active.Load())
+}
+
+func TestTrackedConn_CloseWith(t *testing.T) {
+	service := New(&Config{}, NewMockRevProxyRepo(t), NewMockConnectionQueue(t), nil, nil)
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	released := 0
+
+	conn := service.track(local, "example", "service1", nil)
+	conn.closeWith(func() { released++ })
+
+	assert.NoError(t, conn.Close())
+	assert.NoError(t, conn.Close())
+	assert.Equal(t, 1, released)
+	assert.Equal(t, 0, service.ActiveConnections())
+}
@@ -0,0 +1,153 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var ErrRateLimited = fmt.Errorf("rate limit exceeded")
+
+const (
+	scopeNameSpace = "namespace"
+	scopeService   = "service"
+	scopeUser      = "user"
+)
+
+// RateLimitConfig is the configuration of the rate limits of the connections requested by proxy clients.
+// The limits of the name space are shared by all its services, the limits of the services are set in their name space,
+// and the limits of the user are shared by all connections of the authenticated SOCKS5 user.
+type RateLimitConfig struct {
+	NameSpaces map[string]NameSpaceRateLimit `mapstructure:"namespaces"`
+	Users      map[string]network.RateLimit  `mapstructure:"users"`
+}
+
+// NameSpaceRateLimit is the rate limit of the name space and the rate limits of its services.
+type NameSpaceRateLimit struct {
+	Services          map[string]network.RateLimit `mapstructure:"services"`
+	network.RateLimit `mapstructure:",squash"`
+}
+
+// limitKey identifies the subject of the rate limit or the quota.
+type limitKey struct {
+	scope string
+	name  string
+}
+
+// newLimitKey returns the key of the subject with the lowercased name.
+// Viper lowercases the keys of the configured maps, so the names are matched case-insensitively.
+func newLimitKey(scope, name string) limitKey {
+	return limitKey{scope: scope, name: strings.ToLower(name)}
+}
+
+// addrLimitKeys returns the keys of the name space and the service of the address.
+func addrLimitKeys(addr *network.Address) []limitKey {
+	return []limitKey{
+		newLimitKey(scopeNameSpace, addr.NameSpace),
+		newLimitKey(scopeService, addr.Service+"."+addr.NameSpace),
+	}
+}
+
+// rateLimiter is the pair of the limiters of new connections and bandwidth, either of them can be nil.
+type rateLimiter struct {
+	conns     *network.RateLimiter
+	bandwidth *network.RateLimiter
+}
+
+// newRateLimiters creates the limiters for the configured rate limits.
+// The hits of the limits are counted by the rate_limit_hits metric.
+func newRateLimiters(cfg *RateLimitConfig) map[limitKey]*rateLimiter {
+	limiters := make(map[limitKey]*rateLimiter)
+
+	add := func(key limitKey, limit network.RateLimit) {
+		l := &rateLimiter{
+			conns:     limit.ConnectionLimiter(onRateLimited(key, "connections")),
+			bandwidth: limit.BandwidthLimiter(onRateLimited(key, "bandwidth")),
+		}
+
+		if l.conns != nil || l.bandwidth != nil {
+			limiters[key] = l
+		}
+	}
+
+	for nameSpace, nsLimit := range cfg.NameSpaces {
+		add(newLimitKey(scopeNameSpace, nameSpace), nsLimit.RateLimit)
+
+		for service, limit := range nsLimit.Services {
+			add(newLimitKey(scopeService, service+"."+nameSpace), limit)
+		}
+	}
+
+	for user, limit := range cfg.Users {
+		add(newLimitKey(scopeUser, user), limit)
+	}
+
+	return limiters
+}
+
+// onRateLimited returns the function that counts the hits of the limit.
+func onRateLimited(key limitKey, limit string) func() {
+	attrs := metric.WithAttributes(
+		attribute.String("limit", limit),
+		attribute.String("scope", key.scope),
+		attribute.String("name", key.name),
+	)
+
+	return func() {
+		hits, _ := meter.Int64Counter("rate_limit_hits", metric.WithDescription("Number of times the rate limits are hit"))
+		hits.Add(context.Background(), 1, attrs)
+	}
+}
+
+// rateLimit takes the new connection from the limits of the name space, the service and the user of the caller.
+// It fails with ErrRateLimited if any of the limits is exceeded, then the connections taken from the other limits
+// are returned.
+// It returns the bandwidth limiters that apply to the connection and the function that returns the taken connections,
+// it must be called if the connection isn't established.
+func (s *Service) rateLimit(ctx context.Context, addr *network.Address) ([]*network.RateLimiter, func(), error) {
+	if len(s.limiters) == 0 {
+		return nil, func() {}, nil
+	}
+
+	keys := addrLimitKeys(addr)
+
+	if caller, ok := CallerFromContext(ctx); ok && caller.User != "" {
+		keys = append(keys, newLimitKey(scopeUser, caller.User))
+	}
+
+	var bandwidth []*network.RateLimiter
+
+	taken := make([]*network.RateLimiter, 0, len(keys))
+	cancel := func() {
+		for _, c := range taken {
+			c.Cancel()
+		}
+	}
+
+	for _, key := range keys {
+		l, ok := s.limiters[key]
+		if !ok {
+			continue
+		}
+
+		if l.conns != nil {
+			if !l.conns.Allow() {
+				cancel()
+
+				return nil, nil, fmt.Errorf("%w: %s %s", ErrRateLimited, key.scope, key.name)
+			}
+
+			taken = append(taken, l.conns)
+		}
+
+		if l.bandwidth != nil {
+			bandwidth = append(bandwidth, l.bandwidth)
+		}
+	}
+
+	return bandwidth, cancel, nil
+}
@@ -0,0 +1,168 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConnection_RateLimited(t *testing.T) {
+	addr := network.NewAddress("service1", "example")
+
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+
+	cfg := &Config{
+		RateLimits: RateLimitConfig{
+			NameSpaces: map[string]NameSpaceRateLimit{
+				"example": {RateLimit: network.RateLimit{Connections: 1}},
+			},
+		},
+	}
+
+	service := New(cfg, revProxyRepo, connQueue, nil, nil)
+
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(nil, ErrRevProxyNotFound).Twice()
+
+	// The connection is returned to the limit when the request fails, so the next request is not rate limited.
+	_, err := service.NewConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrRevProxyNotFound)
+
+	_, err = service.NewConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrRevProxyNotFound)
+
+	_, _, err = service.rateLimit(context.Background(), addr)
+	require.NoError(t, err)
+
+	_, err = service.NewConnection(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
+
+func TestNewConnection_RateLimitReturnedOnQuota(t *testing.T) {
+	addr := network.NewAddress("service1", "example")
+
+	cfg := &Config{
+		RateLimits: RateLimitConfig{
+			NameSpaces: map[string]NameSpaceRateLimit{
+				"example": {RateLimit: network.RateLimit{Connections: 1}},
+			},
+		},
+		Quotas: QuotaConfig{
+			NameSpaces: map[string]NameSpaceQuota{
+				"example": {MaxConnections: 1},
+			},
+		},
+	}
+
+	service := New(cfg, NewMockRevProxyRepo(t), NewMockConnectionQueue(t), nil, nil)
+
+	release, err := service.acquireQuota(context.Background(), addr)
+	require.NoError(t, err)
+
+	defer release()
+
+	for range 3 {
+		_, err = service.NewConnection(context.Background(), addr)
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+	}
+}
+
+func TestService_RateLimit(t *testing.T) {
+	cfg := &RateLimitConfig{
+		NameSpaces: map[string]NameSpaceRateLimit{
+			"example": {
+				RateLimit: network.RateLimit{Bandwidth: 1024},
+				Services: map[string]network.RateLimit{
+					"service1": {Bandwidth: 512, Connections: 10},
+				},
+			},
+			"limited": {RateLimit: network.RateLimit{Connections: 1}},
+		},
+		Users: map[string]network.RateLimit{
+			"alice": {Connections: 1},
+			"Bob":   {Bandwidth: 256},
+		},
+	}
+
+	tests := []struct {
+		addr       *network.Address
+		wantErr    error
+		name       string
+		user       string
+		wantBursts []int
+	}{
+		{
+			name:       "name space and service",
+			addr:       network.NewAddress("service1", "example"),
+			wantBursts: []int{1024, 512},
+		},
+		{
+			name:       "name space only",
+			addr:       network.NewAddress("service2", "example"),
+			wantBursts: []int{1024},
+		},
+		{
+			name:       "user bandwidth",
+			addr:       network.NewAddress("service2", "example"),
+			user:       "bob",
+			wantBursts: []int{1024, 256},
+		},
+		{
+			name:       "mixed case names",
+			addr:       network.NewAddress("Service1", "Example"),
+			user:       "Bob",
+			wantBursts: []int{1024, 512, 256},
+		},
+		{
+			name: "not limited",
+			addr: network.NewAddress("service1", "other"),
+		},
+		{
+			name:    "user connections",
+			addr:    network.NewAddress("service1", "other"),
+			user:    "alice",
+			wantErr: ErrRateLimited,
+		},
+	}
+
+	service := New(&Config{RateLimits: *cfg}, NewMockRevProxyRepo(t), NewMockConnectionQueue(t), nil, nil)
+
+	// The only connection of alice is taken before the test cases.
+	_, _, err := service.rateLimit(WithCaller(context.Background(), Caller{User: "alice"}), network.NewAddress("service1", "other"))
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.user != "" {
+				ctx = WithCaller(ctx, Caller{User: tt.user})
+			}
+
+			limiters, _, err := service.rateLimit(ctx, tt.addr)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+
+			bursts := make([]int, 0, len(limiters))
+			for _, l := range limiters {
+				bursts = append(bursts, l.Burst())
+			}
+
+			assert.ElementsMatch(t, tt.wantBursts, bursts)
+		})
+	}
+
+	// The connections denied by the limit of the user don't take the connections of the name space.
+	for range 3 {
+		_, _, err = service.rateLimit(WithCaller(context.Background(), Caller{User: "alice"}), network.NewAddress("service1", "limited"))
+		assert.ErrorIs(t, err, ErrRateLimited)
+	}
+
+	_, _, err = service.rateLimit(context.Background(), network.NewAddress("service1", "limited"))
+	assert.NoError(t, err)
+
+	_, _, err = service.rateLimit(context.Background(), network.NewAddress("service1", "limited"))
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
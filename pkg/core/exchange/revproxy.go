@@ -3,43 +3,74 @@ package exchange
 import (
 	"context"
 	"fmt"
+	"path"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
 	ErrNameSpaceEmpty   = fmt.Errorf("name space is empty")
+	ErrNameSpaceCase    = fmt.Errorf("name space must be lowercase")
 	ErrServicesEmpty    = fmt.Errorf("services list is empty")
 	ErrDuplicateService = fmt.Errorf("duplicate service name")
 	ErrRevProxyStopped  = fmt.Errorf("revproxy is stopped")
 	ErrServiceNameEmpty = fmt.Errorf("service name is empty")
 	ErrRevProxyStarted  = fmt.Errorf("revproxy is already started")
+	ErrInvalidPattern   = fmt.Errorf("invalid service name pattern")
+	ErrWildcardWarmPool = fmt.Errorf("warm pool is not supported for wildcard services")
 )
 
 type RevProxy struct {
 	ctx       context.Context
 	cancel    context.CancelFunc
 	cmdStream chan RevProxyCommand
+	warmPools map[string]*warmPool
+	status    atomic.Pointer[Status]
 	NameSpace string
 	Services  []string
-	mu        sync.RWMutex
 	wg        sync.WaitGroup
+	inFlight  atomic.Int64
+	mu        sync.RWMutex
+	sendMu    sync.RWMutex
 }
 
+// Status is the state of the reverse proxy that it reports over the control stream.
+type Status struct {
+	ReportedAt    time.Time
+	ActiveBridges map[string]int64
+	Version       string
+	Uptime        time.Duration
+}
+
+// RevProxyCommand is the command to establish the reverse connection for the connection request.
+// Port is the requested port of the service, it's zero for warm connections,
+// because the port is sent when the warm connection is activated.
 type RevProxyCommand struct {
 	NameSpace string
 	Name      string
 	ConnID    uint64
+	Port      uint16
+	Warm      bool
 }
 
 // NewRevProxy creates a new RevProxy with the specified name space and services.
+// Service names can be wildcard patterns in the path.Match syntax, e.g. "*" or "api-*".
 // It returns an error if the name space is empty or if the services list is empty.
-// It also returns an error if the services list contains duplicate service names.
+// The name space must be lowercase, because requested addresses and configured limits are matched in lower case.
+// It also returns an error if the services list contains duplicate service names or invalid patterns.
 // The RevProxy is created with an empty command stream.
 func NewRevProxy(nameSpace string, services []string) (*RevProxy, error) {
 	if nameSpace == "" {
 		return nil, ErrNameSpaceEmpty
 	}
 
+	if nameSpace != strings.ToLower(nameSpace) {
+		return nil, fmt.Errorf("%w: %s", ErrNameSpaceCase, nameSpace)
+	}
+
 	if len(services) == 0 {
 		return nil, ErrServicesEmpty
 	}
@@ -55,6 +86,10 @@ func NewRevProxy(nameSpace string, services []string) (*RevProxy, error) {
 			return nil, ErrDuplicateService
 		}
 
+		if _, err := path.Match(service, ""); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPattern, service)
+		}
+
 		uniqIndex[service] = struct{}{}
 	}
 
@@ -62,9 +97,29 @@ func NewRevProxy(nameSpace string, services []string) (*RevProxy, error) {
 		NameSpace: nameSpace,
 		Services:  services,
 		cmdStream: make(chan RevProxyCommand),
+		warmPools: make(map[string]*warmPool),
 	}, nil
 }
 
+// EnableWarmPool enables the pool of warm connections of the given size for the service.
+// It must be called before the RevProxy is started.
+// It returns an error if the RevProxy doesn't serve the service or the service is a wildcard pattern.
+func (r *RevProxy) EnableWarmPool(service string, size int) error {
+	if !r.HasService(service) {
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, service)
+	}
+
+	if IsServicePattern(service) {
+		return fmt.Errorf("%w: %s", ErrWildcardWarmPool, service)
+	}
+
+	if size > 0 {
+		r.warmPools[service] = newWarmPool(r.NameSpace, service, size)
+	}
+
+	return nil
+}
+
 // Start starts the RevProxy and returns an error if the RevProxy is already running.
 // The RevProxy is started with the specified context.
 func (r *RevProxy) Start(ctx context.Context) error {
@@ -84,7 +139,11 @@ func (r *RevProxy) Start(ctx context.Context) error {
 		defer r.wg.Done()
 
 		<-ctx.Done()
+
+		// Wait for pending senders to give up, sending to closed channel panics
+		r.sendMu.Lock()
 		close(r.cmdStream)
+		r.sendMu.Unlock()
 	}()
 
 	return nil
@@ -92,14 +151,23 @@ func (r *RevProxy) Start(ctx context.Context) error {
 
 // Stop stops the RevProxy.
 // It closes the command stream and sets the context to nil.
+// Stopping a RevProxy that is not started does nothing.
 func (r *RevProxy) Stop() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if r.ctx == nil {
+		return
+	}
+
 	r.cancel()
 
 	r.ctx = nil
 	r.wg.Wait()
+
+	for _, pool := range r.warmPools {
+		pool.close()
+	}
 }
 
 // CommandStream returns a read-only channel of RevProxyCommand.
@@ -110,9 +178,31 @@ func (r *RevProxy) CommandStream() <-chan RevProxyCommand {
 	return r.cmdStream
 }
 
-// RequestConnection sends a request to establish a connection with the specified ID and name.
+// RequestConnection sends a request to establish a connection with the specified ID, name and port.
 // It returns an error if the context is canceled or if the command cannot be sent to the command stream.
-func (r *RevProxy) RequestConnection(ctx context.Context, id uint64, name string) error {
+func (r *RevProxy) RequestConnection(ctx context.Context, id uint64, name string, port uint16) error {
+	return r.sendCommand(ctx, RevProxyCommand{
+		NameSpace: r.NameSpace,
+		Name:      name,
+		ConnID:    id,
+		Port:      port,
+	})
+}
+
+// RequestWarmConnection sends a request to establish a warm connection with the specified ID and name.
+// The warm connection is kept idle until it's activated.
+// It returns an error if the context is canceled or if the command cannot be sent to the command stream.
+func (r *RevProxy) RequestWarmConnection(ctx context.Context, id uint64, name string) error {
+	return r.sendCommand(ctx, RevProxyCommand{
+		NameSpace: r.NameSpace,
+		Name:      name,
+		ConnID:    id,
+		Warm:      true,
+	})
+}
+
+// sendCommand sends the command to the command stream.
+func (r *RevProxy) sendCommand(ctx context.Context, cmd RevProxyCommand) error {
 	r.mu.RLock()
 	proxyCtx := r.ctx
 	r.mu.RUnlock()
@@ -121,10 +211,11 @@ func (r *RevProxy) RequestConnection(ctx context.Context, id uint64, name string
 		return ErrRevProxyStopped
 	}
 
-	cmd := RevProxyCommand{
-		NameSpace: r.NameSpace,
-		Name:      name,
-		ConnID:    id,
+	r.sendMu.RLock()
+	defer r.sendMu.RUnlock()
+
+	if proxyCtx.Err() != nil {
+		return ErrRevProxyStopped
 	}
 
 	select {
@@ -136,3 +227,76 @@ func (r *RevProxy) RequestConnection(ctx context.Context, id uint64, name string
 		return nil
 	}
 }
+
+// HasService reports whether the RevProxy serves the service with the given name,
+// either by the exact name or by a wildcard pattern.
+func (r *RevProxy) HasService(name string) bool {
+	return MatchService(r.Services, name)
+}
+
+// HasExactService reports whether the RevProxy serves the service with the given name not by a wildcard pattern.
+func (r *RevProxy) HasExactService(name string) bool {
+	return slices.Contains(r.Services, name)
+}
+
+// MatchService reports whether the service with the given name is in the list of services,
+// either by the exact name or by a wildcard pattern.
+func MatchService(services []string, name string) bool {
+	if slices.Contains(services, name) {
+		return true
+	}
+
+	for _, service := range services {
+		if ok, _ := path.Match(service, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsServicePattern reports whether the service name is a wildcard pattern.
+func IsServicePattern(name string) bool {
+	return strings.ContainsAny(name, `*?[\`)
+}
+
+// InFlight returns the number of connections that are requested or established through the RevProxy.
+func (r *RevProxy) InFlight() int64 {
+	return r.inFlight.Load()
+}
+
+// acquire increments the number of in-flight connections.
+func (r *RevProxy) acquire() {
+	r.inFlight.Add(1)
+}
+
+// release decrements the number of in-flight connections.
+func (r *RevProxy) release() {
+	r.inFlight.Add(-1)
+}
+
+// Status returns the last status reported by the RevProxy.
+// The second return value is false if the RevProxy has not reported its status yet.
+func (r *RevProxy) Status() (Status, bool) {
+	status := r.status.Load()
+	if status == nil {
+		return Status{}, false
+	}
+
+	return *status, true
+}
+
+// setStatus stores the status reported by the RevProxy and returns the previous one.
+func (r *RevProxy) setStatus(status Status) (Status, bool) {
+	prev := r.status.Swap(&status)
+	if prev == nil {
+		return Status{}, false
+	}
+
+	return *prev, true
+}
+
+// warmPool returns the pool of warm connections for the service, or nil if it's not enabled.
+func (r *RevProxy) warmPool(service string) *warmPool {
+	return r.warmPools[service]
+}
@@ -51,6 +51,38 @@ func TestNewRevProxy(t *testing.T) {
 	_, err = NewRevProxy(nameSpace, services)
 
 	assert.ErrorIs(t, err, ErrServiceNameEmpty)
+
+	// Test case 6: Invalid service name pattern
+	nameSpace = "example"
+	services = []string{"service1", "api-["}
+
+	_, err = NewRevProxy(nameSpace, services)
+
+	assert.ErrorIs(t, err, ErrInvalidPattern)
+
+	// Test case 7: Mixed case name space
+	nameSpace = "Example"
+	services = []string{"service1"}
+
+	_, err = NewRevProxy(nameSpace, services)
+
+	assert.ErrorIs(t, err, ErrNameSpaceCase)
+}
+
+func TestRevProxy_HasService(t *testing.T) {
+	revProxy, err := NewRevProxy("example", []string{"service1", "api-*"})
+	assert.NoError(t, err)
+
+	assert.True(t, revProxy.HasService("service1"))
+	assert.True(t, revProxy.HasExactService("service1"))
+
+	assert.True(t, revProxy.HasService("api-users"))
+	assert.False(t, revProxy.HasExactService("api-users"))
+
+	assert.False(t, revProxy.HasService("service2"))
+
+	assert.NoError(t, revProxy.EnableWarmPool("service1", 1))
+	assert.ErrorIs(t, revProxy.EnableWarmPool("api-*", 1), ErrWildcardWarmPool)
 }
 func TestRevProxy_CommandStream(t *testing.T) {
 	// Create a new RevProxy
@@ -124,14 +156,15 @@ func TestRevProxy_RequestConnection_Success(t *testing.T) {
 
 	done := make(chan struct{})
 	go func() {
-		err = revProxy.RequestConnection(ctx, connID, serviceName)
+		err = revProxy.RequestConnection(ctx, connID, serviceName, 8080)
 		assert.NoError(t, err)
 		close(done)
 	}()
 
 	select {
-	case _, ok := <-revProxy.CommandStream():
+	case cmd, ok := <-revProxy.CommandStream():
 		assert.True(t, ok)
+		assert.Equal(t, RevProxyCommand{NameSpace: nameSpace, Name: serviceName, ConnID: connID, Port: 8080}, cmd)
 	case <-time.After(100 * time.Millisecond):
 		t.Error("Expected command to be sent to RevProxy")
 	}
@@ -159,7 +192,7 @@ func TestRevProxy_RequestConnection_CancelContext(t *testing.T) {
 
 	cancel()
 
-	err = revProxy.RequestConnection(cancelCtx, connID, serviceName)
+	err = revProxy.RequestConnection(cancelCtx, connID, serviceName, 0)
 	assert.Equal(t, context.Canceled, err)
 }
 
@@ -177,6 +210,6 @@ func TestRevProxy_RequestConnection_ClosedRevProxy(t *testing.T) {
 	serviceName := "service1"
 
 	revProxy.Stop()
-	err = revProxy.RequestConnection(ctx, connID, serviceName)
+	err = revProxy.RequestConnection(ctx, connID, serviceName, 0)
 	assert.Equal(t, ErrRevProxyStopped, err)
 }
@@ -2,23 +2,65 @@ package exchange
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ksysoev/oneway/pkg/core/network"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var meter = otel.GetMeterProvider().Meter("oneway")
 var tracer = otel.Tracer("github.com/ksysoev/oneway/pkg/core/exchange")
 
-var ErrConnReqNotFound = fmt.Errorf("connection request not found")
+var (
+	ErrConnReqNotFound   = fmt.Errorf("connection request not found")
+	ErrNameSpaceMismatch = fmt.Errorf("connection request belongs to another name space")
+	ErrRevProxyNotFound  = fmt.Errorf("revproxy not found")
+	ErrServiceNotFound   = fmt.Errorf("service not found")
+	ErrConnReqExpired    = fmt.Errorf("connection request expired")
+	ErrConnRejected      = fmt.Errorf("connection rejected by revproxy")
+)
+
+const (
+	defaultRequestTTL  = 10 * time.Second
+	defaultGracePeriod = 20 * time.Second
+)
 
 type Service struct {
 	revProxyRepo RevProxyRepo
 	connQueue    ConnectionQueue
+	policies     PolicyRepo
+	peers        PeerRepo
+	conns        map[*trackedConn]struct{}
+	limiters     map[limitKey]*rateLimiter
+	quotas       map[limitKey]*quota
+	timeouts     network.Timeouts
+	requestTTL   time.Duration
+	gracePeriod  time.Duration
+	connsMu      sync.Mutex
+	draining     atomic.Bool
+}
+
+// Config is the configuration of the exchange service.
+// RequestTTL limits how long a connection request waits for the reverse connection.
+// GracePeriod limits how long the active connections are waited for when the exchange is drained.
+// Timeouts limit how long the connections of proxy clients are bridged with services.
+// RateLimits limit the bandwidth and the rate of new connections of proxy clients.
+// Quotas limit the number of concurrent connections of proxy clients per name space and service.
+type Config struct {
+	RateLimits  RateLimitConfig  `mapstructure:"rate_limits"`
+	Quotas      QuotaConfig      `mapstructure:"quotas"`
+	Timeouts    network.Timeouts `mapstructure:"timeouts"`
+	RequestTTL  time.Duration    `mapstructure:"request_ttl"`
+	GracePeriod time.Duration    `mapstructure:"grace_period"`
 }
 
 type ConnResult struct {
@@ -28,27 +70,78 @@ type ConnResult struct {
 
 type RevProxyRepo interface {
 	Register(proxy *RevProxy)
-	Find(nameSpace string) (*RevProxy, error)
+	Find(nameSpace, service string) (*RevProxy, error)
 	Unregister(proxy *RevProxy)
+	Services() map[string][]string
 }
 
 type ConnectionQueue interface {
-	AddRequest(connChan chan ConnResult) uint64
-	AddConnection(id uint64, conn ConnResult) error
+	AddRequest(nameSpace string, connChan chan ConnResult) (uint64, error)
+	AddConnection(nameSpace string, id uint64, conn ConnResult) error
+	RemoveRequest(id uint64) bool
+}
+
+// PolicyRepo provides the current access control policy, nil policy allows all connection requests.
+type PolicyRepo interface {
+	Policy() *Policy
+}
+
+// PeerRepo provides the other nodes of the cluster.
+// Find returns the node that has the reverse proxy serving the service in the name space registered,
+// Node returns the node by its id.
+type PeerRepo interface {
+	Find(nameSpace, service string) (Peer, error)
+	Node(id uint16) (Peer, bool)
 }
 
 // New creates a new instance of the Service.
-// It takes a RevProxyRepo and a ConnectionQueue as parameters.
+// It takes a configuration, a RevProxyRepo, a ConnectionQueue, a PolicyRepo and a PeerRepo as parameters.
+// If the request TTL or the grace period are not configured, the default ones are used.
+// If policies is nil, the access control is disabled.
+// If peers is nil, the exchange runs as a single node.
 // It returns a pointer to the newly created Service.
-func New(revProxyRepo RevProxyRepo, connQueue ConnectionQueue) *Service {
+func New(cfg *Config, revProxyRepo RevProxyRepo, connQueue ConnectionQueue, policies PolicyRepo, peers PeerRepo) *Service {
+	requestTTL := cfg.RequestTTL
+	if requestTTL <= 0 {
+		requestTTL = defaultRequestTTL
+	}
+
+	gracePeriod := cfg.GracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = defaultGracePeriod
+	}
+
 	return &Service{
 		revProxyRepo: revProxyRepo,
 		connQueue:    connQueue,
+		policies:     policies,
+		peers:        peers,
+		conns:        make(map[*trackedConn]struct{}),
+		limiters:     newRateLimiters(&cfg.RateLimits),
+		quotas:       newQuotas(&cfg.Quotas),
+		timeouts:     cfg.Timeouts,
+		requestTTL:   requestTTL,
+		gracePeriod:  gracePeriod,
 	}
 }
 
+// BridgeTimeouts returns the timeouts of bridging the connections of proxy clients with services.
+func (s *Service) BridgeTimeouts() network.Timeouts {
+	return s.timeouts
+}
+
 // NewConnection creates a new connection.
 // It takes a context and an address as parameters.
+// It fails with ErrDraining once the exchange is being drained.
+// The request is checked against the access control policy for the caller from the context first,
+// if it's denied, it fails with ErrAccessDenied.
+// Then the rate limits of the name space, the service and the user are checked, if any is exceeded,
+// it fails with ErrRateLimited, the bandwidth limits are applied to the returned connection.
+// The connection takes the slots of the quotas of the name space and the service until it's closed,
+// if any quota is exhausted, it fails with ErrQuotaExceeded.
+// The request is routed to one of the reverse proxies that serve the requested service in the namespace,
+// if there is no such proxy, the request is forwarded to the node of the cluster that has one,
+// otherwise it fails immediately with ErrRevProxyNotFound or ErrServiceNotFound.
 // It returns a net.Conn and an error.
 func (s *Service) NewConnection(ctx context.Context, addr *network.Address) (net.Conn, error) {
 	ctx, span := tracer.Start(ctx, "Exchange.NewConnection")
@@ -57,26 +150,110 @@ func (s *Service) NewConnection(ctx context.Context, addr *network.Address) (net
 	counter, _ := meter.Int64Counter("connection")
 	counter.Add(ctx, 1, metric.WithAttributes(attribute.String("address", addr.String())))
 
-	connChan := make(chan ConnResult, 1)
+	if s.draining.Load() {
+		return nil, ErrDraining
+	}
+
+	if err := s.authorize(ctx, addr); err != nil {
+		return nil, err
+	}
 
-	id := s.connQueue.AddRequest(connChan)
+	limiters, cancel, err := s.rateLimit(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
 
-	span.AddEvent("Request added")
+	release, err := s.acquireQuota(ctx, addr)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 
-	proxy, err := s.revProxyRepo.Find(addr.NameSpace)
+	conn, err := s.route(ctx, addr)
 	if err != nil {
+		release()
+		cancel()
+
+		return nil, err
+	}
+
+	conn.limiters = limiters
+	conn.closeWith(release)
+
+	return conn, nil
+}
+
+// route connects to the service through the reverse proxy registered on this node,
+// if there is no such proxy, the request is forwarded to the node of the cluster that has one.
+func (s *Service) route(ctx context.Context, addr *network.Address) (*trackedConn, error) {
+	proxy, err := s.revProxyRepo.Find(addr.NameSpace, addr.Service)
+	if err != nil {
+		if s.peers != nil {
+			if peer, errP := s.peers.Find(addr.NameSpace, addr.Service); errP == nil {
+				return s.forwardConnection(ctx, peer, addr)
+			}
+		}
+
 		return nil, fmt.Errorf("failed to get reverse connection proxy: %w", err)
 	}
 
-	if err = proxy.RequestConnection(ctx, id, addr.Service); err != nil {
-		return nil, fmt.Errorf("failed to request connection: %w", err)
+	return s.connect(ctx, proxy, addr)
+}
+
+// connect establishes the connection through the reverse proxy, the warm connection is used if there is one.
+// The connection is counted as in-flight for the reverse proxy and as active for the exchange until it's closed.
+func (s *Service) connect(ctx context.Context, proxy *RevProxy, addr *network.Address) (*trackedConn, error) {
+	proxy.acquire()
+
+	conn, err := s.warmConnection(ctx, proxy, addr)
+	if err == nil && conn == nil {
+		conn, err = s.requestConnection(ctx, proxy, addr.Service, addr.Port, false)
+	}
+
+	if err != nil {
+		proxy.release()
+		return nil, err
+	}
+
+	return s.track(conn, addr.NameSpace, addr.Service, proxy.release), nil
+}
+
+// requestConnection sends the connection request to the reverse proxy and waits for the reverse connection.
+// If warm is true, the reverse proxy is requested to establish a warm connection, the port is sent on its activation.
+// The request is removed from the queue when the context is done or the request TTL is expired,
+// a reverse connection that arrives at the same moment is closed.
+// It returns a net.Conn and an error.
+func (s *Service) requestConnection(ctx context.Context, proxy *RevProxy, service string, port uint16, warm bool) (net.Conn, error) {
+	span := trace.SpanFromContext(ctx)
+	connChan := make(chan ConnResult, 1)
+
+	id, err := s.connQueue.AddRequest(proxy.NameSpace, connChan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add connection request: %w", err)
+	}
+
+	span.AddEvent("Request added")
+
+	reqCtx, cancel := context.WithTimeout(ctx, s.requestTTL)
+	defer cancel()
+
+	if warm {
+		err = proxy.RequestWarmConnection(reqCtx, id, service)
+	} else {
+		err = proxy.RequestConnection(reqCtx, id, service, port)
+	}
+
+	if err != nil {
+		s.cancelRequest(ctx, id, connChan)
+		return nil, fmt.Errorf("failed to request connection: %w", s.requestError(ctx, err))
 	}
 
 	span.AddEvent("Request sent")
 
 	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	case <-reqCtx.Done():
+		s.cancelRequest(ctx, id, connChan)
+		return nil, s.requestError(ctx, reqCtx.Err())
 	case res, ok := <-connChan:
 		if !ok {
 			return nil, fmt.Errorf("failed to get connection")
@@ -86,30 +263,143 @@ func (s *Service) NewConnection(ctx context.Context, addr *network.Address) (net
 	}
 }
 
+// cancelRequest removes the connection request from the queue.
+// If the reverse connection is already being delivered, it waits for it and closes it, so the socket is not leaked.
+func (s *Service) cancelRequest(ctx context.Context, id uint64, connChan chan ConnResult) {
+	if s.connQueue.RemoveRequest(id) {
+		return
+	}
+
+	if res, ok := <-connChan; ok && res.Conn != nil {
+		if err := res.Conn.Close(); err != nil {
+			slog.DebugContext(ctx, "failed to close late connection", slog.Any("error", err))
+		}
+	}
+}
+
+// requestError converts the error of waiting for the reverse connection.
+// If the request TTL is expired while the parent context is still active,
+// it counts the expired request and returns ErrConnReqExpired.
+func (s *Service) requestError(ctx context.Context, err error) error {
+	if !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+		return err
+	}
+
+	expired, _ := meter.Int64Counter("connection_request_expired", metric.WithDescription("Number of expired connection requests"))
+	expired.Add(ctx, 1)
+
+	return ErrConnReqExpired
+}
+
 // RegisterRevProxy registers the reverse connection proxy.
-// It takes a context, namespace, and services as parameters.
+// It takes a context, namespace, services and sizes of warm connection pools per service as parameters.
+// The reverse connection proxy is started with the given context and stays registered until it's unregistered,
+// the warm connection pools are filled in the background.
 // It returns a pointer to a RevProxy and an error.
-func (s *Service) RegisterRevProxy(_ context.Context, nameSpace string, services []string) (*RevProxy, error) {
+func (s *Service) RegisterRevProxy(ctx context.Context, nameSpace string, services []string, warmPool map[string]int) (*RevProxy, error) {
 	proxy, err := NewRevProxy(nameSpace, services)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create reverse connection proxy: %w", err)
 	}
 
+	for service, size := range warmPool {
+		if err := proxy.EnableWarmPool(service, size); err != nil {
+			return nil, fmt.Errorf("failed to enable warm pool: %w", err)
+		}
+	}
+
+	if err := proxy.Start(ctx); err != nil {
+		return nil, fmt.Errorf("failed to start reverse connection proxy: %w", err)
+	}
+
 	s.revProxyRepo.Register(proxy)
 
+	for service := range warmPool {
+		s.fillWarmPool(proxy, service)
+	}
+
 	return proxy, nil
 }
 
-// UnregisterRevProxy unregisters the reverse connection proxy.
+// UnregisterRevProxy unregisters the reverse connection proxy and stops it.
 // It takes a pointer to a RevProxy as a parameter.
 func (s *Service) UnregisterRevProxy(proxy *RevProxy) {
 	s.revProxyRepo.Unregister(proxy)
+	proxy.Stop()
+
+	if status, ok := proxy.Status(); ok {
+		recordActiveBridges(proxy.NameSpace, status.ActiveBridges, nil)
+	}
+}
+
+// ReportStatus stores the status reported by the reverse connection proxy.
+// The number of active bridges is exported as the revproxy_active_bridges metric,
+// which is summed up over all reverse proxies that serve the same service.
+func (s *Service) ReportStatus(proxy *RevProxy, status Status) {
+	prev, _ := proxy.setStatus(status)
+
+	recordActiveBridges(proxy.NameSpace, prev.ActiveBridges, status.ActiveBridges)
+
+	slog.Debug("revproxy status is reported",
+		slog.String("namespace", proxy.NameSpace),
+		slog.String("version", status.Version),
+		slog.Duration("uptime", status.Uptime),
+		slog.Any("active_bridges", status.ActiveBridges),
+	)
+}
+
+// recordActiveBridges records the change of the number of active bridges of the reverse proxy.
+func recordActiveBridges(nameSpace string, prev, curr map[string]int64) {
+	bridges, _ := meter.Int64UpDownCounter("revproxy_active_bridges", metric.WithDescription("Number of active bridges reported by revproxies"))
+
+	for service, n := range curr {
+		if delta := n - prev[service]; delta != 0 {
+			bridges.Add(context.Background(), delta, metric.WithAttributes(attribute.String("namespace", nameSpace), attribute.String("service", service)))
+		}
+	}
+
+	for service, n := range prev {
+		if _, ok := curr[service]; !ok && n != 0 {
+			bridges.Add(context.Background(), -n, metric.WithAttributes(attribute.String("namespace", nameSpace), attribute.String("service", service)))
+		}
+	}
 }
 
 // AddConnection adds a connection to the connection queue.
+// If the request is already expired or canceled, an error is returned and the caller is responsible
+// for closing the connection.
+// The nameSpace is the name space the reverse connection is authenticated for,
+// the connection is accepted only if the request with the given id was sent to the same name space.
+// Empty nameSpace means that the reverse connection is not bound to any name space.
+// If the request is issued by the other node of the cluster, the connection is delivered to that node.
 // It returns an error if the connection queue cannot add the connection.
-func (s *Service) AddConnection(id uint64, conn net.Conn) error {
-	return s.connQueue.AddConnection(id, ConnResult{
+func (s *Service) AddConnection(nameSpace string, id uint64, conn net.Conn) error {
+	err := s.connQueue.AddConnection(nameSpace, id, ConnResult{
 		Conn: conn,
 	})
+
+	if peer, ok := s.requestPeer(id, err); ok {
+		return s.deliverConnection(peer, nameSpace, id, conn)
+	}
+
+	return err
+}
+
+// RejectConnection rejects the pending connection request with the given id.
+// It's called when the reverse proxy fails to establish the connection,
+// the reason is delivered to the requester wrapped with ErrConnRejected, so it fails without waiting for the request TTL.
+// The nameSpace is the name space of the reverse proxy that rejects the request,
+// the request is rejected only if it was sent to the same name space.
+// If the request is issued by the other node of the cluster, the rejection is delivered to that node.
+// It returns an error if the connection request is not found.
+func (s *Service) RejectConnection(nameSpace string, id uint64, reason error) error {
+	err := s.connQueue.AddConnection(nameSpace, id, ConnResult{
+		Err: fmt.Errorf("%w: %w", ErrConnRejected, reason),
+	})
+
+	if peer, ok := s.requestPeer(id, err); ok {
+		return s.rejectConnection(peer, nameSpace, id, reason)
+	}
+
+	return err
 }
@@ -2,6 +2,8 @@ package exchange
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net"
 	"testing"
 	"time"
@@ -15,10 +17,15 @@ func TestNew(t *testing.T) {
 	revProxyRepo := NewMockRevProxyRepo(t)
 	connQueue := NewMockConnectionQueue(t)
 
-	service := New(revProxyRepo, connQueue)
+	service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
 
 	assert.Equal(t, revProxyRepo, service.revProxyRepo)
 	assert.Equal(t, connQueue, service.connQueue)
+	assert.Equal(t, defaultRequestTTL, service.requestTTL)
+
+	service = New(&Config{RequestTTL: time.Second}, revProxyRepo, connQueue, nil, nil)
+
+	assert.Equal(t, time.Second, service.requestTTL)
 }
 func TestAddConnection(t *testing.T) {
 	tests := []struct {
@@ -40,20 +47,44 @@ func TestAddConnection(t *testing.T) {
 			revProxyRepo := NewMockRevProxyRepo(t)
 			connQueue := NewMockConnectionQueue(t)
 
-			service := New(revProxyRepo, connQueue)
+			service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
 
 			mockConn, _ := net.Pipe()
 			defer mockConn.Close()
 
-			connQueue.EXPECT().AddConnection(uint64(123), ConnResult{Conn: mockConn}).Return(tt.err)
+			connQueue.EXPECT().AddConnection("example", uint64(123), ConnResult{Conn: mockConn}).Return(tt.err)
 
 			// Add the connection to the connection queue
-			err := service.AddConnection(123, mockConn)
+			err := service.AddConnection("example", 123, mockConn)
 			assert.ErrorIs(t, err, tt.err)
 		})
 	}
 }
 
+func TestRejectConnection(t *testing.T) {
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+
+	service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
+
+	connQueue.EXPECT().AddConnection("example", uint64(123), mock.Anything).
+		RunAndReturn(func(_ string, _ uint64, res ConnResult) error {
+			assert.Nil(t, res.Conn)
+			assert.ErrorIs(t, res.Err, ErrConnRejected)
+			assert.ErrorIs(t, res.Err, network.ErrConnRefused)
+
+			return nil
+		}).Once()
+
+	err := service.RejectConnection("example", 123, network.ErrConnRefused)
+	assert.NoError(t, err)
+
+	connQueue.EXPECT().AddConnection("example", uint64(321), mock.Anything).Return(ErrConnReqNotFound).Once()
+
+	err = service.RejectConnection("example", 321, network.ErrConnRefused)
+	assert.ErrorIs(t, err, ErrConnReqNotFound)
+}
+
 func TestRegisterRevProxy(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -77,21 +108,32 @@ func TestRegisterRevProxy(t *testing.T) {
 			revProxyRepo := NewMockRevProxyRepo(t)
 			connQueue := NewMockConnectionQueue(t)
 
-			service := New(revProxyRepo, connQueue)
+			service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
 
 			nameSpace := tt.NameSpace
 			services := []string{"service1", "service2"}
 
 			if tt.err == nil {
 				revProxyRepo.EXPECT().Register(mock.Anything).Return()
+				revProxyRepo.EXPECT().Unregister(mock.Anything).Return()
 			}
 
-			result, err := service.RegisterRevProxy(context.Background(), nameSpace, services)
+			result, err := service.RegisterRevProxy(context.Background(), nameSpace, services, nil)
 
 			assert.ErrorIs(t, err, tt.err)
 
 			if err == nil {
 				assert.NotNil(t, result)
+
+				// Started proxy waits for the command to be read, so it fails only due to canceled context
+				canceledCtx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				assert.ErrorIs(t, result.RequestConnection(canceledCtx, 1, "service1", 0), context.Canceled)
+
+				service.UnregisterRevProxy(result)
+
+				assert.ErrorIs(t, result.RequestConnection(context.Background(), 1, "service1", 0), ErrRevProxyStopped)
 			} else {
 				assert.Nil(t, result)
 			}
@@ -102,7 +144,7 @@ func TestUnregisterRevProxy(t *testing.T) {
 	revProxyRepo := NewMockRevProxyRepo(t)
 	connQueue := NewMockConnectionQueue(t)
 
-	service := New(revProxyRepo, connQueue)
+	service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
 
 	proxy := &RevProxy{} // Create a mock RevProxy
 
@@ -131,20 +173,25 @@ type MockConnQ struct {
 	mock.Mock
 }
 
-func (m *MockConnQ) AddRequest(connChan chan ConnResult) uint64 {
-	args := m.Called(connChan)
+func (m *MockConnQ) AddRequest(nameSpace string, connChan chan ConnResult) (uint64, error) {
+	args := m.Called(nameSpace, connChan)
 	m.connRes = connChan
 
 	close(m.ready)
 
-	return args.Get(0).(uint64)
+	return args.Get(0).(uint64), args.Error(1)
 }
 
-func (m *MockConnQ) AddConnection(id uint64, conn ConnResult) error {
-	args := m.Called(id, conn)
+func (m *MockConnQ) AddConnection(nameSpace string, id uint64, conn ConnResult) error {
+	args := m.Called(nameSpace, id, conn)
 	return args.Error(0)
 }
 
+func (m *MockConnQ) RemoveRequest(id uint64) bool {
+	args := m.Called(id)
+	return args.Bool(0)
+}
+
 func TestNewConnection(t *testing.T) {
 	addr := &network.Address{
 		NameSpace: "example",
@@ -164,13 +211,13 @@ func TestNewConnection(t *testing.T) {
 
 	defer proxy.Stop()
 
-	service := New(revProxyRepo, connQueue)
+	service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
 
 	mockConn, _ := net.Pipe()
 	defer mockConn.Close()
 
-	connQueue.On("AddRequest", mock.Anything, mock.Anything).Return(uint64(123))
-	revProxyRepo.EXPECT().Find(addr.NameSpace).Return(proxy, nil)
+	connQueue.On("AddRequest", addr.NameSpace, mock.Anything).Return(uint64(123), nil)
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(proxy, nil)
 
 	done := make(chan struct{})
 	go func() {
@@ -178,6 +225,10 @@ func TestNewConnection(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.NotNil(t, conn)
+		assert.Equal(t, int64(1), proxy.InFlight())
+
+		assert.NoError(t, conn.Close())
+		assert.Equal(t, int64(0), proxy.InFlight())
 
 		close(done)
 	}()
@@ -214,6 +265,42 @@ func TestNewConnection(t *testing.T) {
 	time.Sleep(1000 * time.Millisecond)
 }
 
+func TestNewConnection_Rejected(t *testing.T) {
+	addr := network.NewAddress("service1", "example")
+
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnQ(t)
+
+	proxy, err := NewRevProxy(addr.NameSpace, []string{addr.Service})
+	assert.NoError(t, err)
+
+	err = proxy.Start(context.Background())
+	assert.NoError(t, err)
+
+	defer proxy.Stop()
+
+	service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
+
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(proxy, nil)
+	connQueue.On("AddRequest", addr.NameSpace, mock.Anything).Return(uint64(123), nil)
+
+	go func() {
+		<-proxy.CommandStream()
+
+		connQueue.connRes <- ConnResult{Err: fmt.Errorf("%w: %w", ErrConnRejected, network.ErrConnRefused)}
+		close(connQueue.connRes)
+	}()
+
+	start := time.Now()
+	conn, err := service.NewConnection(context.Background(), addr)
+
+	assert.ErrorIs(t, err, ErrConnRejected)
+	assert.ErrorIs(t, err, network.ErrConnRefused)
+	assert.Nil(t, conn)
+	assert.Less(t, time.Since(start), defaultRequestTTL)
+	assert.Equal(t, int64(0), proxy.InFlight())
+}
+
 func TestNewConnection_FailToFindNameSpace(t *testing.T) {
 	revProxyRepo := NewMockRevProxyRepo(t)
 	connQueue := NewMockConnQ(t)
@@ -223,13 +310,115 @@ func TestNewConnection_FailToFindNameSpace(t *testing.T) {
 		Service:   "service1",
 	}
 
-	service := New(revProxyRepo, connQueue)
+	service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
 
-	connQueue.On("AddRequest", mock.Anything, mock.Anything).Return(uint64(123))
-	revProxyRepo.EXPECT().Find(addr.NameSpace).Return(nil, assert.AnError)
+	revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(nil, assert.AnError)
 
 	conn, err := service.NewConnection(context.Background(), addr)
 
 	assert.ErrorIs(t, err, assert.AnError)
 	assert.Nil(t, conn)
 }
+
+func TestNewConnection_RequestExpired(t *testing.T) {
+	tests := []struct {
+		name      string
+		removed   bool
+		delivered bool
+	}{
+		{
+			name:    "request removed",
+			removed: true,
+		},
+		{
+			name:      "connection delivered after expiration",
+			removed:   false,
+			delivered: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := network.NewAddress("service1", "example")
+
+			revProxyRepo := NewMockRevProxyRepo(t)
+			connQueue := NewMockConnQ(t)
+
+			proxy, err := NewRevProxy(addr.NameSpace, []string{addr.Service})
+			assert.NoError(t, err)
+
+			err = proxy.Start(context.Background())
+			assert.NoError(t, err)
+
+			defer proxy.Stop()
+
+			service := New(&Config{RequestTTL: 10 * time.Millisecond}, revProxyRepo, connQueue, nil, nil)
+
+			revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(proxy, nil)
+			connQueue.On("AddRequest", addr.NameSpace, mock.Anything).Return(uint64(123), nil)
+
+			lateConn, peerConn := net.Pipe()
+			defer peerConn.Close()
+
+			connQueue.On("RemoveRequest", uint64(123)).Return(tt.removed).Run(func(_ mock.Arguments) {
+				if tt.delivered {
+					connQueue.connRes <- ConnResult{Conn: lateConn}
+					close(connQueue.connRes)
+				}
+			})
+
+			go func() {
+				<-proxy.CommandStream()
+			}()
+
+			conn, err := service.NewConnection(context.Background(), addr)
+
+			assert.ErrorIs(t, err, ErrConnReqExpired)
+			assert.Nil(t, conn)
+			assert.Equal(t, int64(0), proxy.InFlight())
+
+			if tt.delivered {
+				_, err = lateConn.Write([]byte("test"))
+				assert.ErrorIs(t, err, io.ErrClosedPipe)
+			}
+		})
+	}
+}
+
+func TestReportStatus(t *testing.T) {
+	revProxyRepo := NewMockRevProxyRepo(t)
+	connQueue := NewMockConnectionQueue(t)
+
+	service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
+
+	proxy, err := NewRevProxy("example", []string{"service1"})
+	assert.NoError(t, err)
+
+	_, ok := proxy.Status()
+	assert.False(t, ok)
+
+	status := Status{
+		ReportedAt:    time.Now(),
+		ActiveBridges: map[string]int64{"service1": 2},
+		Version:       "v1.0.0",
+		Uptime:        time.Minute,
+	}
+
+	service.ReportStatus(proxy, status)
+
+	actual, ok := proxy.Status()
+	assert.True(t, ok)
+	assert.Equal(t, status, actual)
+
+	status.ActiveBridges = map[string]int64{"service1": 1}
+
+	service.ReportStatus(proxy, status)
+
+	actual, ok = proxy.Status()
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), actual.ActiveBridges["service1"])
+
+	revProxyRepo.EXPECT().Unregister(proxy)
+
+	service.UnregisterRevProxy(proxy)
+}
@@ -0,0 +1,186 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// warmPool keeps reverse connections that are established in advance for a service.
+// The pending counter is the number of warm connections that are requested but not delivered yet,
+// it's used to keep the pool from requesting more connections than its size.
+// The number of idle connections is exported as the warm_pool_size metric.
+type warmPool struct {
+	conns   chan net.Conn
+	size    metric.Int64UpDownCounter
+	attrs   metric.MeasurementOption
+	pending int
+	mu      sync.Mutex
+	closed  bool
+}
+
+func newWarmPool(nameSpace, service string, size int) *warmPool {
+	poolSize, _ := meter.Int64UpDownCounter("warm_pool_size", metric.WithDescription("Number of idle warm connections"))
+
+	return &warmPool{
+		conns: make(chan net.Conn, size),
+		size:  poolSize,
+		attrs: metric.WithAttributes(attribute.String("namespace", nameSpace), attribute.String("service", service)),
+	}
+}
+
+// take returns an idle warm connection from the pool.
+// The second return value is false if the pool is empty.
+func (p *warmPool) take() (net.Conn, bool) {
+	select {
+	case conn := <-p.conns:
+		p.size.Add(context.Background(), -1, p.attrs)
+		return conn, true
+	default:
+		return nil, false
+	}
+}
+
+// reserve reserves a place in the pool for a new warm connection.
+// It returns false if the pool is closed or it's already full, counting the pending connections.
+func (p *warmPool) reserve() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || len(p.conns)+p.pending >= cap(p.conns) {
+		return false
+	}
+
+	p.pending++
+
+	return true
+}
+
+// put puts the warm connection into the reserved place of the pool.
+// If conn is nil, the reservation is released.
+// It returns false if the connection is not accepted because the pool is closed.
+func (p *warmPool) put(conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending--
+
+	if conn == nil || p.closed {
+		return false
+	}
+
+	p.conns <- conn
+	p.size.Add(context.Background(), 1, p.attrs)
+
+	return true
+}
+
+// close closes the pool and all idle connections in it.
+func (p *warmPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+
+	p.closed = true
+
+	for {
+		select {
+		case conn := <-p.conns:
+			p.size.Add(context.Background(), -1, p.attrs)
+			_ = conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// warmConnection takes a warm connection from the pool of the service and activates it for the requested port.
+// Connections that fail to activate are closed and the next one is taken,
+// but if the reverse proxy fails to dial the destination, the error is returned wrapped with ErrConnRejected.
+// It returns nil connection and nil error if the pool is not enabled or empty.
+// The pool is refilled in the background.
+func (s *Service) warmConnection(ctx context.Context, proxy *RevProxy, addr *network.Address) (net.Conn, error) {
+	service := addr.Service
+
+	pool := proxy.warmPool(service)
+	if pool == nil {
+		return nil, nil
+	}
+
+	defer s.fillWarmPool(proxy, service)
+
+	for {
+		conn, ok := pool.take()
+		if !ok {
+			misses, _ := meter.Int64Counter("warm_pool_misses", metric.WithDescription("Number of connections requested when the warm pool is empty"))
+			misses.Add(ctx, 1, pool.attrs)
+
+			return nil, nil
+		}
+
+		err := s.activateWarmConn(conn, addr.Port)
+		if err == nil {
+			hits, _ := meter.Int64Counter("warm_pool_hits", metric.WithDescription("Number of connections served from the warm pool"))
+			hits.Add(ctx, 1, pool.attrs)
+
+			return conn, nil
+		}
+
+		_ = conn.Close()
+
+		if errors.Is(err, network.ErrDialFailed) {
+			return nil, fmt.Errorf("%w: %w", ErrConnRejected, err)
+		}
+
+		slog.DebugContext(ctx, "failed to activate warm connection", slog.Any("error", err))
+	}
+}
+
+// activateWarmConn activates the warm connection for the port, waiting for the reply of the reverse proxy up to the request TTL.
+func (s *Service) activateWarmConn(conn net.Conn, port uint16) error {
+	if err := conn.SetDeadline(time.Now().Add(s.requestTTL)); err != nil {
+		return fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	if err := network.ActivateWarmConn(conn, port); err != nil {
+		return err
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// fillWarmPool requests warm connections until the pool of the service is full, counting the pending requests.
+// Failed requests are not retried until the pool is used next time.
+func (s *Service) fillWarmPool(proxy *RevProxy, service string) {
+	pool := proxy.warmPool(service)
+	if pool == nil {
+		return
+	}
+
+	for pool.reserve() {
+		go func() {
+			conn, err := s.requestConnection(context.Background(), proxy, service, 0, true)
+			if err != nil {
+				slog.Debug("failed to request warm connection", slog.Any("error", err), slog.String("namespace", proxy.NameSpace), slog.String("service", service))
+				pool.put(nil)
+
+				return
+			}
+
+			if !pool.put(conn) {
+				_ = conn.Close()
+			}
+		}()
+	}
+}
@@ -0,0 +1,135 @@
+package exchange
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWarmPool(t *testing.T) {
+	pool := newWarmPool("example", "service1", 2)
+
+	_, ok := pool.take()
+	assert.False(t, ok)
+
+	assert.True(t, pool.reserve())
+	assert.True(t, pool.reserve())
+	assert.False(t, pool.reserve())
+
+	conn1, peer1 := net.Pipe()
+	defer peer1.Close()
+
+	assert.True(t, pool.put(conn1))
+	assert.False(t, pool.put(nil))
+	assert.True(t, pool.reserve())
+
+	conn, ok := pool.take()
+	assert.True(t, ok)
+	assert.Equal(t, conn1, conn)
+
+	conn2, peer2 := net.Pipe()
+	defer peer2.Close()
+
+	assert.True(t, pool.put(conn2))
+
+	pool.close()
+
+	_, err := conn2.Write([]byte("test"))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+
+	assert.False(t, pool.reserve())
+
+	_, ok = pool.take()
+	assert.False(t, ok)
+}
+
+func TestEnableWarmPool(t *testing.T) {
+	proxy, err := NewRevProxy("example", []string{"service1"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, proxy.EnableWarmPool("service1", 1))
+	assert.NotNil(t, proxy.warmPool("service1"))
+	assert.ErrorIs(t, proxy.EnableWarmPool("service2", 1), ErrServiceNotFound)
+	assert.Nil(t, proxy.warmPool("service2"))
+}
+
+func TestNewConnection_WarmPool(t *testing.T) {
+	tests := []struct {
+		dialErr error
+		name    string
+	}{
+		{
+			name: "activated",
+		},
+		{
+			name:    "destination refused",
+			dialErr: network.ErrConnRefused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := &network.Address{Service: "service1", NameSpace: "example", Port: 8080}
+
+			revProxyRepo := NewMockRevProxyRepo(t)
+			connQueue := NewMockConnectionQueue(t)
+
+			proxy, err := NewRevProxy(addr.NameSpace, []string{addr.Service})
+			assert.NoError(t, err)
+			assert.NoError(t, proxy.EnableWarmPool(addr.Service, 1))
+
+			service := New(&Config{}, revProxyRepo, connQueue, nil, nil)
+
+			warmConn, peerConn := net.Pipe()
+			defer peerConn.Close()
+
+			pool := proxy.warmPool(addr.Service)
+			assert.True(t, pool.reserve())
+			assert.True(t, pool.put(warmConn))
+
+			go func() {
+				port, err := network.AwaitWarmActivation(peerConn)
+				if err != nil {
+					return
+				}
+
+				assert.Equal(t, addr.Port, port)
+
+				_ = network.ReplyWarmActivation(peerConn, tt.dialErr)
+			}()
+
+			revProxyRepo.EXPECT().Find(addr.NameSpace, addr.Service).Return(proxy, nil)
+
+			refilled := make(chan struct{})
+
+			// The pool is refilled after the warm connection is taken, the request fails because the proxy is not started.
+			connQueue.EXPECT().AddRequest(addr.NameSpace, mock.Anything).Return(0, assert.AnError).Run(func(string, chan ConnResult) {
+				close(refilled)
+			})
+
+			conn, err := service.NewConnection(context.Background(), addr)
+
+			<-refilled
+
+			if tt.dialErr != nil {
+				assert.ErrorIs(t, err, ErrConnRejected)
+				assert.ErrorIs(t, err, tt.dialErr)
+				assert.Nil(t, conn)
+				assert.Equal(t, int64(0), proxy.InFlight())
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, conn)
+			assert.Equal(t, int64(1), proxy.InFlight())
+			assert.NoError(t, conn.Close())
+			assert.Equal(t, int64(0), proxy.InFlight())
+		})
+	}
+}
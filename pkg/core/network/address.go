@@ -3,19 +3,21 @@ package network
 import (
 	"fmt"
 	"net"
+	"net/netip"
+	"strconv"
 	"strings"
 )
 
 var ErrInvalidAddress = fmt.Errorf("invalid address")
 
-const (
-	addressSeparator = "."
-	addressParts     = 2
-)
+const addressSeparator = "."
 
+// Address is the address of the service exposed by a revproxy.
+// Port is the requested port of the service, zero means that the port is not specified.
 type Address struct {
 	Service   string
 	NameSpace string
+	Port      uint16
 }
 
 // NewAddress creates a new Address object with the specified service and namespace.
@@ -27,24 +29,100 @@ func NewAddress(service, nameSpace string) *Address {
 	}
 }
 
+// AddressConfig is the configuration of the address grammar.
+// Suffix is the optional domain suffix that is stripped from the requested host,
+// so with the suffix "oneway" the host echo.example.oneway addresses the service echo in the namespace example.
+type AddressConfig struct {
+	Suffix string `mapstructure:"suffix"`
+}
+
+// AddressParser parses the requested host and port into the Address.
+// The host consists of dot-separated labels, the first label is the service and the rest is the namespace,
+// so the namespace can have several labels, e.g. api.billing.prod is the service api in the namespace billing.prod.
+// The labels can contain only letters, digits, hyphens and underscores, IP addresses are not valid service addresses.
+// Host names are case-insensitive, so the namespace is lowercased, as revproxies can register only lowercase namespaces.
+type AddressParser struct {
+	suffix string
+}
+
+// NewAddressParser creates a new AddressParser with the given configuration.
+// If cfg is nil, no suffix is stripped.
+func NewAddressParser(cfg *AddressConfig) *AddressParser {
+	p := &AddressParser{}
+
+	if cfg != nil {
+		if suffix := strings.Trim(cfg.Suffix, addressSeparator); suffix != "" {
+			p.suffix = addressSeparator + strings.ToLower(suffix)
+		}
+	}
+
+	return p
+}
+
 // ParseAddress parses the given address string and returns a pointer to an Address object and an error.
-// The address string should be in the format "service.namespace", where service and namespace are separated by a dot.
+// The address string should be in the format "service.namespace:port", where service and namespace are separated by a dot.
 // If the address string is not in the correct format, an error is returned.
-// The returned Address object contains the parsed service and namespace values.
+// The returned Address object contains the parsed service, namespace and port values.
 func ParseAddress(addr string) (*Address, error) {
-	fullAddr, _, err := net.SplitHostPort(addr)
+	return NewAddressParser(nil).Parse(addr)
+}
+
+// Parse parses the address string in the format "service.namespace[.suffix]:port".
+// It returns ErrInvalidAddress if the address string is not in the correct format.
+func (p *AddressParser) Parse(addr string) (*Address, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, ErrInvalidAddress
+	}
+
+	port, err := strconv.ParseUint(portStr, 10, 16)
 	if err != nil {
 		return nil, ErrInvalidAddress
 	}
 
-	addrParts := strings.Split(fullAddr, addressSeparator)
-	if len(addrParts) != addressParts {
+	// The host can be a fully qualified domain name with the trailing dot.
+	host = strings.TrimSuffix(host, addressSeparator)
+
+	if p.suffix != "" && len(host) > len(p.suffix) && strings.EqualFold(host[len(host)-len(p.suffix):], p.suffix) {
+		host = host[:len(host)-len(p.suffix)]
+	}
+
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil, ErrInvalidAddress
+	}
+
+	service, nameSpace, ok := strings.Cut(host, addressSeparator)
+	if !ok {
 		return nil, ErrInvalidAddress
 	}
 
-	service, nameSpace := addrParts[0], addrParts[1]
+	for _, label := range strings.Split(host, addressSeparator) {
+		if !validLabel(label) {
+			return nil, ErrInvalidAddress
+		}
+	}
+
+	address := NewAddress(service, strings.ToLower(nameSpace))
+	address.Port = uint16(port)
+
+	return address, nil
+}
+
+// validLabel reports whether the label of the host is not empty and contains only letters, digits, hyphens and underscores.
+func validLabel(label string) bool {
+	if label == "" {
+		return false
+	}
+
+	for _, c := range label {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
 
-	return NewAddress(service, nameSpace), nil
+	return true
 }
 
 // String returns the string representation of the Address object in the format "service.namespace".
@@ -19,6 +19,22 @@ func TestParseAddress(t *testing.T) {
 			err:         nil,
 			expectedStr: "service.namespace",
 		},
+		{
+			addr:        "service.namespace:9090",
+			expected:    &Address{Service: "service", NameSpace: "namespace", Port: 9090},
+			err:         nil,
+			expectedStr: "service.namespace",
+		},
+		{
+			addr:     "service.namespace:65536",
+			expected: nil,
+			err:      ErrInvalidAddress,
+		},
+		{
+			addr:     "service.namespace:http",
+			expected: nil,
+			err:      ErrInvalidAddress,
+		},
 		{
 			addr:     "invalidaddress",
 			expected: nil,
@@ -29,6 +45,39 @@ func TestParseAddress(t *testing.T) {
 			expected: nil,
 			err:      ErrInvalidAddress,
 		},
+		{
+			addr:        "api.billing.prod:80",
+			expected:    &Address{Service: "api", NameSpace: "billing.prod", Port: 80},
+			err:         nil,
+			expectedStr: "api.billing.prod",
+		},
+		{
+			addr:        "service.namespace.:80",
+			expected:    &Address{Service: "service", NameSpace: "namespace", Port: 80},
+			err:         nil,
+			expectedStr: "service.namespace",
+		},
+		{
+			addr:     "service..namespace:80",
+			expected: nil,
+			err:      ErrInvalidAddress,
+		},
+		{
+			addr:     "serv/ice.namespace:80",
+			expected: nil,
+			err:      ErrInvalidAddress,
+		},
+		{
+			addr:        "Service.Billing.PROD:80",
+			expected:    &Address{Service: "Service", NameSpace: "billing.prod", Port: 80},
+			err:         nil,
+			expectedStr: "Service.billing.prod",
+		},
+		{
+			addr:     "10.0.0.1:80",
+			expected: nil,
+			err:      ErrInvalidAddress,
+		},
 	}
 
 	for _, test := range tests {
@@ -42,3 +91,47 @@ func TestParseAddress(t *testing.T) {
 		}
 	}
 }
+
+func TestAddressParser_Suffix(t *testing.T) {
+	parser := NewAddressParser(&AddressConfig{Suffix: ".Oneway."})
+
+	tests := []struct {
+		expected *Address
+		err      error
+		addr     string
+	}{
+		{
+			addr:     "echo.example.oneway:80",
+			expected: &Address{Service: "echo", NameSpace: "example", Port: 80},
+		},
+		{
+			addr:     "echo.example.ONEWAY.:80",
+			expected: &Address{Service: "echo", NameSpace: "example", Port: 80},
+		},
+		{
+			addr:     "api.billing.prod.oneway:443",
+			expected: &Address{Service: "api", NameSpace: "billing.prod", Port: 443},
+		},
+		{
+			addr:     "echo.example:80",
+			expected: &Address{Service: "echo", NameSpace: "example", Port: 80},
+		},
+		{
+			addr: "echo.oneway:80",
+			err:  ErrInvalidAddress,
+		},
+		{
+			addr: "oneway:80",
+			err:  ErrInvalidAddress,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			actual, err := parser.Parse(tt.addr)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
@@ -6,23 +6,111 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
+var (
+	ErrIdleTimeout           = fmt.Errorf("idle timeout")
+	ErrLifetimeExceeded      = fmt.Errorf("connection lifetime exceeded")
+	ErrHandshakeTimeout      = fmt.Errorf("handshake timeout")
+	ErrHalfCloseNotSupported = fmt.Errorf("half-close is not supported")
+)
+
+const defaultLinger = 30 * time.Second
+
+// CloseReason is the reason the bridge is closed.
+type CloseReason string
+
+const (
+	// CloseReasonEOF means one of the connections is closed by its side.
+	CloseReasonEOF CloseReason = "eof"
+	// CloseReasonError means one of the connections has failed.
+	CloseReasonError CloseReason = "error"
+	// CloseReasonCanceled means the context of the bridge is done.
+	CloseReasonCanceled CloseReason = "canceled"
+	// CloseReasonIdleTimeout means no bytes are transferred in either direction within the idle timeout.
+	CloseReasonIdleTimeout CloseReason = "idle_timeout"
+	// CloseReasonLifetime means the bridge has run longer than the maximum lifetime.
+	CloseReasonLifetime CloseReason = "max_lifetime"
+	// CloseReasonHandshakeTimeout means no bytes are transferred within the handshake timeout.
+	CloseReasonHandshakeTimeout CloseReason = "handshake_timeout"
+)
+
+// Timeouts limits how long the bridged connections are kept open, zero disables the timeout.
+// Idle is the time without bytes transferred in either direction, Lifetime is the maximum duration of the bridge,
+// and Handshake is the time until the first bytes are transferred, after that the idle timeout applies.
+// Linger is the time without bytes transferred the other direction is waited for after one direction is half-closed,
+// it's 30 seconds by default.
+type Timeouts struct {
+	Idle      time.Duration `yaml:"idle" mapstructure:"idle"`
+	Lifetime  time.Duration `yaml:"lifetime" mapstructure:"lifetime"`
+	Handshake time.Duration `yaml:"handshake" mapstructure:"handshake"`
+	Linger    time.Duration `yaml:"linger" mapstructure:"linger"`
+}
+
+// limited reports whether any of the timeouts that close the running bridge is set.
+func (t Timeouts) limited() bool {
+	return t.Idle > 0 || t.Lifetime > 0 || t.Handshake > 0
+}
+
+// linger returns the time without bytes transferred the other direction is waited for after the half-close.
+func (t Timeouts) linger() time.Duration {
+	if t.Linger <= 0 {
+		return defaultLinger
+	}
+
+	return t.Linger
+}
+
+// deadline returns the time the bridge started at start and last active at lastActive is closed at
+// and the reason it is closed for. The zero time means the bridge isn't limited.
+// The zero lastActive means no bytes are transferred yet.
+func (t Timeouts) deadline(start, lastActive time.Time) (time.Time, error) {
+	var (
+		deadline time.Time
+		reason   error
+	)
+
+	limit := func(at time.Time, err error) {
+		if deadline.IsZero() || at.Before(deadline) {
+			deadline, reason = at, err
+		}
+	}
+
+	if t.Lifetime > 0 {
+		limit(start.Add(t.Lifetime), ErrLifetimeExceeded)
+	}
+
+	switch {
+	case lastActive.IsZero() && t.Handshake > 0:
+		limit(start.Add(t.Handshake), ErrHandshakeTimeout)
+	case lastActive.IsZero() && t.Idle > 0:
+		limit(start.Add(t.Idle), ErrIdleTimeout)
+	case t.Idle > 0:
+		limit(lastActive.Add(t.Idle), ErrIdleTimeout)
+	}
+
+	return deadline, reason
+}
+
 type Conn interface {
 	io.ReadWriteCloser
 }
 
 type Stats struct {
+	Reason   CloseReason
 	Sent     int64
 	Recv     int64
 	Duration time.Duration
 }
 
 type Bridge struct {
-	src  Conn
-	dest Conn
+	src      Conn
+	dest     Conn
+	limiters []*RateLimiter
+	timeouts Timeouts
 }
 
 // NewBridge creates a new Bridge instance that bridges the given source and destination io.ReadWriteClosers.
@@ -34,50 +122,228 @@ func NewBridge(src, dest Conn) *Bridge {
 	}
 }
 
+// SetTimeouts sets the timeouts the bridge is closed after, it must be called before Run.
+func (b *Bridge) SetTimeouts(timeouts Timeouts) {
+	b.timeouts = timeouts
+}
+
+// SetRateLimiters sets the bandwidth limiters of the bridge, it must be called before Run.
+// The limiters of the connections that implement RateLimited are applied too.
+func (b *Bridge) SetRateLimiters(limiters ...*RateLimiter) {
+	b.limiters = limiters
+}
+
+// rateLimiters returns the bandwidth limiters of the bridge and its connections.
+func (b *Bridge) rateLimiters() []*RateLimiter {
+	limiters := b.limiters
+
+	for _, conn := range []Conn{b.src, b.dest} {
+		if rl, ok := conn.(RateLimited); ok {
+			limiters = append(limiters, rl.RateLimiters()...)
+		}
+	}
+
+	return limiters
+}
+
 // Run executes the bridge operation, copying data bidirectionally between the source and destination.
-// It returns the statistics of the operation, including the number of bytes sent and received, and the duration of the operation.
-// If any errors occur during the operation, it returns joined errors.
+// When one direction reaches EOF, the half-close is propagated to the other connection if it supports CloseWrite,
+// and the other direction keeps running until it's finished too or no bytes are transferred within the linger timeout.
+// The bandwidth of both directions is shared within the rate limiters.
+// The bridge is closed when both directions are finished, one of the connections fails or doesn't support half-close,
+// the context is done or one of the timeouts expires.
+// It returns the statistics of the operation, including the number of bytes sent and received, the duration
+// of the operation and the reason the bridge is closed.
+// If any errors occur during the operation, it returns joined errors, the expired timeouts are not errors.
 func (b *Bridge) Run(ctx context.Context) (Stats, error) {
 	var sent, recv int64
 
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	parentCtx := ctx
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
 	startTime := time.Now()
 
 	const ExpectedErrors = 3
-	errCh := make(chan error, ExpectedErrors)
+	sentCh := make(chan error, 1)
+	recvCh := make(chan error, 1)
+	closeCh := make(chan error, 1)
+
+	var lastActive atomic.Int64
+
+	var src, dest io.ReadWriter = &activityConn{ReadWriter: b.src, lastActive: &lastActive},
+		&activityConn{ReadWriter: b.dest, lastActive: &lastActive}
 
-	startCopy(b.src, b.dest, &sent, errCh)
-	startCopy(b.dest, b.src, &recv, errCh)
+	if b.timeouts.limited() {
+		go b.watch(ctx, cancel, startTime, &lastActive)
+	}
+
+	if limiters := b.rateLimiters(); len(limiters) > 0 {
+		src = newLimitedConn(ctx, src, limiters)
+		dest = newLimitedConn(ctx, dest, limiters)
+	}
+
+	startCopy(src, dest, &sent, sentCh)
+	startCopy(dest, src, &recv, recvCh)
 
 	go func() {
 		<-ctx.Done()
-		errCh <- b.Close()
+		closeCh <- b.Close()
 	}()
 
 	errs := make([]error, 0, ExpectedErrors)
 
-	for i := 0; i < 3; i++ {
-		if err := <-errCh; err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, syscall.ECONNRESET) {
+	var (
+		reason CloseReason
+		linger <-chan time.Time
+	)
+
+	// stop closes the bridge, the reason is taken at the first stop.
+	stop := func() {
+		if reason == "" {
+			reason = closeReason(parentCtx, context.Cause(ctx))
+		}
+
+		cancel(nil)
+	}
+
+	for sentCh != nil || recvCh != nil {
+		var (
+			err  error
+			peer Conn
+		)
+
+		select {
+		case err = <-sentCh:
+			sentCh, peer = nil, b.dest
+		case err = <-recvCh:
+			recvCh, peer = nil, b.src
+		case <-linger:
+			// The linger timeout is counted from the last transferred bytes.
+			if wait := time.Until(time.Unix(0, lastActive.Load()).Add(b.timeouts.linger())); wait > 0 {
+				linger = time.After(wait)
+				continue
+			}
+
+			stop()
+
+			continue
+		}
+
+		if err != nil && !isClosedErr(err) {
 			errs = append(errs, err)
 		}
 
-		cancel()
+		if err == nil && ctx.Err() == nil && (sentCh != nil || recvCh != nil) && CloseWrite(peer) == nil {
+			linger = time.After(b.timeouts.linger())
+			continue
+		}
+
+		stop()
+	}
+
+	stop()
+
+	if err := <-closeCh; err != nil && !isClosedErr(err) {
+		errs = append(errs, err)
 	}
 
 	var err error
 	if len(errs) > 0 {
 		err = fmt.Errorf("error to run bridge: %w", errors.Join(errs...))
+
+		if reason == CloseReasonEOF {
+			reason = CloseReasonError
+		}
 	}
 
 	return Stats{
+		Reason:   reason,
 		Sent:     sent,
 		Recv:     recv,
 		Duration: time.Since(startTime),
 	}, err
 }
 
+// isClosedErr reports whether the error is caused by closing the connection, that is expected when the bridge is closed.
+func isClosedErr(err error) bool {
+	return errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// CloseWrite closes the sending direction of the connection, so the peer reads io.EOF
+// while the receiving direction stays open.
+// It returns ErrHalfCloseNotSupported if the connection doesn't support half-close.
+func CloseWrite(conn any) error {
+	cw, ok := conn.(interface{ CloseWrite() error })
+	if !ok {
+		return ErrHalfCloseNotSupported
+	}
+
+	return cw.CloseWrite()
+}
+
+// closeReason returns the reason of closing the bridge by the cause of its context at the moment it's stopped.
+func closeReason(parentCtx context.Context, cause error) CloseReason {
+	switch {
+	case errors.Is(cause, ErrIdleTimeout):
+		return CloseReasonIdleTimeout
+	case errors.Is(cause, ErrLifetimeExceeded):
+		return CloseReasonLifetime
+	case errors.Is(cause, ErrHandshakeTimeout):
+		return CloseReasonHandshakeTimeout
+	case parentCtx.Err() != nil:
+		return CloseReasonCanceled
+	default:
+		return CloseReasonEOF
+	}
+}
+
+// watch cancels the bridge with the expired timeout as the cause, it returns when the context is done.
+func (b *Bridge) watch(ctx context.Context, cancel context.CancelCauseFunc, start time.Time, lastActive *atomic.Int64) {
+	for {
+		var active time.Time
+		if ns := lastActive.Load(); ns != 0 {
+			active = time.Unix(0, ns)
+		}
+
+		deadline, reason := b.timeouts.deadline(start, active)
+		if deadline.IsZero() {
+			return
+		}
+
+		if !time.Now().Before(deadline) {
+			cancel(reason)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(deadline))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// activityConn records the time of the last transferred bytes.
+type activityConn struct {
+	io.ReadWriter
+	lastActive *atomic.Int64
+}
+
+func (c *activityConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriter.Read(p)
+	if n > 0 {
+		c.lastActive.Store(time.Now().UnixNano())
+	}
+
+	return n, err
+}
+
 func startCopy(src io.Reader, dest io.Writer, sent *int64, out chan<- error) {
 	go func() {
 		var err error
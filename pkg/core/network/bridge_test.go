@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -119,6 +120,7 @@ func TestBridge_Run(t *testing.T) {
 		stats, err := bridge.Run(context.Background())
 
 		assert.NoError(t, err)
+		assert.Equal(t, CloseReasonEOF, stats.Reason)
 		assert.Equal(t, int64(13), stats.Sent)
 		assert.Equal(t, int64(13), stats.Recv)
 		assert.GreaterOrEqual(t, stats.Duration.Milliseconds(), int64(0))
@@ -151,6 +153,7 @@ func TestBridge_Run_Error(t *testing.T) {
 
 		fmt.Println(err)
 		assert.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, CloseReasonError, stats.Reason)
 		assert.Equal(t, int64(0), stats.Sent)
 		assert.Equal(t, int64(0), stats.Recv)
 		assert.GreaterOrEqual(t, stats.Duration.Milliseconds(), int64(0))
@@ -164,3 +167,261 @@ func TestBridge_Run_Error(t *testing.T) {
 		t.Error("Expected Run to finish in 1 second")
 	}
 }
+
+func TestTimeouts_Deadline(t *testing.T) {
+	start := time.Now()
+	active := start.Add(time.Second)
+
+	tests := []struct {
+		lastActive   time.Time
+		wantDeadline time.Time
+		wantReason   error
+		name         string
+		timeouts     Timeouts
+	}{
+		{
+			name: "no timeouts",
+		},
+		{
+			name:         "handshake before first bytes",
+			timeouts:     Timeouts{Idle: 5 * time.Second, Handshake: 2 * time.Second},
+			wantDeadline: start.Add(2 * time.Second),
+			wantReason:   ErrHandshakeTimeout,
+		},
+		{
+			name:         "idle before first bytes without handshake",
+			timeouts:     Timeouts{Idle: 5 * time.Second},
+			wantDeadline: start.Add(5 * time.Second),
+			wantReason:   ErrIdleTimeout,
+		},
+		{
+			name:         "idle after first bytes",
+			timeouts:     Timeouts{Idle: 5 * time.Second, Handshake: 2 * time.Second},
+			lastActive:   active,
+			wantDeadline: active.Add(5 * time.Second),
+			wantReason:   ErrIdleTimeout,
+		},
+		{
+			name:         "lifetime is earlier",
+			timeouts:     Timeouts{Idle: 5 * time.Second, Lifetime: 3 * time.Second},
+			lastActive:   active,
+			wantDeadline: start.Add(3 * time.Second),
+			wantReason:   ErrLifetimeExceeded,
+		},
+		{
+			name:         "handshake only after first bytes",
+			timeouts:     Timeouts{Handshake: 2 * time.Second},
+			lastActive:   active,
+			wantDeadline: time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadline, reason := tt.timeouts.deadline(start, tt.lastActive)
+
+			assert.Equal(t, tt.wantDeadline, deadline)
+			assert.Equal(t, tt.wantReason, reason)
+		})
+	}
+}
+
+func TestBridge_Run_Timeouts(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantReason CloseReason
+		timeouts   Timeouts
+		wantSent   int64
+		activity   bool
+	}{
+		{
+			name:       "idle timeout",
+			timeouts:   Timeouts{Idle: 100 * time.Millisecond},
+			activity:   true,
+			wantSent:   4,
+			wantReason: CloseReasonIdleTimeout,
+		},
+		{
+			name:       "handshake timeout",
+			timeouts:   Timeouts{Handshake: 100 * time.Millisecond, Idle: time.Minute},
+			wantReason: CloseReasonHandshakeTimeout,
+		},
+		{
+			name:       "lifetime exceeded",
+			timeouts:   Timeouts{Lifetime: 200 * time.Millisecond, Idle: time.Minute},
+			activity:   true,
+			wantSent:   4,
+			wantReason: CloseReasonLifetime,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, client := net.Pipe()
+			dest, server := net.Pipe()
+
+			defer client.Close()
+			defer server.Close()
+
+			bridge := NewBridge(src, dest)
+			bridge.SetTimeouts(tt.timeouts)
+
+			if tt.activity {
+				go func() {
+					_, _ = client.Write([]byte("data"))
+					_, _ = io.ReadAll(server)
+				}()
+			}
+
+			stats, err := bridge.Run(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantReason, stats.Reason)
+			assert.Equal(t, tt.wantSent, stats.Sent)
+		})
+	}
+}
+
+func TestBridge_Run_Canceled(t *testing.T) {
+	src, client := net.Pipe()
+	dest, server := net.Pipe()
+
+	defer client.Close()
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	stats, err := NewBridge(src, dest).Run(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, CloseReasonCanceled, stats.Reason)
+}
+
+// tcpPair returns the connected ends of the TCP connection, they support half-close unlike net.Pipe.
+func tcpPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	return client.(*net.TCPConn), server.(*net.TCPConn)
+}
+
+func TestBridge_Run_HalfClose(t *testing.T) {
+	client, src := tcpPair(t)
+	dest, server := tcpPair(t)
+
+	go func() {
+		_, _ = client.Write([]byte("ping"))
+		_ = client.CloseWrite()
+	}()
+
+	go func() {
+		req, _ := io.ReadAll(server)
+		_, _ = server.Write(append(req, []byte(" pong")...))
+		_ = server.Close()
+	}()
+
+	done := make(chan []byte)
+
+	go func() {
+		resp, _ := io.ReadAll(client)
+		done <- resp
+	}()
+
+	stats, err := NewBridge(src, dest).Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, CloseReasonEOF, stats.Reason)
+	assert.Equal(t, int64(4), stats.Sent)
+	assert.Equal(t, int64(9), stats.Recv)
+	assert.Equal(t, "ping pong", string(<-done))
+}
+
+func TestBridge_Run_Linger(t *testing.T) {
+	client, src := tcpPair(t)
+	dest, _ := tcpPair(t)
+
+	assert.NoError(t, client.CloseWrite())
+
+	bridge := NewBridge(src, dest)
+	bridge.SetTimeouts(Timeouts{Linger: 100 * time.Millisecond})
+
+	start := time.Now()
+	stats, err := bridge.Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, CloseReasonEOF, stats.Reason)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestBridge_Run_LingerActivity(t *testing.T) {
+	client, src := tcpPair(t)
+	dest, server := tcpPair(t)
+
+	assert.NoError(t, client.CloseWrite())
+
+	// The server keeps sending data longer than the linger timeout after the client has half-closed.
+	go func() {
+		for i := 0; i < 10; i++ {
+			_, _ = server.Write([]byte("data"))
+
+			time.Sleep(30 * time.Millisecond)
+		}
+
+		_ = server.Close()
+	}()
+
+	done := make(chan []byte)
+
+	go func() {
+		resp, _ := io.ReadAll(client)
+		done <- resp
+	}()
+
+	bridge := NewBridge(src, dest)
+	bridge.SetTimeouts(Timeouts{Linger: 100 * time.Millisecond})
+
+	start := time.Now()
+	stats, err := bridge.Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, CloseReasonEOF, stats.Reason)
+	assert.Equal(t, int64(40), stats.Recv)
+	assert.GreaterOrEqual(t, time.Since(start), 270*time.Millisecond)
+	assert.Len(t, <-done, 40)
+}
+
+func TestCloseWrite(t *testing.T) {
+	client, server := tcpPair(t)
+
+	assert.NoError(t, CloseWrite(client))
+
+	data, err := io.ReadAll(server)
+
+	assert.NoError(t, err)
+	assert.Empty(t, data)
+
+	assert.ErrorIs(t, CloseWrite(NewMockConn(t)), ErrHalfCloseNotSupported)
+}
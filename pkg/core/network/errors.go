@@ -0,0 +1,42 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+var (
+	ErrConnRefused     = fmt.Errorf("connection refused")
+	ErrHostUnreachable = fmt.Errorf("host unreachable")
+	ErrNetUnreachable  = fmt.Errorf("network unreachable")
+	ErrConnTimeout     = fmt.Errorf("connection timed out")
+)
+
+// DialError wraps the error of dialing a destination with one of the sentinel errors
+// ErrConnRefused, ErrHostUnreachable, ErrNetUnreachable or ErrConnTimeout matching its cause.
+// The original error is kept in the chain.
+// If the cause is not recognized, the error is returned as is.
+func DialError(err error) error {
+	var (
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("%w: %w", ErrConnRefused, err)
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return fmt.Errorf("%w: %w", ErrHostUnreachable, err)
+	case errors.Is(err, syscall.ENETUNREACH):
+		return fmt.Errorf("%w: %w", ErrNetUnreachable, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %w", ErrConnTimeout, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,62 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected error
+		name     string
+	}{
+		{
+			name:     "connection refused",
+			err:      &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expected: ErrConnRefused,
+		},
+		{
+			name:     "host unreachable",
+			err:      &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)},
+			expected: ErrHostUnreachable,
+		},
+		{
+			name:     "host not found",
+			err:      &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "example", IsNotFound: true}},
+			expected: ErrHostUnreachable,
+		},
+		{
+			name:     "network unreachable",
+			err:      &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ENETUNREACH)},
+			expected: ErrNetUnreachable,
+		},
+		{
+			name:     "timeout",
+			err:      fmt.Errorf("failed to dial: %w", context.DeadlineExceeded),
+			expected: ErrConnTimeout,
+		},
+		{
+			name:     "unknown error",
+			err:      assert.AnError,
+			expected: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := DialError(tt.err)
+
+			assert.ErrorIs(t, err, tt.expected)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	assert.NoError(t, DialError(nil))
+}
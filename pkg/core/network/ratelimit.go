@@ -0,0 +1,190 @@
+package network
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimit is the configuration of the rate limits, zero disables the limit.
+// Bandwidth is the number of bytes per second transferred in both directions,
+// Connections is the number of new connections per second and Burst is the number of connections
+// that can be opened at once, by default it's Connections rounded up.
+type RateLimit struct {
+	Bandwidth   int64   `yaml:"bandwidth" mapstructure:"bandwidth"`
+	Connections float64 `yaml:"connections" mapstructure:"connections"`
+	Burst       int     `yaml:"burst" mapstructure:"burst"`
+}
+
+// ConnectionLimiter returns the limiter of new connections, it's nil if the limit is disabled.
+// onLimited is called every time the limit is hit, it can be nil.
+func (r RateLimit) ConnectionLimiter(onLimited func()) *RateLimiter {
+	if r.Connections <= 0 {
+		return nil
+	}
+
+	burst := r.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(r.Connections))
+	}
+
+	return NewRateLimiter(r.Connections, burst, onLimited)
+}
+
+// BandwidthLimiter returns the limiter of transferred bytes, it's nil if the limit is disabled.
+// The burst is one second of the bandwidth. onLimited is called every time the limit is hit, it can be nil.
+func (r RateLimit) BandwidthLimiter(onLimited func()) *RateLimiter {
+	if r.Bandwidth <= 0 {
+		return nil
+	}
+
+	return NewRateLimiter(float64(r.Bandwidth), int(r.Bandwidth), onLimited)
+}
+
+// RateLimited is implemented by connections that are subject to the bandwidth limits,
+// the bridge applies the limiters of both of its connections.
+type RateLimited interface {
+	RateLimiters() []*RateLimiter
+}
+
+// RateLimiter is the token bucket that is refilled at the rate of tokens per second up to the burst.
+// It's safe for concurrent use, so the limit can be shared by many connections.
+type RateLimiter struct {
+	last      time.Time
+	onLimited func()
+	rate      float64
+	burst     float64
+	tokens    float64
+	mu        sync.Mutex
+}
+
+// NewRateLimiter creates the rate limiter that starts with the full bucket.
+// onLimited is called every time the limit is hit, it can be nil.
+func NewRateLimiter(rate float64, burst int, onLimited func()) *RateLimiter {
+	burst = max(burst, 1)
+
+	return &RateLimiter{
+		rate:      rate,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+		onLimited: onLimited,
+	}
+}
+
+// Burst returns the maximum number of tokens that can be taken at once without waiting.
+func (l *RateLimiter) Burst() int {
+	return int(l.burst)
+}
+
+// Allow takes one token if it's available, otherwise it reports that the limit is hit.
+func (l *RateLimiter) Allow() bool {
+	l.mu.Lock()
+	l.refill()
+
+	ok := l.tokens >= 1
+	if ok {
+		l.tokens--
+	}
+
+	l.mu.Unlock()
+
+	if !ok {
+		l.limited()
+	}
+
+	return ok
+}
+
+// Cancel returns the token taken by Allow, e.g. when the connection is denied by another limit.
+func (l *RateLimiter) Cancel() {
+	l.mu.Lock()
+	l.refill()
+	l.tokens = min(l.burst, l.tokens+1)
+	l.mu.Unlock()
+}
+
+// WaitN takes n tokens, if they are not available, it waits until they are refilled or the context is done.
+// The tokens are taken in advance, so the concurrent callers wait in turn.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	l.refill()
+	l.tokens -= float64(n)
+	debt := -l.tokens
+	l.mu.Unlock()
+
+	if debt <= 0 {
+		return nil
+	}
+
+	l.limited()
+
+	timer := time.NewTimer(time.Duration(debt / l.rate * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refill adds the tokens accumulated since the last call, it must be called with the lock held.
+func (l *RateLimiter) refill() {
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+func (l *RateLimiter) limited() {
+	if l.onLimited != nil {
+		l.onLimited()
+	}
+}
+
+// limitedConn waits for the bandwidth limiters after every read.
+// The reads are not larger than the smallest burst, so the transfer is smoothed.
+type limitedConn struct {
+	io.ReadWriter
+	ctx      context.Context
+	limiters []*RateLimiter
+	chunk    int
+}
+
+func newLimitedConn(ctx context.Context, conn io.ReadWriter, limiters []*RateLimiter) *limitedConn {
+	chunk := math.MaxInt
+	for _, l := range limiters {
+		chunk = min(chunk, l.Burst())
+	}
+
+	return &limitedConn{
+		ReadWriter: conn,
+		ctx:        ctx,
+		limiters:   limiters,
+		chunk:      chunk,
+	}
+}
+
+// Read reads up to the chunk and waits for the limiters, it fails with net.ErrClosed if the bridge is closed meanwhile.
+func (c *limitedConn) Read(p []byte) (int, error) {
+	if len(p) > c.chunk {
+		p = p[:c.chunk]
+	}
+
+	n, err := c.ReadWriter.Read(p)
+	if n == 0 {
+		return n, err
+	}
+
+	for _, l := range c.limiters {
+		if errW := l.WaitN(c.ctx, n); errW != nil {
+			return n, net.ErrClosed
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,106 @@
+package network
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit_Limiters(t *testing.T) {
+	assert.Nil(t, RateLimit{}.ConnectionLimiter(nil))
+	assert.Nil(t, RateLimit{}.BandwidthLimiter(nil))
+
+	conns := RateLimit{Connections: 2.5}.ConnectionLimiter(nil)
+	assert.Equal(t, 3, conns.Burst())
+
+	conns = RateLimit{Connections: 2.5, Burst: 10}.ConnectionLimiter(nil)
+	assert.Equal(t, 10, conns.Burst())
+
+	bandwidth := RateLimit{Bandwidth: 1024}.BandwidthLimiter(nil)
+	assert.Equal(t, 1024, bandwidth.Burst())
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	hits := 0
+	limiter := NewRateLimiter(10, 2, func() { hits++ })
+
+	assert.True(t, limiter.Allow())
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Allow())
+	assert.Equal(t, 1, hits)
+
+	time.Sleep(150 * time.Millisecond)
+
+	assert.True(t, limiter.Allow())
+}
+
+func TestRateLimiter_Cancel(t *testing.T) {
+	limiter := NewRateLimiter(0.001, 1, nil)
+
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Allow())
+
+	limiter.Cancel()
+
+	assert.True(t, limiter.Allow())
+
+	// The returned tokens don't exceed the burst.
+	limiter.Cancel()
+	limiter.Cancel()
+
+	assert.True(t, limiter.Allow())
+	assert.False(t, limiter.Allow())
+}
+
+func TestRateLimiter_WaitN(t *testing.T) {
+	hits := 0
+	limiter := NewRateLimiter(1000, 100, func() { hits++ })
+
+	start := time.Now()
+
+	assert.NoError(t, limiter.WaitN(context.Background(), 100))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, 0, hits)
+
+	assert.NoError(t, limiter.WaitN(context.Background(), 100))
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Equal(t, 1, hits)
+}
+
+func TestRateLimiter_WaitN_Canceled(t *testing.T) {
+	limiter := NewRateLimiter(1, 1, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.NoError(t, limiter.WaitN(ctx, 1))
+	assert.ErrorIs(t, limiter.WaitN(ctx, 1), context.Canceled)
+}
+
+func TestBridge_Run_RateLimited(t *testing.T) {
+	client, src := tcpPair(t)
+	dest, server := tcpPair(t)
+
+	go func() {
+		_, _ = client.Write(make([]byte, 3000))
+		_ = client.Close()
+	}()
+
+	go func() {
+		_, _ = io.Copy(io.Discard, server)
+		_ = server.Close()
+	}()
+
+	bridge := NewBridge(src, dest)
+	bridge.SetRateLimiters(NewRateLimiter(2000, 2000, nil))
+
+	start := time.Now()
+	stats, err := bridge.Run(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3000), stats.Sent)
+	assert.GreaterOrEqual(t, time.Since(start), 450*time.Millisecond)
+}
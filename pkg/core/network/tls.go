@@ -0,0 +1,113 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+var ErrNoCertificate = fmt.Errorf("certificate and key files are required")
+
+type TLSConfig struct {
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	CAFile     string `mapstructure:"ca_file"`
+	ServerName string `mapstructure:"server_name"`
+	ClientAuth bool   `mapstructure:"client_auth"`
+}
+
+// ServerConfig builds the TLS configuration for a server.
+// It returns nil if the configuration is nil, which means that TLS is disabled.
+// The certificate and key files are required.
+// If ClientAuth is enabled, clients are required to present a certificate signed by the CA from CAFile,
+// if CAFile is empty, the system certificate pool is used instead.
+func (c *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, ErrNoCertificate
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if !c.ClientAuth {
+		return cfg, nil
+	}
+
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+
+	if c.CAFile != "" {
+		if cfg.ClientCAs, err = loadCertPool(c.CAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// ClientConfig builds the TLS configuration for a client.
+// It returns nil if the configuration is nil, which means that TLS is disabled.
+// If CAFile is set, the server certificate is verified against it instead of the system certificate pool.
+// If the certificate and key files are set, the client presents the certificate to the server.
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		ServerName: c.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	var err error
+
+	if c.CAFile != "" {
+		if cfg.RootCAs, err = loadCertPool(c.CAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.CertFile == "" && c.KeyFile == "" {
+		return cfg, nil
+	}
+
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, ErrNoCertificate
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+
+	cfg.Certificates = []tls.Certificate{cert}
+
+	return cfg, nil
+}
+
+// loadCertPool reads PEM encoded certificates from the given file and returns them as a certificate pool.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", path)
+	}
+
+	return pool, nil
+}
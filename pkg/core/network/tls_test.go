@@ -0,0 +1,136 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func TestTLSConfig_Nil(t *testing.T) {
+	var cfg *TLSConfig
+
+	srvCfg, err := cfg.ServerConfig()
+
+	assert.NoError(t, err)
+	assert.Nil(t, srvCfg)
+
+	clientCfg, err := cfg.ClientConfig()
+
+	assert.NoError(t, err)
+	assert.Nil(t, clientCfg)
+}
+
+func TestTLSConfig_ServerConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	tests := []struct {
+		cfg        *TLSConfig
+		err        error
+		name       string
+		clientAuth tls.ClientAuthType
+		wantCAs    bool
+	}{
+		{
+			name: "server TLS",
+			cfg:  &TLSConfig{CertFile: certFile, KeyFile: keyFile},
+		},
+		{
+			name:       "mutual TLS",
+			cfg:        &TLSConfig{CertFile: certFile, KeyFile: keyFile, CAFile: certFile, ClientAuth: true},
+			clientAuth: tls.RequireAndVerifyClientCert,
+			wantCAs:    true,
+		},
+		{
+			name: "missing certificate",
+			cfg:  &TLSConfig{KeyFile: keyFile},
+			err:  ErrNoCertificate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.cfg.ServerConfig()
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Nil(t, cfg)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, cfg.Certificates, 1)
+			assert.Equal(t, tt.clientAuth, cfg.ClientAuth)
+			assert.Equal(t, tt.wantCAs, cfg.ClientCAs != nil)
+		})
+	}
+}
+
+func TestTLSConfig_ClientConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	cfg, err := (&TLSConfig{CAFile: certFile, ServerName: "example"}).ClientConfig()
+
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg.RootCAs)
+	assert.Equal(t, "example", cfg.ServerName)
+	assert.Empty(t, cfg.Certificates)
+
+	cfg, err = (&TLSConfig{CertFile: certFile, KeyFile: keyFile}).ClientConfig()
+
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Certificates, 1)
+
+	_, err = (&TLSConfig{CertFile: certFile}).ClientConfig()
+
+	assert.ErrorIs(t, err, ErrNoCertificate)
+
+	_, err = (&TLSConfig{CAFile: keyFile}).ClientConfig()
+
+	assert.Error(t, err)
+}
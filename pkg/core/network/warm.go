@@ -0,0 +1,114 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A warm connection is a reverse connection that is established in advance and kept idle until it's needed.
+// The exchange activates it by sending WarmActivate followed by the requested port (2 bytes, big endian),
+// the revproxy dials the destination and replies with a single byte: WarmAccepted or the code of the dial error.
+const (
+	WarmActivate byte = 1
+	WarmAccepted byte = 0
+)
+
+const warmActivationLength = 3
+
+const (
+	warmDialFailed byte = iota + 1
+	warmConnRefused
+	warmHostUnreachable
+	warmNetUnreachable
+	warmConnTimeout
+)
+
+var (
+	ErrDialFailed         = fmt.Errorf("failed to dial destination")
+	ErrInvalidWarmMessage = fmt.Errorf("invalid warm connection message")
+)
+
+// ActivateWarmConn activates the warm connection for the requested port and waits for the reply of the revproxy.
+// If the revproxy fails to dial the destination, the error corresponding to the reply code is returned
+// wrapped with ErrDialFailed, the errors of reading and writing the connection are returned as is.
+func ActivateWarmConn(conn io.ReadWriter, port uint16) error {
+	msg := make([]byte, warmActivationLength)
+	msg[0] = WarmActivate
+	binary.BigEndian.PutUint16(msg[1:], port)
+
+	if _, err := conn.Write(msg); err != nil {
+		return fmt.Errorf("failed to activate warm connection: %w", err)
+	}
+
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("failed to read warm connection reply: %w", err)
+	}
+
+	return warmReplyError(reply[0])
+}
+
+// AwaitWarmActivation blocks until the exchange activates the warm connection.
+// It returns the requested port, zero means that the port is not specified.
+func AwaitWarmActivation(conn io.Reader) (uint16, error) {
+	msg := make([]byte, warmActivationLength)
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return 0, fmt.Errorf("failed to read warm connection activation: %w", err)
+	}
+
+	if msg[0] != WarmActivate {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidWarmMessage, msg[0])
+	}
+
+	return binary.BigEndian.Uint16(msg[1:]), nil
+}
+
+// ReplyWarmActivation sends the result of dialing the destination for the activated warm connection.
+func ReplyWarmActivation(conn io.Writer, dialErr error) error {
+	if _, err := conn.Write([]byte{warmReplyCode(dialErr)}); err != nil {
+		return fmt.Errorf("failed to write warm connection reply: %w", err)
+	}
+
+	return nil
+}
+
+// warmReplyCode returns the reply code for the error of dialing the destination.
+func warmReplyCode(err error) byte {
+	switch {
+	case err == nil:
+		return WarmAccepted
+	case errors.Is(err, ErrConnRefused):
+		return warmConnRefused
+	case errors.Is(err, ErrHostUnreachable):
+		return warmHostUnreachable
+	case errors.Is(err, ErrNetUnreachable):
+		return warmNetUnreachable
+	case errors.Is(err, ErrConnTimeout):
+		return warmConnTimeout
+	default:
+		return warmDialFailed
+	}
+}
+
+// warmReplyError returns the error that corresponds to the reply code.
+// All dial errors are wrapped with ErrDialFailed.
+func warmReplyError(code byte) error {
+	switch code {
+	case WarmAccepted:
+		return nil
+	case warmConnRefused:
+		return fmt.Errorf("%w: %w", ErrDialFailed, ErrConnRefused)
+	case warmHostUnreachable:
+		return fmt.Errorf("%w: %w", ErrDialFailed, ErrHostUnreachable)
+	case warmNetUnreachable:
+		return fmt.Errorf("%w: %w", ErrDialFailed, ErrNetUnreachable)
+	case warmConnTimeout:
+		return fmt.Errorf("%w: %w", ErrDialFailed, ErrConnTimeout)
+	case warmDialFailed:
+		return ErrDialFailed
+	default:
+		return fmt.Errorf("%w: %d", ErrInvalidWarmMessage, code)
+	}
+}
@@ -0,0 +1,98 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmActivation(t *testing.T) {
+	tests := []struct {
+		dialErr  error
+		expected error
+		name     string
+	}{
+		{
+			name: "accepted",
+		},
+		{
+			name:     "connection refused",
+			dialErr:  ErrConnRefused,
+			expected: ErrConnRefused,
+		},
+		{
+			name:     "host unreachable",
+			dialErr:  ErrHostUnreachable,
+			expected: ErrHostUnreachable,
+		},
+		{
+			name:     "network unreachable",
+			dialErr:  ErrNetUnreachable,
+			expected: ErrNetUnreachable,
+		},
+		{
+			name:     "timeout",
+			dialErr:  ErrConnTimeout,
+			expected: ErrConnTimeout,
+		},
+		{
+			name:     "unknown error",
+			dialErr:  assert.AnError,
+			expected: ErrDialFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchangeSide, revProxySide := net.Pipe()
+
+			defer exchangeSide.Close()
+			defer revProxySide.Close()
+
+			go func() {
+				port, err := AwaitWarmActivation(revProxySide)
+				if err != nil {
+					return
+				}
+
+				dialErr := tt.dialErr
+				if port != 8080 {
+					dialErr = assert.AnError
+				}
+
+				_ = ReplyWarmActivation(revProxySide, dialErr)
+			}()
+
+			err := ActivateWarmConn(exchangeSide, 8080)
+
+			if tt.expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.expected)
+				assert.ErrorIs(t, err, ErrDialFailed)
+			}
+		})
+	}
+}
+
+func TestWarmActivation_InvalidMessage(t *testing.T) {
+	_, err := AwaitWarmActivation(bytes.NewReader([]byte{42, 0, 80}))
+	assert.ErrorIs(t, err, ErrInvalidWarmMessage)
+
+	_, err = AwaitWarmActivation(bytes.NewReader(nil))
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = AwaitWarmActivation(bytes.NewReader([]byte{WarmActivate}))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	conn := struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader([]byte{42}), io.Discard}
+
+	err = ActivateWarmConn(conn, 80)
+	assert.ErrorIs(t, err, ErrInvalidWarmMessage)
+}
@@ -4,6 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/ksysoev/oneway/pkg/core/network"
 	"go.opentelemetry.io/otel"
@@ -13,37 +19,125 @@ import (
 
 var meter = otel.GetMeterProvider().Meter("oneway")
 
+var (
+	ErrServiceNotFound = fmt.Errorf("service not found")
+	ErrBridgeFailed    = fmt.Errorf("bridge failed")
+	ErrPortNotExposed  = fmt.Errorf("port is not exposed")
+)
+
+// ResolveFunc returns the destination address for the requested port.
+type ResolveFunc func(port uint16) (string, error)
+
 type BridgeProvider interface {
-	CreateConnection(ctx context.Context, id uint64, addr string) (*network.Bridge, error)
+	CreateConnection(ctx context.Context, id uint64, serviceName, addr string) (*network.Bridge, error)
+	CreateWarmConnection(ctx context.Context, id uint64, serviceName string, resolve ResolveFunc) (*network.Bridge, error)
 }
 
+const (
+	defaultGracePeriod = 20 * time.Second
+	drainPollInterval  = 100 * time.Millisecond
+)
+
+// Config is the configuration of the revproxy services.
+// GracePeriod limits how long the active bridges are waited for on shutdown before they are closed.
 type Config struct {
-	NameSpace string           `yaml:"namespace"`
-	CtrlAPI   string           `mapstructure:"ctrl_api"`
-	Services  []ServiceCongfig `yaml:"services"`
+	NameSpace   string           `yaml:"namespace"`
+	Services    []ServiceCongfig `yaml:"services"`
+	GracePeriod time.Duration    `yaml:"grace_period" mapstructure:"grace_period"`
 }
 
+// ServiceCongfig is the configuration of the service exposed by the revproxy.
+// WarmPool is the number of idle connections kept established to the exchange for the service.
+// Ports maps the requested ports to the ports of the host from Address, only these ports are exposed.
+// If PassThrough is enabled, the requested port is dialed on the host as is.
+// If neither is configured, all requests are forwarded to Address regardless of the requested port.
+// Name can be a wildcard pattern in the path.Match syntax, e.g. "*" or "api-*", then the "{service}" placeholder
+// in Address is replaced with the requested service name. The warm pool is not supported for wildcard services.
+// Timeouts limit how long the connections to the service are bridged.
 type ServiceCongfig struct {
-	Name    string `yaml:"name"`
-	Address string `yaml:"address"`
+	Ports       map[uint16]uint16 `yaml:"ports" mapstructure:"ports"`
+	Name        string            `yaml:"name"`
+	Address     string            `yaml:"address"`
+	Timeouts    network.Timeouts  `yaml:"timeouts" mapstructure:"timeouts"`
+	WarmPool    int               `yaml:"warm_pool" mapstructure:"warm_pool"`
+	PassThrough bool              `yaml:"pass_through" mapstructure:"pass_through"`
+}
+
+// destination returns the address to dial for the requested service and port, zero port means the configured Address.
+// It returns ErrPortNotExposed, that is reported to the client as the refused connection, if the port is not mapped.
+func (c *ServiceCongfig) destination(serviceName string, port uint16) (string, error) {
+	addr := strings.ReplaceAll(c.Address, "{service}", serviceName)
+
+	if port == 0 || (len(c.Ports) == 0 && !c.PassThrough) {
+		return addr, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	if mapped, ok := c.Ports[port]; ok {
+		return net.JoinHostPort(host, strconv.Itoa(int(mapped))), nil
+	}
+
+	if c.PassThrough {
+		return net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+	}
+
+	return "", fmt.Errorf("%w: %w: port %d of service %s", ErrPortNotExposed, network.ErrConnRefused, port, serviceName)
+}
+
+// isPattern reports whether the service name is a wildcard pattern.
+func (c *ServiceCongfig) isPattern() bool {
+	return strings.ContainsAny(c.Name, `*?[\`)
 }
 
 type RCPService struct {
-	config     *Config
-	srvcIndx   map[string]string
-	bridgeProv BridgeProvider
+	bridgeProv    BridgeProvider
+	bridgesCtx    context.Context
+	config        *Config
+	srvcIndx      map[string]*ServiceCongfig
+	activeBridges map[string]*atomic.Int64
+	cancelBridges context.CancelFunc
+	patterns      []*ServiceCongfig
+	gracePeriod   time.Duration
 }
 
 func New(cfg *Config, bridgeProv BridgeProvider) *RCPService {
-	srvcIndx := make(map[string]string)
-	for _, service := range cfg.Services {
-		srvcIndx[service.Name] = service.Address
+	srvcIndx := make(map[string]*ServiceCongfig)
+	activeBridges := make(map[string]*atomic.Int64)
+
+	var patterns []*ServiceCongfig
+
+	for i := range cfg.Services {
+		service := &cfg.Services[i]
+		activeBridges[service.Name] = &atomic.Int64{}
+
+		if service.isPattern() {
+			patterns = append(patterns, service)
+			continue
+		}
+
+		srvcIndx[service.Name] = service
 	}
 
+	gracePeriod := cfg.GracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = defaultGracePeriod
+	}
+
+	bridgesCtx, cancelBridges := context.WithCancel(context.Background())
+
 	return &RCPService{
-		config:     cfg,
-		srvcIndx:   srvcIndx,
-		bridgeProv: bridgeProv,
+		config:        cfg,
+		srvcIndx:      srvcIndx,
+		patterns:      patterns,
+		activeBridges: activeBridges,
+		bridgeProv:    bridgeProv,
+		bridgesCtx:    bridgesCtx,
+		cancelBridges: cancelBridges,
+		gracePeriod:   gracePeriod,
 	}
 }
 
@@ -51,34 +145,108 @@ func (s *RCPService) NameSpace() string {
 	return s.config.NameSpace
 }
 
+// lookup returns the configuration of the service with the given name.
+// The exact name takes precedence, otherwise wildcard patterns are matched in the configured order.
+func (s *RCPService) lookup(serviceName string) (*ServiceCongfig, error) {
+	if service, ok := s.srvcIndx[serviceName]; ok {
+		return service, nil
+	}
+
+	for _, service := range s.patterns {
+		if ok, _ := path.Match(service.Name, serviceName); ok {
+			return service, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceName)
+}
+
+// CreateConnection connects the requested port of the service with the exchange for the connection request
+// with the given id and bridges the connections until one of them is closed.
+// If the connection can't be established, the error can be reported back to the exchange,
+// the errors that happen after the bridge is established are wrapped with ErrBridgeFailed.
 // TODO Do i need namespace here as argument?
-func (s *RCPService) CreateConnection(ctx context.Context, _, serviceName string, id uint64) error {
+func (s *RCPService) CreateConnection(ctx context.Context, _, serviceName string, id uint64, port uint16) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	dest, ok := s.srvcIndx[serviceName]
-	if !ok {
-		return fmt.Errorf("service not found")
+	service, err := s.lookup(serviceName)
+	if err != nil {
+		return err
+	}
+
+	dest, err := service.destination(serviceName, port)
+	if err != nil {
+		return err
 	}
 
-	bridge, err := s.bridgeProv.CreateConnection(ctx, id, dest)
+	bridge, err := s.bridgeProv.CreateConnection(ctx, id, serviceName, dest)
 	if err != nil {
 		return fmt.Errorf("failed to create bridge: %w", err)
 	}
 
-	stats, err := bridge.Run(ctx)
+	return s.runBridge(ctx, service, serviceName, bridge)
+}
+
+// CreateWarmConnection establishes the warm connection to the exchange for the connection request with the given id.
+// The connection stays idle until the exchange activates it, then the destination for the requested port is dialed
+// and the connections are bridged until one of them is closed.
+// The errors that happen after the bridge is established are wrapped with ErrBridgeFailed.
+func (s *RCPService) CreateWarmConnection(ctx context.Context, _, serviceName string, id uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	service, err := s.lookup(serviceName)
+	if err != nil {
+		return err
+	}
+
+	resolve := func(port uint16) (string, error) {
+		return service.destination(serviceName, port)
+	}
+
+	bridge, err := s.bridgeProv.CreateWarmConnection(ctx, id, serviceName, resolve)
+	if err != nil {
+		return fmt.Errorf("failed to create warm bridge: %w", err)
+	}
+
+	return s.runBridge(ctx, service, serviceName, bridge)
+}
+
+// runBridge runs the bridge for the service within its timeouts and records its metrics.
+// The bridge isn't bound to the context of the request, so it outlives the shutdown until it's cut by Drain.
+// The metrics and the active bridges of wildcard services are recorded by the pattern to keep their cardinality bounded,
+// the log has both the requested service name and the pattern it matched.
+func (s *RCPService) runBridge(ctx context.Context, service *ServiceCongfig, requested string, bridge *network.Bridge) error {
+	serviceName := service.Name
+
+	active := s.activeBridges[serviceName]
+	active.Add(1)
+
+	bridge.SetTimeouts(service.Timeouts)
+	stats, err := bridge.Run(s.bridgesCtx)
+
+	active.Add(-1)
 
 	counter, _ := meter.Int64Counter("transmitted_bytes")
 	counter.Add(ctx, stats.Sent, metric.WithAttributes(attribute.String("service", serviceName), attribute.String("direction", "sent")))
 	counter.Add(ctx, stats.Recv, metric.WithAttributes(attribute.String("service", serviceName), attribute.String("direction", "received")))
 	timing, _ := meter.Float64Histogram("connection_duration", metric.WithDescription("Connection duration in milliseconds"), metric.WithUnit("s"))
-	timing.Record(ctx, stats.Duration.Seconds(), metric.WithAttributes(attribute.String("service", serviceName)))
+	timing.Record(ctx, stats.Duration.Seconds(), metric.WithAttributes(attribute.String("service", serviceName), attribute.String("reason", string(stats.Reason))))
+
+	slog.Debug("bridge is closed",
+		slog.String("service", requested),
+		slog.String("pattern", serviceName),
+		slog.String("reason", string(stats.Reason)),
+		slog.Duration("duration", stats.Duration),
+	)
 
 	if err != nil {
-		slog.Error("failed to run bridge", slog.Any("error", err))
+		slog.Error("failed to run bridge", slog.String("service", requested), slog.String("pattern", serviceName), slog.Any("error", err))
+		return fmt.Errorf("%w: %w", ErrBridgeFailed, err)
 	}
 
-	return err
+	return nil
 }
 
 func (s *RCPService) ServiceNames() []string {
@@ -89,3 +257,76 @@ func (s *RCPService) ServiceNames() []string {
 
 	return serviceNames
 }
+
+// ActiveBridges returns the number of currently running bridges for each service.
+func (s *RCPService) ActiveBridges() map[string]int64 {
+	bridges := make(map[string]int64, len(s.activeBridges))
+	for name, active := range s.activeBridges {
+		bridges[name] = active.Load()
+	}
+
+	return bridges
+}
+
+// Drain waits for the active bridges to finish up to the grace period or until the context is done,
+// then it closes the remaining bridges. The cut bridges are logged and counted by the drain_cut_bridges metric.
+// It returns the number of cut bridges for each service, it's empty if all bridges have finished in time.
+func (s *RCPService) Drain(ctx context.Context) map[string]int64 {
+	ctx, cancel := context.WithTimeout(ctx, s.gracePeriod)
+	defer cancel()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	slog.InfoContext(ctx, "revproxy is draining", slog.Any("active_bridges", s.runningBridges()), slog.Duration("grace_period", s.gracePeriod))
+
+	for {
+		active := s.runningBridges()
+		if len(active) == 0 {
+			slog.Info("revproxy is drained")
+			return active
+		}
+
+		select {
+		case <-ctx.Done():
+			s.cancelBridges()
+
+			cutCounter, _ := meter.Int64Counter("drain_cut_bridges", metric.WithDescription("Number of bridges closed after the drain grace period"))
+			for name, count := range active {
+				cutCounter.Add(context.Background(), count, metric.WithAttributes(attribute.String("service", name)))
+			}
+
+			slog.Warn("bridges are cut after the grace period", slog.Any("services", active))
+
+			return active
+		case <-ticker.C:
+		}
+	}
+}
+
+// runningBridges returns the number of running bridges for the services that have them.
+func (s *RCPService) runningBridges() map[string]int64 {
+	running := make(map[string]int64)
+
+	for name, count := range s.ActiveBridges() {
+		if count > 0 {
+			running[name] = count
+		}
+	}
+
+	return running
+}
+
+// WarmPool returns the sizes of warm connection pools for services that have them configured.
+// Wildcard services are skipped, the exchange doesn't support warm pools for them.
+func (s *RCPService) WarmPool() map[string]int {
+	warmPool := make(map[string]int)
+
+	for _, service := range s.config.Services {
+		if service.WarmPool > 0 && !service.isPattern() {
+			warmPool[service.Name] = service.WarmPool
+		}
+	}
+
+	return warmPool
+}
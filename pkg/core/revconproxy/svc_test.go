@@ -0,0 +1,168 @@
+package revconproxy
+
+import (
+	"testing"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceConfig_Destination(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		config  ServiceCongfig
+		port    uint16
+		wantErr bool
+	}{
+		{
+			name:   "zero port",
+			config: ServiceCongfig{Address: "host:8080", Ports: map[uint16]uint16{80: 9090}},
+			want:   "host:8080",
+		},
+		{
+			name:   "no ports configured",
+			config: ServiceCongfig{Address: "host:8080"},
+			port:   443,
+			want:   "host:8080",
+		},
+		{
+			name:   "mapped port",
+			config: ServiceCongfig{Address: "host:8080", Ports: map[uint16]uint16{80: 9090}},
+			port:   80,
+			want:   "host:9090",
+		},
+		{
+			name:   "pass-through",
+			config: ServiceCongfig{Address: "host:8080", PassThrough: true},
+			port:   22,
+			want:   "host:22",
+		},
+		{
+			name:   "mapped port with pass-through",
+			config: ServiceCongfig{Address: "host:8080", Ports: map[uint16]uint16{80: 9090}, PassThrough: true},
+			port:   80,
+			want:   "host:9090",
+		},
+		{
+			name:   "unmapped port with pass-through",
+			config: ServiceCongfig{Address: "host:8080", Ports: map[uint16]uint16{80: 9090}, PassThrough: true},
+			port:   81,
+			want:   "host:81",
+		},
+		{
+			name:    "unmapped port",
+			config:  ServiceCongfig{Address: "host:8080", Ports: map[uint16]uint16{80: 9090}},
+			port:    81,
+			wantErr: true,
+		},
+		{
+			name:   "address without port",
+			config: ServiceCongfig{Address: "host", Ports: map[uint16]uint16{80: 9090}},
+			port:   80,
+			want:   "host:9090",
+		},
+		{
+			name:   "address without port with pass-through",
+			config: ServiceCongfig{Address: "host", PassThrough: true},
+			port:   22,
+			want:   "host:22",
+		},
+		{
+			name:   "IPv6 address",
+			config: ServiceCongfig{Address: "[::1]:8080", Ports: map[uint16]uint16{80: 9090}},
+			port:   80,
+			want:   "[::1]:9090",
+		},
+		{
+			name:   "service placeholder",
+			config: ServiceCongfig{Name: "*", Address: "{service}.internal:8080", Ports: map[uint16]uint16{80: 9090}},
+			port:   80,
+			want:   "api.internal:9090",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.destination("api", tt.port)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrPortNotExposed)
+				assert.ErrorIs(t, err, network.ErrConnRefused)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServiceConfig_IsPattern(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "service1", want: false},
+		{name: "api.internal", want: false},
+		{name: "*", want: true},
+		{name: "api-*", want: true},
+		{name: "api-?", want: true},
+		{name: "api-[0-9]", want: true},
+		{name: `api\-1`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, (&ServiceCongfig{Name: tt.name}).isPattern())
+		})
+	}
+}
+
+func TestRCPService_Lookup(t *testing.T) {
+	svc := New(&Config{Services: []ServiceCongfig{
+		{Name: "api-*", Address: "{service}.api:8080"},
+		{Name: "api-admin", Address: "admin:8080"},
+		{Name: "api-?", Address: "short:8080"},
+		{Name: "*", Address: "{service}.internal:80"},
+	}}, nil)
+
+	tests := []struct {
+		name        string
+		service     string
+		wantPattern string
+		wantAddr    string
+		wantErr     bool
+	}{
+		{name: "exact name beats pattern", service: "api-admin", wantPattern: "api-admin", wantAddr: "admin:8080"},
+		{name: "first matching pattern", service: "api-1", wantPattern: "api-*", wantAddr: "api-1.api:8080"},
+		{name: "later pattern", service: "web", wantPattern: "*", wantAddr: "web.internal:80"},
+		{name: "pattern doesn't match separator", service: "api/1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := svc.lookup(tt.service)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrServiceNotFound)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPattern, service.Name)
+
+			addr, err := service.destination(tt.service, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAddr, addr)
+		})
+	}
+
+	// Without patterns only the exact names are found.
+	svc = New(&Config{Services: []ServiceCongfig{{Name: "service1", Address: "host:80"}}}, nil)
+
+	_, err := svc.lookup("service2")
+	assert.ErrorIs(t, err, ErrServiceNotFound)
+}
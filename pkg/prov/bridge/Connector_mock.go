@@ -8,6 +8,7 @@ import (
 	context "context"
 	net "net"
 
+	revconn "github.com/ksysoev/oneway/api/revconn"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -24,29 +25,29 @@ func (_m *MockConnector) EXPECT() *MockConnector_Expecter {
 	return &MockConnector_Expecter{mock: &_m.Mock}
 }
 
-// Connect provides a mock function with given fields: ctx, id
-func (_m *MockConnector) Connect(ctx context.Context, id uint64) (net.Conn, error) {
-	ret := _m.Called(ctx, id)
+// ConnectWithMetadata provides a mock function with given fields: ctx, id, md
+func (_m *MockConnector) ConnectWithMetadata(ctx context.Context, id uint64, md revconn.Metadata) (net.Conn, error) {
+	ret := _m.Called(ctx, id, md)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Connect")
+		panic("no return value specified for ConnectWithMetadata")
 	}
 
 	var r0 net.Conn
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, uint64) (net.Conn, error)); ok {
-		return rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, revconn.Metadata) (net.Conn, error)); ok {
+		return rf(ctx, id, md)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, uint64) net.Conn); ok {
-		r0 = rf(ctx, id)
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, revconn.Metadata) net.Conn); ok {
+		r0 = rf(ctx, id, md)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(net.Conn)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
-		r1 = rf(ctx, id)
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, revconn.Metadata) error); ok {
+		r1 = rf(ctx, id, md)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -54,31 +55,32 @@ func (_m *MockConnector) Connect(ctx context.Context, id uint64) (net.Conn, erro
 	return r0, r1
 }
 
-// MockConnector_Connect_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Connect'
-type MockConnector_Connect_Call struct {
+// MockConnector_ConnectWithMetadata_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ConnectWithMetadata'
+type MockConnector_ConnectWithMetadata_Call struct {
 	*mock.Call
 }
 
-// Connect is a helper method to define mock.On call
+// ConnectWithMetadata is a helper method to define mock.On call
 //   - ctx context.Context
 //   - id uint64
-func (_e *MockConnector_Expecter) Connect(ctx interface{}, id interface{}) *MockConnector_Connect_Call {
-	return &MockConnector_Connect_Call{Call: _e.mock.On("Connect", ctx, id)}
+//   - md revconn.Metadata
+func (_e *MockConnector_Expecter) ConnectWithMetadata(ctx interface{}, id interface{}, md interface{}) *MockConnector_ConnectWithMetadata_Call {
+	return &MockConnector_ConnectWithMetadata_Call{Call: _e.mock.On("ConnectWithMetadata", ctx, id, md)}
 }
 
-func (_c *MockConnector_Connect_Call) Run(run func(ctx context.Context, id uint64)) *MockConnector_Connect_Call {
+func (_c *MockConnector_ConnectWithMetadata_Call) Run(run func(ctx context.Context, id uint64, md revconn.Metadata)) *MockConnector_ConnectWithMetadata_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].(uint64))
+		run(args[0].(context.Context), args[1].(uint64), args[2].(revconn.Metadata))
 	})
 	return _c
 }
 
-func (_c *MockConnector_Connect_Call) Return(_a0 net.Conn, _a1 error) *MockConnector_Connect_Call {
+func (_c *MockConnector_ConnectWithMetadata_Call) Return(_a0 net.Conn, _a1 error) *MockConnector_ConnectWithMetadata_Call {
 	_c.Call.Return(_a0, _a1)
 	return _c
 }
 
-func (_c *MockConnector_Connect_Call) RunAndReturn(run func(context.Context, uint64) (net.Conn, error)) *MockConnector_Connect_Call {
+func (_c *MockConnector_ConnectWithMetadata_Call) RunAndReturn(run func(context.Context, uint64, revconn.Metadata) (net.Conn, error)) *MockConnector_ConnectWithMetadata_Call {
 	_c.Call.Return(run)
 	return _c
 }
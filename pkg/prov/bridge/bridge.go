@@ -2,19 +2,32 @@ package bridge
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 
 	"github.com/ksysoev/oneway/api/revconn"
 	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/ksysoev/oneway/pkg/core/revconproxy"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
+// Config is the configuration of the connection to the exchange.
+// Mux is the number of multiplexed sessions that carry all reverse connections, zero disables multiplexing
+// and every reverse connection is established as a separate TCP connection.
+// InstanceID identifies the revproxy in the metadata of the reverse connections, it's the host name by default.
 type Config struct {
-	Address string
+	TLS        *network.TLSConfig `mapstructure:"tls"`
+	Address    string
+	Token      string
+	InstanceID string `mapstructure:"instance_id"`
+	Mux        int    `mapstructure:"mux"`
 }
 
 type Connector interface {
-	Connect(ctx context.Context, id uint64) (net.Conn, error)
+	ConnectWithMetadata(ctx context.Context, id uint64, md revconn.Metadata) (net.Conn, error)
 }
 
 type ContextDialer interface {
@@ -22,45 +35,112 @@ type ContextDialer interface {
 }
 
 type Bridge struct {
-	apiClient Connector
-	dialer    ContextDialer
+	apiClient  Connector
+	dialer     ContextDialer
+	instanceID string
 }
 
 // New creates a new Bridge instance
-// with the provided configuration
-func New(cfg *Config) *Bridge {
-	apiClient := revconn.NewClient(cfg.Address)
+// with the provided configuration.
+// It returns an error if the TLS configuration can't be loaded.
+func New(cfg *Config) (*Bridge, error) {
+	tlsConfig, err := cfg.TLS.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS config: %w", err)
+	}
 
-	return &Bridge{
-		apiClient: apiClient,
-		dialer:    &net.Dialer{},
+	apiClient := revconn.NewClient(
+		cfg.Address,
+		revconn.WithClientToken(cfg.Token),
+		revconn.WithClientTLS(tlsConfig),
+		revconn.WithClientMux(cfg.Mux),
+	)
+
+	instanceID := cfg.InstanceID
+	if instanceID == "" {
+		// The metadata is informational, so the revproxy works without the instance id if the host name is unknown.
+		instanceID, _ = os.Hostname()
 	}
+
+	return &Bridge{
+		apiClient:  apiClient,
+		dialer:     &net.Dialer{},
+		instanceID: instanceID,
+	}, nil
 }
 
 // CreateConnection creates a new network bridge connection.
-// It takes a context, connection ID, and address as parameters.
+// It takes a context, connection ID, service name and address as parameters.
+// The destination is dialed first, so the connection request can be rejected
+// without opening the back connection if the destination is not reachable.
 // It returns a pointer to a network.Bridge and an error.
-func (r *Bridge) CreateConnection(ctx context.Context, id uint64, addr string) (*network.Bridge, error) {
-	src, err := r.createBackConnection(ctx, id)
+func (r *Bridge) CreateConnection(ctx context.Context, id uint64, service, addr string) (*network.Bridge, error) {
+	dest, err := r.createDestConnection(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
 
-	dest, err := r.createDestConnection(ctx, addr)
+	src, err := r.createBackConnection(ctx, id, r.metadata(ctx, service, addr))
 	if err != nil {
-		src.Close()
+		dest.Close()
 		return nil, err
 	}
 
 	return network.NewBridge(src, dest), nil
 }
 
+// CreateWarmConnection creates a new network bridge connection from the warm connection.
+// The back connection is established first and kept idle until the exchange activates it,
+// then the destination for the requested port is resolved and dialed, and the result is reported back to the exchange.
+// If the context is done while the connection is idle, the connection is closed.
+// The destination is not known when the back connection is established, so it's not sent in the metadata.
+// It returns a pointer to a network.Bridge and an error.
+func (r *Bridge) CreateWarmConnection(
+	ctx context.Context,
+	id uint64,
+	service string,
+	resolve revconproxy.ResolveFunc,
+) (*network.Bridge, error) {
+	src, err := r.createBackConnection(ctx, id, r.metadata(ctx, service, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() { src.Close() })
+
+	port, err := network.AwaitWarmActivation(src)
+
+	if !stop() || err != nil {
+		src.Close()
+		return nil, errors.Join(err, ctx.Err())
+	}
+
+	var dest net.Conn
+
+	addr, dialErr := resolve(port)
+	if dialErr == nil {
+		dest, dialErr = r.createDestConnection(ctx, addr)
+	}
+
+	if err := network.ReplyWarmActivation(src, dialErr); err != nil || dialErr != nil {
+		src.Close()
+
+		if dest != nil {
+			dest.Close()
+		}
+
+		return nil, errors.Join(dialErr, err)
+	}
+
+	return network.NewBridge(src, dest), nil
+}
+
 // createBackConnection creates a connection to the back-end service
 // using the provided connection ID.
-// It takes a context and connection ID as parameters.
+// It takes a context, connection ID and the metadata of the connection as parameters.
 // It returns an io.ReadWriteCloser and an error.
-func (r *Bridge) createBackConnection(ctx context.Context, id uint64) (net.Conn, error) {
-	conn, err := r.apiClient.Connect(ctx, id)
+func (r *Bridge) createBackConnection(ctx context.Context, id uint64, md revconn.Metadata) (net.Conn, error) {
+	conn, err := r.apiClient.ConnectWithMetadata(ctx, id, md)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect with for id %d: %w", id, err)
 	}
@@ -71,12 +151,31 @@ func (r *Bridge) createBackConnection(ctx context.Context, id uint64) (net.Conn,
 // createDestConnection creates a connection to the destination service
 // using the provided address.
 // It takes a context and address as parameters.
+// The dial error is classified with network.DialError.
 // It returns an io.ReadWriteCloser and an error.
 func (r *Bridge) createDestConnection(ctx context.Context, dest string) (net.Conn, error) {
 	connDest, err := r.dialer.DialContext(ctx, "tcp", dest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to dial %s: %w", dest, err)
+		return nil, fmt.Errorf("failed to dial %s: %w", dest, network.DialError(err))
 	}
 
 	return connDest, nil
 }
+
+// metadata returns the metadata of the back connection: the revproxy instance, the service,
+// the destination if it's known and the trace context of the request.
+func (r *Bridge) metadata(ctx context.Context, service, dest string) revconn.Metadata {
+	md := revconn.Metadata{revconn.MetaService: service}
+
+	if r.instanceID != "" {
+		md[revconn.MetaInstanceID] = r.instanceID
+	}
+
+	if dest != "" {
+		md[revconn.MetaDestination] = dest
+	}
+
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(md))
+
+	return md
+}
@@ -2,10 +2,17 @@ package bridge
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"os"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/ksysoev/oneway/api/revconn"
+	"github.com/ksysoev/oneway/pkg/core/network"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestNew(t *testing.T) {
@@ -13,10 +20,28 @@ func TestNew(t *testing.T) {
 		Address: "example.com:1234",
 	}
 
-	bridge := New(cfg)
+	bridge, err := New(cfg)
 
+	assert.NoError(t, err)
 	assert.NotNil(t, bridge.apiClient)
 	assert.Equal(t, &net.Dialer{}, bridge.dialer)
+
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname, bridge.instanceID)
+
+	cfg.InstanceID = "revproxy-1"
+
+	bridge, err = New(cfg)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "revproxy-1", bridge.instanceID)
+
+	cfg.TLS = &network.TLSConfig{CAFile: "not-existing-file"}
+
+	bridge, err = New(cfg)
+
+	assert.Error(t, err)
+	assert.Nil(t, bridge)
 }
 
 func TestBridge_CreateConnection(t *testing.T) {
@@ -57,19 +82,26 @@ func TestBridge_CreateConnection(t *testing.T) {
 			dialer := NewMockContextDialer(t)
 
 			bridgeProv := &Bridge{
-				apiClient: apiClient,
-				dialer:    dialer,
+				apiClient:  apiClient,
+				dialer:     dialer,
+				instanceID: "revproxy-1",
 			}
 
 			srcConn, destConn := net.Pipe()
 
-			apiClient.EXPECT().Connect(ctx, expectedID).Return(srcConn, tt.srcErr)
+			dialer.EXPECT().DialContext(ctx, expectedProto, expectedAddr).Return(destConn, tt.destErr)
 
-			if tt.srcErr == nil {
-				dialer.EXPECT().DialContext(ctx, expectedProto, expectedAddr).Return(destConn, tt.destErr)
+			if tt.destErr == nil {
+				expectedMD := revconn.Metadata{
+					revconn.MetaService:     "service1",
+					revconn.MetaInstanceID:  "revproxy-1",
+					revconn.MetaDestination: expectedAddr,
+				}
+
+				apiClient.EXPECT().ConnectWithMetadata(ctx, expectedID, expectedMD).Return(srcConn, tt.srcErr)
 			}
 
-			bridge, err := bridgeProv.CreateConnection(context.Background(), uint64(1), expectedAddr)
+			bridge, err := bridgeProv.CreateConnection(context.Background(), uint64(1), "service1", expectedAddr)
 
 			if tt.expectedErr != nil {
 				assert.ErrorIs(t, err, tt.expectedErr)
@@ -84,3 +116,122 @@ func TestBridge_CreateConnection(t *testing.T) {
 		})
 	}
 }
+
+func TestBridge_CreateWarmConnection(t *testing.T) {
+	ctx := context.Background()
+	expectedAddr := "example.com:1234"
+	expectedID := uint64(1)
+
+	tests := []struct {
+		destErr     error
+		expectedErr error
+		name        string
+		port        uint16
+	}{
+		{
+			name: "activated",
+		},
+		{
+			name:        "destination refused",
+			destErr:     network.DialError(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}),
+			expectedErr: network.ErrConnRefused,
+		},
+		{
+			name:        "port not exposed",
+			port:        9090,
+			expectedErr: network.ErrConnRefused,
+		},
+	}
+
+	resolve := func(port uint16) (string, error) {
+		if port != 8080 {
+			return "", fmt.Errorf("%w: port %d", network.ErrConnRefused, port)
+		}
+
+		return expectedAddr, nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.port == 0 {
+				tt.port = 8080
+			}
+
+			apiClient := NewMockConnector(t)
+			dialer := NewMockContextDialer(t)
+
+			bridgeProv := &Bridge{
+				apiClient: apiClient,
+				dialer:    dialer,
+			}
+
+			srcConn, exchangeConn := net.Pipe()
+			destConn, serviceConn := net.Pipe()
+
+			defer exchangeConn.Close()
+			defer serviceConn.Close()
+
+			expectedMD := revconn.Metadata{revconn.MetaService: "service1"}
+
+			apiClient.EXPECT().ConnectWithMetadata(mock.Anything, expectedID, expectedMD).Return(srcConn, nil)
+
+			switch {
+			case tt.port != 8080:
+			case tt.destErr != nil:
+				dialer.EXPECT().DialContext(mock.Anything, "tcp", expectedAddr).Return(nil, tt.destErr)
+			default:
+				dialer.EXPECT().DialContext(mock.Anything, "tcp", expectedAddr).Return(destConn, nil)
+			}
+
+			activated := make(chan error, 1)
+
+			go func() {
+				activated <- network.ActivateWarmConn(exchangeConn, tt.port)
+			}()
+
+			bridge, err := bridgeProv.CreateWarmConnection(ctx, expectedID, "service1", resolve)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, bridge)
+				assert.ErrorIs(t, <-activated, tt.expectedErr)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, bridge)
+			assert.NoError(t, <-activated)
+			assert.NoError(t, bridge.Close())
+		})
+	}
+}
+
+func TestBridge_CreateWarmConnection_ContextDone(t *testing.T) {
+	apiClient := NewMockConnector(t)
+	dialer := NewMockContextDialer(t)
+
+	bridgeProv := &Bridge{
+		apiClient: apiClient,
+		dialer:    dialer,
+	}
+
+	srcConn, exchangeConn := net.Pipe()
+	defer exchangeConn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	apiClient.EXPECT().ConnectWithMetadata(mock.Anything, uint64(1), mock.Anything).Return(srcConn, nil)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	bridge, err := bridgeProv.CreateWarmConnection(ctx, 1, "service1", func(uint16) (string, error) {
+		return "example.com:1234", nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, bridge)
+}
@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+)
+
+type BalancingStrategy string
+
+const (
+	RoundRobin       BalancingStrategy = "round_robin"
+	LeastConnections BalancingStrategy = "least_connections"
+	Random           BalancingStrategy = "random"
+)
+
+// ParseBalancingStrategy parses the name of the balancing strategy.
+// Empty name means the default round-robin strategy.
+// It returns an error if the strategy is unknown.
+func ParseBalancingStrategy(name string) (BalancingStrategy, error) {
+	switch strategy := BalancingStrategy(name); strategy {
+	case "":
+		return RoundRobin, nil
+	case RoundRobin, LeastConnections, Random:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown balancing strategy: %s", name)
+	}
+}
+
+// pick selects one of the reverse proxies according to the strategy.
+// The counter is used by round-robin strategy to remember the position between calls.
+// It returns nil if the list of proxies is empty.
+func (s BalancingStrategy) pick(proxies []*exchange.RevProxy, counter *atomic.Uint64) *exchange.RevProxy {
+	if len(proxies) == 0 {
+		return nil
+	}
+
+	switch s {
+	case LeastConnections:
+		selected := proxies[0]
+
+		for _, proxy := range proxies[1:] {
+			if proxy.InFlight() < selected.InFlight() {
+				selected = proxy
+			}
+		}
+
+		return selected
+	case Random:
+		return proxies[rand.N(len(proxies))]
+	default:
+		return proxies[(counter.Add(1)-1)%uint64(len(proxies))]
+	}
+}
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBalancingStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected BalancingStrategy
+		wantErr  bool
+	}{
+		{name: "", expected: RoundRobin},
+		{name: "round_robin", expected: RoundRobin},
+		{name: "least_connections", expected: LeastConnections},
+		{name: "random", expected: Random},
+		{name: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		strategy, err := ParseBalancingStrategy(tt.name)
+
+		if tt.wantErr {
+			assert.Error(t, err)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, strategy)
+	}
+}
+
+func TestBalancingStrategy_Pick(t *testing.T) {
+	proxies := []*exchange.RevProxy{
+		{NameSpace: "example"},
+		{NameSpace: "example"},
+	}
+
+	for _, strategy := range []BalancingStrategy{RoundRobin, LeastConnections, Random} {
+		counter := &atomic.Uint64{}
+
+		assert.Nil(t, strategy.pick(nil, counter))
+		assert.Contains(t, proxies, strategy.pick(proxies, counter))
+	}
+
+	counter := &atomic.Uint64{}
+
+	assert.Same(t, proxies[0], RoundRobin.pick(proxies, counter))
+	assert.Same(t, proxies[1], RoundRobin.pick(proxies, counter))
+	assert.Same(t, proxies[0], RoundRobin.pick(proxies, counter))
+}
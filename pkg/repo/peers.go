@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+)
+
+type peerNode struct {
+	peer     exchange.Peer
+	services map[string][]string
+}
+
+// PeerRegistry keeps the other nodes of the cluster and the services of the reverse proxies registered on them.
+type PeerRegistry struct {
+	nodes   map[uint16]peerNode
+	counter atomic.Uint64
+	mu      sync.RWMutex
+}
+
+// NewPeerRegistry creates a new instance of PeerRegistry.
+func NewPeerRegistry() *PeerRegistry {
+	return &PeerRegistry{
+		nodes: make(map[uint16]peerNode),
+	}
+}
+
+// Update sets the peer of the node and the services registered on it, keyed by namespace.
+func (r *PeerRegistry) Update(node uint16, peer exchange.Peer, services map[string][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nodes[node] = peerNode{
+		peer:     peer,
+		services: services,
+	}
+}
+
+// Remove removes the node from the registry.
+func (r *PeerRegistry) Remove(node uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.nodes, node)
+}
+
+// Node returns the peer of the node with the given id.
+// The second return value is false if the node is not known.
+func (r *PeerRegistry) Node(id uint16) (exchange.Peer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	node, ok := r.nodes[id]
+
+	return node.peer, ok
+}
+
+// Find searches for a node that has the reverse proxy serving the given service in the given namespace registered.
+// If several nodes serve the service, they are selected in round-robin order,
+// the nodes that serve the service by the exact name are preferred to the ones that serve it by a wildcard pattern.
+// It returns exchange.ErrRevProxyNotFound if there are no nodes for the namespace,
+// and exchange.ErrServiceNotFound if none of the nodes serves the service.
+func (r *PeerRegistry) Find(nameSpace, service string) (exchange.Peer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var exact, wildcard []exchange.Peer
+
+	found := false
+
+	for _, id := range slices.Sorted(maps.Keys(r.nodes)) {
+		node := r.nodes[id]
+
+		services, ok := node.services[nameSpace]
+		if !ok {
+			continue
+		}
+
+		found = true
+
+		switch {
+		case slices.Contains(services, service):
+			exact = append(exact, node.peer)
+		case exchange.MatchService(services, service):
+			wildcard = append(wildcard, node.peer)
+		}
+	}
+
+	if !found {
+		return nil, exchange.ErrRevProxyNotFound
+	}
+
+	candidates := exact
+	if len(candidates) == 0 {
+		candidates = wildcard
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: %s.%s", exchange.ErrServiceNotFound, service, nameSpace)
+	}
+
+	return candidates[(r.counter.Add(1)-1)%uint64(len(candidates))], nil
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerRegistry_Node(t *testing.T) {
+	registry := NewPeerRegistry()
+	peer := exchange.NewMockPeer(t)
+
+	_, ok := registry.Node(1)
+	assert.False(t, ok)
+
+	registry.Update(1, peer, map[string][]string{"example": {"service1"}})
+
+	found, ok := registry.Node(1)
+	assert.True(t, ok)
+	assert.Equal(t, peer, found)
+
+	registry.Remove(1)
+
+	_, ok = registry.Node(1)
+	assert.False(t, ok)
+}
+
+func TestPeerRegistry_Find(t *testing.T) {
+	registry := NewPeerRegistry()
+
+	peer1 := exchange.NewMockPeer(t)
+	peer2 := exchange.NewMockPeer(t)
+	wildcard := exchange.NewMockPeer(t)
+
+	registry.Update(1, peer1, map[string][]string{"example": {"service1", "service2"}})
+	registry.Update(2, peer2, map[string][]string{"example": {"service1"}})
+	registry.Update(3, wildcard, map[string][]string{"example": {"*"}, "other": {"service3"}})
+
+	// Round-robin between the nodes that serve the service by the exact name
+	first, err := registry.Find("example", "service1")
+	assert.NoError(t, err)
+
+	second, err := registry.Find("example", "service1")
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []exchange.Peer{peer1, peer2}, []exchange.Peer{first, second})
+
+	found, err := registry.Find("example", "service2")
+	assert.NoError(t, err)
+	assert.Equal(t, peer1, found)
+
+	found, err = registry.Find("example", "service4")
+	assert.NoError(t, err)
+	assert.Equal(t, wildcard, found)
+
+	_, err = registry.Find("other", "service1")
+	assert.ErrorIs(t, err, exchange.ErrServiceNotFound)
+
+	_, err = registry.Find("unknown", "service1")
+	assert.ErrorIs(t, err, exchange.ErrRevProxyNotFound)
+}
@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/spf13/viper"
+)
+
+// PolicyFile provides the access control policy loaded from the file.
+// The format of the file is detected by its extension, e.g. yaml or json.
+type PolicyFile struct {
+	policy atomic.Pointer[exchange.Policy]
+	path   string
+	data   []byte
+	mu     sync.Mutex
+}
+
+// NewPolicyFile creates a new instance of PolicyFile and loads the policy from the file.
+// It returns an error if the file can't be read or the policy is invalid.
+func NewPolicyFile(path string) (*PolicyFile, error) {
+	p := &PolicyFile{
+		path: filepath.Clean(path),
+	}
+
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Policy returns the current access control policy.
+func (p *PolicyFile) Policy() *exchange.Policy {
+	return p.policy.Load()
+}
+
+// Watch reloads the policy when the file changes until the context is done.
+// The directory of the file is watched, so the file can be replaced atomically, e.g. by renaming or by symlink swap.
+// If the changed policy is invalid, the error is logged and the previous policy is kept.
+func (p *PolicyFile) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(p.path)); err != nil {
+		return fmt.Errorf("failed to watch policy file: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if err := p.reload(); err != nil {
+				slog.ErrorContext(ctx, "failed to reload policy", slog.Any("error", err), slog.String("path", p.path))
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			slog.ErrorContext(ctx, "policy file watcher error", slog.Any("error", err), slog.String("path", p.path))
+		}
+	}
+}
+
+// reload loads the policy from the file if its content has changed since the last load.
+// The empty file is rejected, the deny-all policy must be set explicitly by the default action.
+func (p *PolicyFile) reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	if p.data != nil && bytes.Equal(data, p.data) {
+		return nil
+	}
+
+	// The file is empty for a moment when it's rewritten in place, it must not be loaded as the deny-all policy.
+	if len(bytes.TrimSpace(data)) == 0 {
+		return fmt.Errorf("%w: policy file is empty", exchange.ErrInvalidPolicy)
+	}
+
+	v := viper.New()
+	v.SetConfigType(strings.TrimPrefix(filepath.Ext(p.path), "."))
+
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to parse policy file: %w", err)
+	}
+
+	cfg := &exchange.PolicyConfig{}
+	if err := v.Unmarshal(cfg); err != nil {
+		return fmt.Errorf("failed to unmarshal policy: %w", err)
+	}
+
+	policy, err := exchange.NewPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %w", err)
+	}
+
+	p.policy.Store(policy)
+	p.data = data
+
+	slog.Info("access policy is loaded", slog.String("path", p.path), slog.Int("rules", len(cfg.Rules)))
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `
+default: deny
+rules:
+  - name: alice
+    action: allow
+    users: [alice]
+    targets: [example]
+`
+
+const updatedPolicy = `
+default: deny
+rules:
+  - name: bob
+    action: allow
+    users: [bob]
+`
+
+func allowed(p *PolicyFile, user string) bool {
+	return p.Policy().Evaluate(exchange.Caller{User: user}, network.NewAddress("restapi", "example"), time.Now()).Allowed
+}
+
+func TestNewPolicyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+
+	p, err := NewPolicyFile(path)
+	require.NoError(t, err)
+
+	assert.True(t, allowed(p, "alice"))
+	assert.False(t, allowed(p, "bob"))
+}
+
+func TestNewPolicyFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewPolicyFile(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	path := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("rules:\n  - action: maybe\n"), 0o600))
+
+	_, err = NewPolicyFile(path)
+	assert.ErrorIs(t, err, exchange.ErrInvalidPolicy)
+}
+
+func TestPolicyFile_ReloadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+
+	p, err := NewPolicyFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("default: maybe\n"), 0o600))
+
+	assert.ErrorIs(t, p.reload(), exchange.ErrInvalidPolicy)
+	assert.True(t, allowed(p, "alice"))
+
+	require.NoError(t, os.WriteFile(path, []byte("\n"), 0o600))
+
+	assert.ErrorIs(t, p.reload(), exchange.ErrInvalidPolicy)
+	assert.True(t, allowed(p, "alice"))
+}
+
+func TestPolicyFile_Watch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testPolicy), 0o600))
+
+	p, err := NewPolicyFile(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- p.Watch(ctx)
+	}()
+
+	// The file is written until the watcher is started and picks up the change.
+	assert.Eventually(t, func() bool {
+		require.NoError(t, os.WriteFile(path, []byte(updatedPolicy), 0o600))
+		return allowed(p, "bob") && !allowed(p, "alice")
+	}, time.Second, 10*time.Millisecond)
+
+	// The file is replaced atomically by renaming.
+	tmp := filepath.Join(dir, "policy.yaml.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte(testPolicy), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+
+	assert.Eventually(t, func() bool {
+		return allowed(p, "alice") && !allowed(p, "bob")
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("watcher is not stopped")
+	}
+}
@@ -1,55 +1,126 @@
 package repo
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
 	"sync"
 
 	"github.com/ksysoev/oneway/pkg/core/exchange"
 )
 
+type connRequest struct {
+	connChan  chan exchange.ConnResult
+	nameSpace string
+}
+
 type ConnectionQueue struct {
-	store     map[uint64]chan exchange.ConnResult
-	currentID uint64
-	l         sync.Mutex
+	store map[uint64]connRequest
+	l     sync.Mutex
+	node  uint16
 }
 
 // NewConnectionQueue creates a new instance of ConnectionQueue.
 // It initializes the store with an empty map.
 // Returns a pointer to the newly created ConnectionQueue.
 func NewConnectionQueue() *ConnectionQueue {
+	return NewNodeConnectionQueue(0)
+}
+
+// NewNodeConnectionQueue creates a new instance of ConnectionQueue for the node of the cluster.
+// The node id is kept in the request IDs, so other nodes can route reverse connections to this node.
+// Zero node means that the exchange runs as a single node and the request IDs are fully random.
+func NewNodeConnectionQueue(node uint16) *ConnectionQueue {
 	return &ConnectionQueue{
-		store: make(map[uint64]chan exchange.ConnResult),
+		store: make(map[uint64]connRequest),
+		node:  node,
 	}
 }
 
-// AddRequest adds a connection request to the queue.
-// It takes a channel of connection results as an argument.
-// Returns the ID of the request.
-func (q *ConnectionQueue) AddRequest(connChan chan exchange.ConnResult) uint64 {
+// AddRequest adds a connection request for the given name space to the queue.
+// It takes a name space and a channel of connection results as arguments.
+// The request ID is generated randomly, so it can't be guessed by other reverse proxies,
+// only its upper bits are the node id if the queue belongs to the node of the cluster.
+// Returns the ID of the request and an error if the ID can't be generated.
+func (q *ConnectionQueue) AddRequest(nameSpace string, connChan chan exchange.ConnResult) (uint64, error) {
 	q.l.Lock()
 	defer q.l.Unlock()
 
-	q.currentID++
-	q.store[q.currentID] = connChan
+	for {
+		id, err := randomID()
+		if err != nil {
+			return 0, err
+		}
+
+		if q.node != 0 {
+			id = exchange.NodeRequestID(q.node, id)
+		}
+
+		if _, ok := q.store[id]; ok || id == 0 {
+			continue
+		}
 
-	return q.currentID
+		q.store[id] = connRequest{
+			nameSpace: nameSpace,
+			connChan:  connChan,
+		}
+
+		return id, nil
+	}
 }
 
 // AddConnection adds a connection to the queue.
-// It takes an ID and a connection result as arguments.
+// It takes a name space, an ID and a connection result as arguments.
 // If the request with the given ID exists in the queue, the connection result is sent to the request channel.
 // Returns an error if the request with the given ID is not found.
-func (q *ConnectionQueue) AddConnection(id uint64, conn exchange.ConnResult) error {
+// Returns an error if the name space is not empty and doesn't match the name space of the request,
+// in this case the request is kept in the queue.
+func (q *ConnectionQueue) AddConnection(nameSpace string, id uint64, conn exchange.ConnResult) error {
 	q.l.Lock()
-	ch, ok := q.store[id]
+	req, ok := q.store[id]
+
+	if !ok {
+		q.l.Unlock()
+		return exchange.ErrConnReqNotFound
+	}
+
+	if nameSpace != "" && nameSpace != req.nameSpace {
+		q.l.Unlock()
+		return exchange.ErrNameSpaceMismatch
+	}
+
+	delete(q.store, id)
 	q.l.Unlock()
 
-	if ok {
-		ch <- conn
-		close(ch)
-		delete(q.store, id)
+	req.connChan <- conn
+	close(req.connChan)
+
+	return nil
+}
+
+// RemoveRequest removes the connection request with the given ID from the queue.
+// It returns false if the request is not found, which means that the connection is already delivered
+// or is being delivered to the request channel.
+func (q *ConnectionQueue) RemoveRequest(id uint64) bool {
+	q.l.Lock()
+	defer q.l.Unlock()
+
+	if _, ok := q.store[id]; !ok {
+		return false
+	}
+
+	delete(q.store, id)
+
+	return true
+}
+
+// randomID generates a random connection request ID.
+func randomID() (uint64, error) {
+	buf := make([]byte, 8)
 
-		return nil
+	if _, err := rand.Read(buf); err != nil {
+		return 0, fmt.Errorf("failed to generate connection id: %w", err)
 	}
 
-	return exchange.ErrConnReqNotFound
+	return binary.BigEndian.Uint64(buf), nil
 }
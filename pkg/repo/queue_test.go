@@ -20,18 +20,28 @@ func TestAddRequest(t *testing.T) {
 	q := NewConnectionQueue()
 
 	connChan := make(chan exchange.ConnResult)
-	id := q.AddRequest(connChan)
+	id, err := q.AddRequest("example", connChan)
 
-	assert.NotEqual(t, 0, id)
+	assert.NoError(t, err)
+	assert.NotEqual(t, uint64(0), id)
 	assert.Equal(t, 1, len(q.store))
-	assert.Equal(t, connChan, q.store[id])
+	assert.Equal(t, connChan, q.store[id].connChan)
+	assert.Equal(t, "example", q.store[id].nameSpace)
+
+	nextID, err := q.AddRequest("example", connChan)
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, id, nextID)
+	assert.NotEqual(t, id+1, nextID)
 }
 
 func TestAddConnection(t *testing.T) {
 	q := NewConnectionQueue()
 
 	connChan := make(chan exchange.ConnResult)
-	id := q.AddRequest(connChan)
+	id, err := q.AddRequest("example", connChan)
+
+	assert.NoError(t, err)
 
 	conn := exchange.ConnResult{
 		Conn: nil,
@@ -39,7 +49,7 @@ func TestAddConnection(t *testing.T) {
 	}
 
 	go func() {
-		err := q.AddConnection(id, conn)
+		err := q.AddConnection("example", id, conn)
 
 		assert.NoError(t, err)
 		assert.Equal(t, 0, len(q.store))
@@ -52,3 +62,56 @@ func TestAddConnection(t *testing.T) {
 		t.Error("AddConnection should send the connection result to the request channel")
 	}
 }
+
+func TestAddConnection_NameSpaceMismatch(t *testing.T) {
+	q := NewConnectionQueue()
+
+	connChan := make(chan exchange.ConnResult, 1)
+	id, err := q.AddRequest("example", connChan)
+
+	assert.NoError(t, err)
+
+	err = q.AddConnection("other", id, exchange.ConnResult{})
+
+	assert.ErrorIs(t, err, exchange.ErrNameSpaceMismatch)
+	assert.Equal(t, 1, len(q.store))
+
+	err = q.AddConnection("", id, exchange.ConnResult{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(q.store))
+}
+
+func TestAddConnection_NotFound(t *testing.T) {
+	q := NewConnectionQueue()
+
+	err := q.AddConnection("example", 123, exchange.ConnResult{})
+
+	assert.ErrorIs(t, err, exchange.ErrConnReqNotFound)
+}
+
+func TestRemoveRequest(t *testing.T) {
+	q := NewConnectionQueue()
+
+	id, err := q.AddRequest("example", make(chan exchange.ConnResult, 1))
+
+	assert.NoError(t, err)
+	assert.True(t, q.RemoveRequest(id))
+	assert.Equal(t, 0, len(q.store))
+	assert.False(t, q.RemoveRequest(id))
+
+	err = q.AddConnection("example", id, exchange.ConnResult{})
+
+	assert.ErrorIs(t, err, exchange.ErrConnReqNotFound)
+}
+
+func TestAddRequest_Node(t *testing.T) {
+	q := NewNodeConnectionQueue(5)
+
+	for i := 0; i < 10; i++ {
+		id, err := q.AddRequest("example", make(chan exchange.ConnResult, 1))
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(5), exchange.RequestNode(id))
+	}
+}
@@ -2,56 +2,137 @@ package repo
 
 import (
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/ksysoev/oneway/pkg/core/exchange"
 )
 
+type nameSpaceProxies struct {
+	counter *atomic.Uint64
+	proxies []*exchange.RevProxy
+}
+
 type RevProxyRegistry struct {
-	store map[string]*exchange.RevProxy
-	mu    sync.RWMutex
+	store    map[string]nameSpaceProxies
+	strategy BalancingStrategy
+	mu       sync.RWMutex
 }
 
 // NewRevProxyRegistry creates a new instance of RevProxyRegistry.
 // It initializes the store with an empty map.
+// The strategy is used to select one of the reverse proxies registered for the same namespace.
 // Returns a pointer to the newly created RevProxyRegistry.
-func NewRevProxyRegistry() *RevProxyRegistry {
+func NewRevProxyRegistry(strategy BalancingStrategy) *RevProxyRegistry {
 	return &RevProxyRegistry{
-		store: make(map[string]*exchange.RevProxy),
+		store:    make(map[string]nameSpaceProxies),
+		strategy: strategy,
 	}
 }
 
-// AddRevProxy adds a RevProxy to the RevProxyRegistry.
+// Register adds a RevProxy to the RevProxyRegistry.
 // It takes a pointer to a RevProxy as the parameter.
-// The RevProxy is added to the registry's store using the proxy's NameSpace as the key.
+// The RevProxy is added to the set of proxies of its namespace, so several proxies can serve the same namespace.
 func (r *RevProxyRegistry) Register(proxy *exchange.RevProxy) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.store[proxy.NameSpace] = proxy
+	entry, ok := r.store[proxy.NameSpace]
+	if !ok {
+		entry.counter = &atomic.Uint64{}
+	}
+
+	if slices.Contains(entry.proxies, proxy) {
+		return
+	}
+
+	entry.proxies = append(entry.proxies, proxy)
+	r.store[proxy.NameSpace] = entry
 }
 
 // Unregister removes the specified proxy from the RevProxyRegistry.
 // It takes a pointer to a RevProxy as the argument.
-// The proxy is removed from the registry by deleting its namespace from the store.
+// Other proxies of the same namespace are left untouched,
+// the namespace is removed from the store when its last proxy is unregistered.
 func (r *RevProxyRegistry) Unregister(proxy *exchange.RevProxy) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	delete(r.store, proxy.NameSpace)
+	entry, ok := r.store[proxy.NameSpace]
+	if !ok {
+		return
+	}
+
+	entry.proxies = slices.DeleteFunc(slices.Clone(entry.proxies), func(p *exchange.RevProxy) bool {
+		return p == proxy
+	})
+
+	if len(entry.proxies) == 0 {
+		delete(r.store, proxy.NameSpace)
+		return
+	}
+
+	r.store[proxy.NameSpace] = entry
 }
 
-// Find searches for a reverse proxy in the registry based on the given namespace.
-// It returns a pointer to the found reverse proxy and an error if the reverse proxy is not found.
-func (r *RevProxyRegistry) Find(nameSpace string) (*exchange.RevProxy, error) {
+// Find searches for a reverse proxy in the registry that serves the given service in the given namespace.
+// If several proxies serve the service, one of them is selected according to the balancing strategy,
+// the proxies that serve the service by the exact name are preferred to the ones that serve it by a wildcard pattern.
+// It returns exchange.ErrRevProxyNotFound if there are no proxies for the namespace,
+// and exchange.ErrServiceNotFound if none of the namespace proxies serves the service.
+func (r *RevProxyRegistry) Find(nameSpace, service string) (*exchange.RevProxy, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	proxy, ok := r.store[nameSpace]
+	entry, ok := r.store[nameSpace]
 
 	if !ok {
-		return nil, fmt.Errorf("revproxy not found")
+		return nil, exchange.ErrRevProxyNotFound
+	}
+
+	candidates := make([]*exchange.RevProxy, 0, len(entry.proxies))
+
+	// The proxies that serve the service by the exact name take precedence over the wildcard ones.
+	for _, proxy := range entry.proxies {
+		if proxy.HasExactService(service) {
+			candidates = append(candidates, proxy)
+		}
+	}
+
+	if len(candidates) == 0 {
+		for _, proxy := range entry.proxies {
+			if proxy.HasService(service) {
+				candidates = append(candidates, proxy)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: %s.%s", exchange.ErrServiceNotFound, service, nameSpace)
+	}
+
+	return r.strategy.pick(candidates, entry.counter), nil
+}
+
+// Services returns the services served by the registered reverse proxies, keyed by namespace.
+// The services of all proxies of the namespace are merged and sorted.
+func (r *RevProxyRegistry) Services() map[string][]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	services := make(map[string][]string, len(r.store))
+
+	for nameSpace, entry := range r.store {
+		var names []string
+
+		for _, proxy := range entry.proxies {
+			names = append(names, proxy.Services...)
+		}
+
+		slices.Sort(names)
+		services[nameSpace] = slices.Compact(names)
 	}
 
-	return proxy, nil
+	return services
 }
@@ -1,25 +1,30 @@
 package repo
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRevProxyRegistry(t *testing.T) {
 	// Create a new instance of RevProxyRegistry
-	registry := NewRevProxyRegistry()
+	registry := NewRevProxyRegistry(RoundRobin)
 
 	// Create a mock reverse proxy
 	proxy := &exchange.RevProxy{
 		NameSpace: "example",
+		Services:  []string{"service"},
 	}
 
 	// Register the mock reverse proxy
 	registry.Register(proxy)
 
 	// Test case: Find an existing reverse proxy
-	foundProxy, err := registry.Find("example")
+	foundProxy, err := registry.Find("example", "service")
 	if err != nil {
 		t.Errorf("Expected no error, got %v", err)
 	}
@@ -29,7 +34,7 @@ func TestRevProxyRegistry(t *testing.T) {
 	}
 
 	// Test case: Find a non-existing reverse proxy
-	nonExistingProxy, err := registry.Find("non-existing")
+	nonExistingProxy, err := registry.Find("non-existing", "service")
 	if err == nil {
 		t.Errorf("Expected an error, got nil")
 	}
@@ -42,7 +47,7 @@ func TestRevProxyRegistry(t *testing.T) {
 	registry.Unregister(proxy)
 
 	// Test case: Find an unregistered reverse proxy
-	unregisteredProxy, err := registry.Find("example")
+	unregisteredProxy, err := registry.Find("example", "service")
 	if err == nil {
 		t.Errorf("Expected an error, got nil")
 	}
@@ -51,3 +56,169 @@ func TestRevProxyRegistry(t *testing.T) {
 		t.Errorf("Expected unregisteredProxy to be nil")
 	}
 }
+
+func TestRevProxyRegistry_UnregisterReplaced(t *testing.T) {
+	registry := NewRevProxyRegistry(RoundRobin)
+
+	oldProxy := &exchange.RevProxy{NameSpace: "example", Services: []string{"service"}}
+	newProxy := &exchange.RevProxy{NameSpace: "example", Services: []string{"service"}}
+
+	registry.Register(oldProxy)
+	registry.Register(newProxy)
+
+	// Unregistering the replaced proxy should keep the new one
+	registry.Unregister(oldProxy)
+
+	foundProxy, err := registry.Find("example", "service")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if foundProxy != newProxy {
+		t.Errorf("Expected foundProxy to be equal to newProxy")
+	}
+}
+
+func TestRevProxyRegistry_MultipleProxies(t *testing.T) {
+	registry := NewRevProxyRegistry(RoundRobin)
+
+	proxy1 := &exchange.RevProxy{NameSpace: "example", Services: []string{"service"}}
+	proxy2 := &exchange.RevProxy{NameSpace: "example", Services: []string{"service"}}
+
+	registry.Register(proxy1)
+	registry.Register(proxy2)
+	registry.Register(proxy2)
+
+	found := make(map[*exchange.RevProxy]int)
+
+	for i := 0; i < 4; i++ {
+		proxy, err := registry.Find("example", "service")
+
+		assert.NoError(t, err)
+
+		found[proxy]++
+	}
+
+	assert.Equal(t, map[*exchange.RevProxy]int{proxy1: 2, proxy2: 2}, found)
+
+	registry.Unregister(proxy1)
+
+	for i := 0; i < 2; i++ {
+		proxy, err := registry.Find("example", "service")
+
+		assert.NoError(t, err)
+		assert.Same(t, proxy2, proxy)
+	}
+
+	registry.Unregister(proxy2)
+
+	_, err := registry.Find("example", "service")
+
+	assert.Error(t, err)
+}
+
+func TestRevProxyRegistry_LeastConnections(t *testing.T) {
+	registry := NewRevProxyRegistry(LeastConnections)
+	svc := exchange.New(&exchange.Config{}, registry, NewConnectionQueue(), nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	proxy1, err := svc.RegisterRevProxy(ctx, "example", []string{"service"}, nil)
+	assert.NoError(t, err)
+
+	proxy2, err := svc.RegisterRevProxy(ctx, "example", []string{"service"}, nil)
+	assert.NoError(t, err)
+
+	// Nobody reads the command stream, so the connection request stays in-flight until the context is canceled
+	go func() {
+		_, _ = svc.NewConnection(ctx, network.NewAddress("service", "example"))
+	}()
+
+	assert.Eventually(t, func() bool {
+		return proxy1.InFlight()+proxy2.InFlight() == 1
+	}, time.Second, time.Millisecond)
+
+	busy, idle := proxy1, proxy2
+	if proxy2.InFlight() == 1 {
+		busy, idle = proxy2, proxy1
+	}
+
+	for i := 0; i < 3; i++ {
+		proxy, err := registry.Find("example", "service")
+
+		assert.NoError(t, err)
+		assert.Same(t, idle, proxy)
+	}
+
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		return busy.InFlight() == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestRevProxyRegistry_FindByService(t *testing.T) {
+	registry := NewRevProxyRegistry(RoundRobin)
+
+	proxy1 := &exchange.RevProxy{NameSpace: "example", Services: []string{"service1"}}
+	proxy2 := &exchange.RevProxy{NameSpace: "example", Services: []string{"service2", "service3"}}
+
+	registry.Register(proxy1)
+	registry.Register(proxy2)
+
+	for i := 0; i < 2; i++ {
+		proxy, err := registry.Find("example", "service1")
+
+		assert.NoError(t, err)
+		assert.Same(t, proxy1, proxy)
+
+		proxy, err = registry.Find("example", "service3")
+
+		assert.NoError(t, err)
+		assert.Same(t, proxy2, proxy)
+	}
+
+	_, err := registry.Find("example", "unknown")
+
+	assert.ErrorIs(t, err, exchange.ErrServiceNotFound)
+
+	_, err = registry.Find("unknown", "service1")
+
+	assert.ErrorIs(t, err, exchange.ErrRevProxyNotFound)
+}
+
+func TestRevProxyRegistry_FindWildcard(t *testing.T) {
+	registry := NewRevProxyRegistry(RoundRobin)
+
+	wildcard := &exchange.RevProxy{NameSpace: "billing.prod", Services: []string{"*"}}
+	exact := &exchange.RevProxy{NameSpace: "billing.prod", Services: []string{"api"}}
+
+	registry.Register(wildcard)
+	registry.Register(exact)
+
+	for i := 0; i < 2; i++ {
+		proxy, err := registry.Find("billing.prod", "api")
+
+		assert.NoError(t, err)
+		assert.Same(t, exact, proxy)
+
+		proxy, err = registry.Find("billing.prod", "reports")
+
+		assert.NoError(t, err)
+		assert.Same(t, wildcard, proxy)
+	}
+}
+
+func TestRevProxyRegistry_Services(t *testing.T) {
+	registry := NewRevProxyRegistry(RoundRobin)
+
+	registry.Register(&exchange.RevProxy{NameSpace: "example", Services: []string{"service2", "service1"}})
+	registry.Register(&exchange.RevProxy{NameSpace: "example", Services: []string{"service1", "*"}})
+	registry.Register(&exchange.RevProxy{NameSpace: "other", Services: []string{"service3"}})
+
+	assert.Equal(t, map[string][]string{
+		"example": {"*", "service1", "service2"},
+		"other":   {"service3"},
+	}, registry.Services())
+}
@@ -0,0 +1,215 @@
+// Code generated by mockery v2.45.0. DO NOT EDIT.
+
+//go:build !compile
+
+package ctrlapi
+
+import (
+	context "context"
+
+	exchange "github.com/ksysoev/oneway/pkg/core/exchange"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockExchangeService is an autogenerated mock type for the ExchangeService type
+type MockExchangeService struct {
+	mock.Mock
+}
+
+type MockExchangeService_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockExchangeService) EXPECT() *MockExchangeService_Expecter {
+	return &MockExchangeService_Expecter{mock: &_m.Mock}
+}
+
+// RegisterRevProxy provides a mock function with given fields: ctx, nameSpace, services, warmPool
+func (_m *MockExchangeService) RegisterRevProxy(ctx context.Context, nameSpace string, services []string, warmPool map[string]int) (*exchange.RevProxy, error) {
+	ret := _m.Called(ctx, nameSpace, services, warmPool)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RegisterRevProxy")
+	}
+
+	var r0 *exchange.RevProxy
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, map[string]int) (*exchange.RevProxy, error)); ok {
+		return rf(ctx, nameSpace, services, warmPool)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string, map[string]int) *exchange.RevProxy); ok {
+		r0 = rf(ctx, nameSpace, services, warmPool)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*exchange.RevProxy)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, []string, map[string]int) error); ok {
+		r1 = rf(ctx, nameSpace, services, warmPool)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockExchangeService_RegisterRevProxy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RegisterRevProxy'
+type MockExchangeService_RegisterRevProxy_Call struct {
+	*mock.Call
+}
+
+// RegisterRevProxy is a helper method to define mock.On call
+//   - ctx context.Context
+//   - nameSpace string
+//   - services []string
+//   - warmPool map[string]int
+func (_e *MockExchangeService_Expecter) RegisterRevProxy(ctx interface{}, nameSpace interface{}, services interface{}, warmPool interface{}) *MockExchangeService_RegisterRevProxy_Call {
+	return &MockExchangeService_RegisterRevProxy_Call{Call: _e.mock.On("RegisterRevProxy", ctx, nameSpace, services, warmPool)}
+}
+
+func (_c *MockExchangeService_RegisterRevProxy_Call) Run(run func(ctx context.Context, nameSpace string, services []string, warmPool map[string]int)) *MockExchangeService_RegisterRevProxy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].([]string), args[3].(map[string]int))
+	})
+	return _c
+}
+
+func (_c *MockExchangeService_RegisterRevProxy_Call) Return(_a0 *exchange.RevProxy, _a1 error) *MockExchangeService_RegisterRevProxy_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockExchangeService_RegisterRevProxy_Call) RunAndReturn(run func(context.Context, string, []string, map[string]int) (*exchange.RevProxy, error)) *MockExchangeService_RegisterRevProxy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RejectConnection provides a mock function with given fields: nameSpace, id, reason
+func (_m *MockExchangeService) RejectConnection(nameSpace string, id uint64, reason error) error {
+	ret := _m.Called(nameSpace, id, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RejectConnection")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, uint64, error) error); ok {
+		r0 = rf(nameSpace, id, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockExchangeService_RejectConnection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RejectConnection'
+type MockExchangeService_RejectConnection_Call struct {
+	*mock.Call
+}
+
+// RejectConnection is a helper method to define mock.On call
+//   - nameSpace string
+//   - id uint64
+//   - reason error
+func (_e *MockExchangeService_Expecter) RejectConnection(nameSpace interface{}, id interface{}, reason interface{}) *MockExchangeService_RejectConnection_Call {
+	return &MockExchangeService_RejectConnection_Call{Call: _e.mock.On("RejectConnection", nameSpace, id, reason)}
+}
+
+func (_c *MockExchangeService_RejectConnection_Call) Run(run func(nameSpace string, id uint64, reason error)) *MockExchangeService_RejectConnection_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(uint64), args[2].(error))
+	})
+	return _c
+}
+
+func (_c *MockExchangeService_RejectConnection_Call) Return(_a0 error) *MockExchangeService_RejectConnection_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockExchangeService_RejectConnection_Call) RunAndReturn(run func(string, uint64, error) error) *MockExchangeService_RejectConnection_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ReportStatus provides a mock function with given fields: proxy, status
+func (_m *MockExchangeService) ReportStatus(proxy *exchange.RevProxy, status exchange.Status) {
+	_m.Called(proxy, status)
+}
+
+// MockExchangeService_ReportStatus_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReportStatus'
+type MockExchangeService_ReportStatus_Call struct {
+	*mock.Call
+}
+
+// ReportStatus is a helper method to define mock.On call
+//   - proxy *exchange.RevProxy
+//   - status exchange.Status
+func (_e *MockExchangeService_Expecter) ReportStatus(proxy interface{}, status interface{}) *MockExchangeService_ReportStatus_Call {
+	return &MockExchangeService_ReportStatus_Call{Call: _e.mock.On("ReportStatus", proxy, status)}
+}
+
+func (_c *MockExchangeService_ReportStatus_Call) Run(run func(proxy *exchange.RevProxy, status exchange.Status)) *MockExchangeService_ReportStatus_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(*exchange.RevProxy), args[1].(exchange.Status))
+	})
+	return _c
+}
+
+func (_c *MockExchangeService_ReportStatus_Call) Return() *MockExchangeService_ReportStatus_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockExchangeService_ReportStatus_Call) RunAndReturn(run func(*exchange.RevProxy, exchange.Status)) *MockExchangeService_ReportStatus_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UnregisterRevProxy provides a mock function with given fields: proxy
+func (_m *MockExchangeService) UnregisterRevProxy(proxy *exchange.RevProxy) {
+	_m.Called(proxy)
+}
+
+// MockExchangeService_UnregisterRevProxy_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UnregisterRevProxy'
+type MockExchangeService_UnregisterRevProxy_Call struct {
+	*mock.Call
+}
+
+// UnregisterRevProxy is a helper method to define mock.On call
+//   - proxy *exchange.RevProxy
+func (_e *MockExchangeService_Expecter) UnregisterRevProxy(proxy interface{}) *MockExchangeService_UnregisterRevProxy_Call {
+	return &MockExchangeService_UnregisterRevProxy_Call{Call: _e.mock.On("UnregisterRevProxy", proxy)}
+}
+
+func (_c *MockExchangeService_UnregisterRevProxy_Call) Run(run func(proxy *exchange.RevProxy)) *MockExchangeService_UnregisterRevProxy_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(*exchange.RevProxy))
+	})
+	return _c
+}
+
+func (_c *MockExchangeService_UnregisterRevProxy_Call) Return() *MockExchangeService_UnregisterRevProxy_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockExchangeService_UnregisterRevProxy_Call) RunAndReturn(run func(*exchange.RevProxy)) *MockExchangeService_UnregisterRevProxy_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockExchangeService creates a new instance of MockExchangeService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockExchangeService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockExchangeService {
+	mock := &MockExchangeService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
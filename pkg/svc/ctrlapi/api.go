@@ -2,42 +2,106 @@ package ctrlapi
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ksysoev/oneway/api"
 	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type ExchangeService interface {
-	RegisterRevProxy(ctx context.Context, nameSpace string, services []string) (*exchange.RevProxy, error)
+	RegisterRevProxy(ctx context.Context, nameSpace string, services []string, warmPool map[string]int) (*exchange.RevProxy, error)
 	UnregisterRevProxy(proxy *exchange.RevProxy)
+	ReportStatus(proxy *exchange.RevProxy, status exchange.Status)
+	RejectConnection(nameSpace string, id uint64, reason error) error
 }
 
+const (
+	defaultHeartbeatInterval = 10 * time.Second
+	heartbeatTimeoutFactor   = 3
+)
+
 type API struct {
 	api.UnimplementedExchangeServiceServer
-	exchange ExchangeService
-	listen   string
+	exchange          ExchangeService
+	tls               *network.TLSConfig
+	stopping          chan struct{}
+	owners            map[owner]int
+	listen            string
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+	mu                sync.Mutex
+}
+
+// owner is the name space registered by the control stream from the remote address.
+// The revproxy sends its requests over the same connection as the control stream,
+// so the address identifies the revproxy that registered the name space.
+type owner struct {
+	addr      string
+	nameSpace string
 }
 
+// Config is the configuration of the control API.
+// If TLS requires client certificates, a revproxy may register only the name space its certificate is issued for.
+// HeartbeatInterval is the interval of heartbeats sent to revproxies,
+// HeartbeatTimeout is the time after which a silent revproxy is considered dead, by default it's three heartbeat intervals.
 type Config struct {
-	Listen string
+	TLS               *network.TLSConfig `mapstructure:"tls"`
+	Listen            string
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	HeartbeatTimeout  time.Duration `mapstructure:"heartbeat_timeout"`
 }
 
 func New(cfg *Config, exchangeSvc ExchangeService) *API {
+	heartbeatInterval := cfg.HeartbeatInterval
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+
+	heartbeatTimeout := cfg.HeartbeatTimeout
+	if heartbeatTimeout <= 0 {
+		heartbeatTimeout = heartbeatTimeoutFactor * heartbeatInterval
+	}
+
 	return &API{
-		exchange: exchangeSvc,
-		listen:   cfg.Listen,
+		exchange:          exchangeSvc,
+		stopping:          make(chan struct{}),
+		owners:            make(map[owner]int),
+		listen:            cfg.Listen,
+		tls:               cfg.TLS,
+		heartbeatInterval: heartbeatInterval,
+		heartbeatTimeout:  heartbeatTimeout,
 	}
 }
 
 func (a *API) Run(ctx context.Context) error {
-	srv := grpc.NewServer()
-	api.RegisterExchangeServiceServer(srv, a)
+	tlsConfig, err := a.tls.ServerConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS config: %w", err)
+	}
 
-	grpcServer := grpc.NewServer()
+	opts := make([]grpc.ServerOption, 0, 1)
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
 	api.RegisterExchangeServiceServer(grpcServer, a)
 
 	lis, err := net.Listen("tcp", a.listen)
@@ -47,6 +111,8 @@ func (a *API) Run(ctx context.Context) error {
 
 	go func() {
 		<-ctx.Done()
+		// Revproxies are told to drain before their control streams are closed.
+		close(a.stopping)
 		grpcServer.GracefulStop()
 	}()
 
@@ -55,29 +121,112 @@ func (a *API) Run(ctx context.Context) error {
 	return grpcServer.Serve(lis)
 }
 
-func (a *API) RegisterService(req *api.RegisterRequest, stream grpc.ServerStreamingServer[api.ConnectCommand]) error {
-	rcp, err := a.exchange.RegisterRevProxy(stream.Context(), req.NameSpace, req.ServiceName)
+// Control handles the control stream of the revproxy.
+// The first message of the stream must register the revproxy, the registration is confirmed with the stream headers.
+// The revproxy that has authenticated with the client certificate may register only the name space of the certificate.
+// After that the exchange sends connect commands and heartbeats, and receives heartbeats and status reports.
+// If the revproxy doesn't send anything within the heartbeat timeout, it's considered dead and unregistered.
+// When the API is stopping, the revproxy receives the drain message and the stream is closed.
+func (a *API) Control(stream grpc.BidiStreamingServer[api.ControlMessage, api.ExchangeMessage]) error {
+	ctx := stream.Context()
+
+	msg, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("failed to receive registration: %w", err)
+	}
+
+	req := msg.GetRegister()
+	if req == nil {
+		return status.Error(codes.InvalidArgument, "first message must be a registration")
+	}
+
+	if err := authorizeNameSpace(ctx, req.NameSpace); err != nil {
+		return err
+	}
+
+	warmPool := make(map[string]int, len(req.WarmPool))
+	for service, size := range req.WarmPool {
+		warmPool[service] = int(size)
+	}
+
+	rcp, err := a.exchange.RegisterRevProxy(ctx, req.NameSpace, req.ServiceName, warmPool)
 	if err != nil {
 		return err
 	}
 
 	defer a.exchange.UnregisterRevProxy(rcp)
 
+	o := owner{addr: peerAddr(ctx), nameSpace: req.NameSpace}
+
+	a.addOwner(o)
+	defer a.removeOwner(o)
+
+	// Headers confirm the registration to the revproxy before the first command is sent.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return fmt.Errorf("failed to send header: %w", err)
+	}
+
+	var lastSeen atomic.Int64
+
+	lastSeen.Store(time.Now().UnixNano())
+
+	recvErr := make(chan error, 1)
+
+	go func() {
+		recvErr <- a.receive(stream, rcp, &lastSeen)
+	}()
+
+	heartbeat := time.NewTicker(a.heartbeatInterval)
+	defer heartbeat.Stop()
+
 	cmdStream := rcp.CommandStream()
 
 	for {
 		select {
-		case <-stream.Context().Done():
+		case <-ctx.Done():
+			return nil
+		case <-a.stopping:
+			err := stream.Send(&api.ExchangeMessage{
+				Message: &api.ExchangeMessage_Drain{Drain: &api.Drain{}},
+			})
+
+			if err != nil {
+				return fmt.Errorf("failed to send drain: %w", err)
+			}
+
 			return nil
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		case <-heartbeat.C:
+			if time.Since(time.Unix(0, lastSeen.Load())) > a.heartbeatTimeout {
+				slog.WarnContext(ctx, "revproxy is not responding", slog.String("namespace", rcp.NameSpace))
+				return status.Error(codes.DeadlineExceeded, "revproxy is not responding")
+			}
+
+			err := stream.Send(&api.ExchangeMessage{
+				Message: &api.ExchangeMessage_Heartbeat{Heartbeat: &api.Heartbeat{Timestamp: time.Now().UnixNano()}},
+			})
+
+			if err != nil {
+				return fmt.Errorf("failed to send heartbeat: %w", err)
+			}
 		case cmd, ok := <-cmdStream:
 			if !ok {
 				return nil
 			}
 
-			err := stream.Send(&api.ConnectCommand{
-				NameSpace:   cmd.NameSpace,
-				ServiceName: cmd.Name,
-				Id:          cmd.ConnID,
+			err := stream.Send(&api.ExchangeMessage{
+				Message: &api.ExchangeMessage_Connect{Connect: &api.ConnectCommand{
+					NameSpace:   cmd.NameSpace,
+					ServiceName: cmd.Name,
+					Id:          cmd.ConnID,
+					Warm:        cmd.Warm,
+					Port:        uint32(cmd.Port),
+				}},
 			})
 
 			if err != nil {
@@ -86,3 +235,137 @@ func (a *API) RegisterService(req *api.RegisterRequest, stream grpc.ServerStream
 		}
 	}
 }
+
+// receive handles the messages from the revproxy until the stream is closed.
+// Every message updates the time the revproxy was seen last time.
+func (a *API) receive(stream grpc.BidiStreamingServer[api.ControlMessage, api.ExchangeMessage], rcp *exchange.RevProxy, lastSeen *atomic.Int64) error {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		lastSeen.Store(time.Now().UnixNano())
+
+		switch m := msg.GetMessage().(type) {
+		case *api.ControlMessage_Heartbeat:
+		case *api.ControlMessage_Status:
+			a.exchange.ReportStatus(rcp, exchange.Status{
+				ReportedAt:    time.Now(),
+				ActiveBridges: m.Status.ActiveBridges,
+				Version:       m.Status.Version,
+				Uptime:        time.Duration(m.Status.UptimeSeconds) * time.Second,
+			})
+		case *api.ControlMessage_Register:
+			return status.Error(codes.InvalidArgument, "revproxy is already registered")
+		}
+	}
+}
+
+// RejectConnection handles the report of the revproxy that it failed to establish the requested connection.
+// The reason is converted to the corresponding error, so the requester can fail fast with a meaningful error.
+// Only the revproxy that has the control stream registered for the name space may reject its connection requests.
+func (a *API) RejectConnection(ctx context.Context, req *api.RejectRequest) (*api.RejectResponse, error) {
+	if !a.owns(owner{addr: peerAddr(ctx), nameSpace: req.NameSpace}) {
+		return nil, status.Errorf(codes.PermissionDenied, "name space %s is not registered by the caller", req.NameSpace)
+	}
+
+	reason := fmt.Errorf("%w: %s", rejectReasonError(req.Reason), req.Message)
+
+	if err := a.exchange.RejectConnection(req.NameSpace, req.Id, reason); err != nil {
+		if errors.Is(err, exchange.ErrConnReqNotFound) || errors.Is(err, exchange.ErrNameSpaceMismatch) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+
+		return nil, fmt.Errorf("failed to reject connection: %w", err)
+	}
+
+	return &api.RejectResponse{}, nil
+}
+
+// addOwner records that the control stream from the address registered the name space.
+func (a *API) addOwner(o owner) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.owners[o]++
+}
+
+// removeOwner removes the name space registered by the control stream when the stream is closed.
+func (a *API) removeOwner(o owner) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.owners[o]--; a.owners[o] <= 0 {
+		delete(a.owners, o)
+	}
+}
+
+// owns reports whether a control stream from the address has registered the name space.
+func (a *API) owns(o owner) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return o.addr != "" && a.owners[o] > 0
+}
+
+// peerAddr returns the remote address of the gRPC call, or an empty string if it's unknown.
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	return p.Addr.String()
+}
+
+// authorizeNameSpace checks that the revproxy may register the name space.
+// The client certificate proves only that it's signed by the trusted CA, so the name space must be
+// its common name or one of its DNS names. Revproxies without client certificates are not restricted.
+func authorizeNameSpace(ctx context.Context, nameSpace string) error {
+	cert := peerCertificate(ctx)
+	if cert == nil {
+		return nil
+	}
+
+	matches := func(name string) bool { return strings.EqualFold(name, nameSpace) }
+
+	if matches(cert.Subject.CommonName) || slices.ContainsFunc(cert.DNSNames, matches) {
+		return nil
+	}
+
+	return status.Errorf(codes.PermissionDenied, "certificate %s is not issued for name space %s", cert.Subject.CommonName, nameSpace)
+}
+
+// peerCertificate returns the verified client certificate of the gRPC call, or nil if there is none.
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return info.State.PeerCertificates[0]
+}
+
+// rejectReasonError returns the error that corresponds to the reject reason.
+func rejectReasonError(reason api.RejectReason) error {
+	switch reason {
+	case api.RejectReason_REJECT_REASON_SERVICE_NOT_FOUND:
+		return exchange.ErrServiceNotFound
+	case api.RejectReason_REJECT_REASON_CONNECTION_REFUSED:
+		return network.ErrConnRefused
+	case api.RejectReason_REJECT_REASON_HOST_UNREACHABLE:
+		return network.ErrHostUnreachable
+	case api.RejectReason_REJECT_REASON_NETWORK_UNREACHABLE:
+		return network.ErrNetUnreachable
+	case api.RejectReason_REJECT_REASON_TIMEOUT:
+		return network.ErrConnTimeout
+	default:
+		return exchange.ErrConnRejected
+	}
+}
@@ -0,0 +1,159 @@
+package ctrlapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/oneway/api"
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// startAPI serves the control API on the loopback interface and returns the client connected to it.
+func startAPI(t *testing.T, a *API) *grpc.ClientConn {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer()
+	api.RegisterExchangeServiceServer(srv, a)
+
+	go func() { _ = srv.Serve(lis) }()
+
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+// register opens the control stream and registers the revproxy for the name space,
+// the returned function closes the stream.
+func register(t *testing.T, client api.ExchangeServiceClient, nameSpace string) context.CancelFunc {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	stream, err := client.Control(ctx)
+	require.NoError(t, err)
+
+	err = stream.Send(&api.ControlMessage{
+		Message: &api.ControlMessage_Register{Register: &api.RegisterRequest{
+			NameSpace:   nameSpace,
+			ServiceName: []string{"service1"},
+		}},
+	})
+	require.NoError(t, err)
+
+	// The headers confirm the registration.
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	return cancel
+}
+
+func TestAPI_RejectConnection(t *testing.T) {
+	exchangeSvc := NewMockExchangeService(t)
+	a := New(&Config{}, exchangeSvc)
+
+	rcp, err := exchange.NewRevProxy("example", []string{"service1"})
+	require.NoError(t, err)
+
+	exchangeSvc.EXPECT().RegisterRevProxy(mock.Anything, "example", []string{"service1"}, map[string]int{}).Return(rcp, nil)
+	exchangeSvc.EXPECT().UnregisterRevProxy(rcp).Return().Maybe()
+	exchangeSvc.EXPECT().RejectConnection("example", uint64(42), mock.Anything).
+		RunAndReturn(func(_ string, _ uint64, reason error) error {
+			assert.ErrorIs(t, reason, network.ErrConnRefused)
+			return nil
+		}).Once()
+
+	owner := api.NewExchangeServiceClient(startAPI(t, a))
+	other := api.NewExchangeServiceClient(startAPI(t, a))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := &api.RejectRequest{NameSpace: "example", Id: 42, Reason: api.RejectReason_REJECT_REASON_CONNECTION_REFUSED}
+
+	// The name space is not registered yet.
+	_, err = owner.RejectConnection(ctx, req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	closeStream := register(t, owner, "example")
+
+	// The name space is registered by the other connection.
+	_, err = other.RejectConnection(ctx, req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// The name space is not registered by the caller.
+	_, err = owner.RejectConnection(ctx, &api.RejectRequest{NameSpace: "other", Id: 42})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = owner.RejectConnection(ctx, req)
+	assert.NoError(t, err)
+
+	closeStream()
+
+	assert.Eventually(t, func() bool {
+		_, err = owner.RejectConnection(ctx, req)
+		return status.Code(err) == codes.PermissionDenied
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestAuthorizeNameSpace(t *testing.T) {
+	withCert := func(cert *x509.Certificate) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}},
+		})
+	}
+
+	tests := []struct {
+		ctx       context.Context
+		name      string
+		nameSpace string
+		wantErr   bool
+	}{
+		{name: "no peer", ctx: context.Background(), nameSpace: "example"},
+		{name: "no certificate", ctx: peer.NewContext(context.Background(), &peer.Peer{}), nameSpace: "example"},
+		{name: "common name", ctx: withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "example"}}), nameSpace: "example"},
+		{name: "DNS name", ctx: withCert(&x509.Certificate{DNSNames: []string{"other", "Billing.Prod"}}), nameSpace: "billing.prod"},
+		{
+			name:      "other name space",
+			ctx:       withCert(&x509.Certificate{Subject: pkix.Name{CommonName: "example"}, DNSNames: []string{"other"}}),
+			nameSpace: "private",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizeNameSpace(tt.ctx, tt.nameSpace)
+
+			if tt.wantErr {
+				assert.Equal(t, codes.PermissionDenied, status.Code(err))
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
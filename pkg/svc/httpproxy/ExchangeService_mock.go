@@ -0,0 +1,144 @@
+// Code generated by mockery v2.45.0. DO NOT EDIT.
+
+//go:build !compile
+
+package httpproxy
+
+import (
+	context "context"
+	net "net"
+
+	network "github.com/ksysoev/oneway/pkg/core/network"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockExchangeService is an autogenerated mock type for the ExchangeService type
+type MockExchangeService struct {
+	mock.Mock
+}
+
+type MockExchangeService_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockExchangeService) EXPECT() *MockExchangeService_Expecter {
+	return &MockExchangeService_Expecter{mock: &_m.Mock}
+}
+
+// BridgeTimeouts provides a mock function with given fields:
+func (_m *MockExchangeService) BridgeTimeouts() network.Timeouts {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for BridgeTimeouts")
+	}
+
+	var r0 network.Timeouts
+	if rf, ok := ret.Get(0).(func() network.Timeouts); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(network.Timeouts)
+	}
+
+	return r0
+}
+
+// MockExchangeService_BridgeTimeouts_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'BridgeTimeouts'
+type MockExchangeService_BridgeTimeouts_Call struct {
+	*mock.Call
+}
+
+// BridgeTimeouts is a helper method to define mock.On call
+func (_e *MockExchangeService_Expecter) BridgeTimeouts() *MockExchangeService_BridgeTimeouts_Call {
+	return &MockExchangeService_BridgeTimeouts_Call{Call: _e.mock.On("BridgeTimeouts")}
+}
+
+func (_c *MockExchangeService_BridgeTimeouts_Call) Run(run func()) *MockExchangeService_BridgeTimeouts_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockExchangeService_BridgeTimeouts_Call) Return(_a0 network.Timeouts) *MockExchangeService_BridgeTimeouts_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockExchangeService_BridgeTimeouts_Call) RunAndReturn(run func() network.Timeouts) *MockExchangeService_BridgeTimeouts_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewConnection provides a mock function with given fields: ctx, address
+func (_m *MockExchangeService) NewConnection(ctx context.Context, address *network.Address) (net.Conn, error) {
+	ret := _m.Called(ctx, address)
+
+	if len(ret) == 0 {
+		panic("no return value specified for NewConnection")
+	}
+
+	var r0 net.Conn
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *network.Address) (net.Conn, error)); ok {
+		return rf(ctx, address)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *network.Address) net.Conn); ok {
+		r0 = rf(ctx, address)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(net.Conn)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *network.Address) error); ok {
+		r1 = rf(ctx, address)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MockExchangeService_NewConnection_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'NewConnection'
+type MockExchangeService_NewConnection_Call struct {
+	*mock.Call
+}
+
+// NewConnection is a helper method to define mock.On call
+//   - ctx context.Context
+//   - address *network.Address
+func (_e *MockExchangeService_Expecter) NewConnection(ctx interface{}, address interface{}) *MockExchangeService_NewConnection_Call {
+	return &MockExchangeService_NewConnection_Call{Call: _e.mock.On("NewConnection", ctx, address)}
+}
+
+func (_c *MockExchangeService_NewConnection_Call) Run(run func(ctx context.Context, address *network.Address)) *MockExchangeService_NewConnection_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*network.Address))
+	})
+	return _c
+}
+
+func (_c *MockExchangeService_NewConnection_Call) Return(_a0 net.Conn, _a1 error) *MockExchangeService_NewConnection_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *MockExchangeService_NewConnection_Call) RunAndReturn(run func(context.Context, *network.Address) (net.Conn, error)) *MockExchangeService_NewConnection_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockExchangeService creates a new instance of MockExchangeService. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockExchangeService(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockExchangeService {
+	mock := &MockExchangeService{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,131 @@
+// Code generated by mockery v2.45.0. DO NOT EDIT.
+
+//go:build !compile
+
+package httpproxy
+
+import (
+	network "github.com/ksysoev/oneway/pkg/core/network"
+	mock "github.com/stretchr/testify/mock"
+)
+
+// MockUserStore is an autogenerated mock type for the UserStore type
+type MockUserStore struct {
+	mock.Mock
+}
+
+type MockUserStore_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockUserStore) EXPECT() *MockUserStore_Expecter {
+	return &MockUserStore_Expecter{mock: &_m.Mock}
+}
+
+// Allowed provides a mock function with given fields: name, addr
+func (_m *MockUserStore) Allowed(name string, addr *network.Address) bool {
+	ret := _m.Called(name, addr)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Allowed")
+	}
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(string, *network.Address) bool); ok {
+		r0 = rf(name, addr)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
+// MockUserStore_Allowed_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Allowed'
+type MockUserStore_Allowed_Call struct {
+	*mock.Call
+}
+
+// Allowed is a helper method to define mock.On call
+//   - name string
+//   - addr *network.Address
+func (_e *MockUserStore_Expecter) Allowed(name interface{}, addr interface{}) *MockUserStore_Allowed_Call {
+	return &MockUserStore_Allowed_Call{Call: _e.mock.On("Allowed", name, addr)}
+}
+
+func (_c *MockUserStore_Allowed_Call) Run(run func(name string, addr *network.Address)) *MockUserStore_Allowed_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(*network.Address))
+	})
+	return _c
+}
+
+func (_c *MockUserStore_Allowed_Call) Return(_a0 bool) *MockUserStore_Allowed_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockUserStore_Allowed_Call) RunAndReturn(run func(string, *network.Address) bool) *MockUserStore_Allowed_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Authenticate provides a mock function with given fields: name, password
+func (_m *MockUserStore) Authenticate(name string, password string) error {
+	ret := _m.Called(name, password)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Authenticate")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(name, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockUserStore_Authenticate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Authenticate'
+type MockUserStore_Authenticate_Call struct {
+	*mock.Call
+}
+
+// Authenticate is a helper method to define mock.On call
+//   - name string
+//   - password string
+func (_e *MockUserStore_Expecter) Authenticate(name interface{}, password interface{}) *MockUserStore_Authenticate_Call {
+	return &MockUserStore_Authenticate_Call{Call: _e.mock.On("Authenticate", name, password)}
+}
+
+func (_c *MockUserStore_Authenticate_Call) Run(run func(name string, password string)) *MockUserStore_Authenticate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockUserStore_Authenticate_Call) Return(_a0 error) *MockUserStore_Authenticate_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockUserStore_Authenticate_Call) RunAndReturn(run func(string, string) error) *MockUserStore_Authenticate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockUserStore creates a new instance of MockUserStore. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockUserStore(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockUserStore {
+	mock := &MockUserStore{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
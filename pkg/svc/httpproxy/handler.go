@@ -0,0 +1,183 @@
+package httpproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+)
+
+const (
+	defaultHTTPPort = "80"
+	basicAuthPrefix = "Basic "
+	authRealm       = `Basic realm="oneway"`
+)
+
+var (
+	ErrHijackNotSupported = fmt.Errorf("connection hijacking is not supported")
+	ErrNotAllowed         = fmt.Errorf("connection is not allowed")
+	ErrNoCredentials      = fmt.Errorf("no basic proxy credentials")
+)
+
+// ServeHTTP tunnels CONNECT requests and forwards requests with absolute http URIs,
+// all other requests are rejected with 400 Bad Request.
+// If the authentication is required, requests without valid credentials are rejected
+// with 407 Proxy Authentication Required.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	caller := requestCaller(r)
+
+	if s.users != nil {
+		name, err := s.authenticate(r)
+		if err != nil {
+			slog.DebugContext(r.Context(), "failed to authenticate proxy client", slog.Any("error", err), slog.String("remote", r.RemoteAddr))
+			w.Header().Set("Proxy-Authenticate", authRealm)
+			http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+
+			return
+		}
+
+		caller.User = name
+	}
+
+	r = r.WithContext(exchange.WithCaller(r.Context(), caller))
+
+	if r.Method == http.MethodConnect {
+		s.handleConnect(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() || r.URL.Scheme != "http" {
+		http.Error(w, "only CONNECT and absolute http URIs are supported", http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.Port() == "" {
+		r.URL.Host = net.JoinHostPort(r.URL.Hostname(), defaultHTTPPort)
+	}
+
+	s.forward.ServeHTTP(w, r)
+}
+
+// handleConnect connects to the requested service and bridges the client connection with it.
+func (s *Service) handleConnect(w http.ResponseWriter, r *http.Request) {
+	dest, err := s.dial(r.Context(), "tcp", r.Host)
+	if err != nil {
+		slog.DebugContext(r.Context(), "failed to connect to service", slog.Any("error", err), slog.String("host", r.Host))
+		http.Error(w, err.Error(), dialStatusCode(err))
+
+		return
+	}
+
+	defer dest.Close()
+
+	conn, brw, err := hijack(w)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to hijack connection", slog.Any("error", err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		slog.DebugContext(r.Context(), "failed to write CONNECT response", slog.Any("error", err))
+		return
+	}
+
+	// The client may send the data right after the request, so it can be buffered already.
+	src := &bufferedConn{Conn: conn, r: brw.Reader}
+
+	bridge := network.NewBridge(src, dest)
+	bridge.SetTimeouts(s.exchange.BridgeTimeouts())
+
+	// The request context is canceled when the handler returns, the hijacked connection lives independently.
+	if _, err := bridge.Run(context.WithoutCancel(r.Context())); err != nil {
+		slog.DebugContext(r.Context(), "CONNECT tunnel is closed with error", slog.Any("error", err))
+	}
+}
+
+// requestCaller returns the identity of the client for the access control policy:
+// the common name of the client certificate and the source address.
+func requestCaller(r *http.Request) exchange.Caller {
+	var caller exchange.Caller
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		caller.Certificate = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	if addr, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		caller.Source = addr.Addr().Unmap()
+	}
+
+	return caller
+}
+
+// authenticate checks the Basic credentials of the Proxy-Authorization header and returns the name of the user.
+func (s *Service) authenticate(r *http.Request) (string, error) {
+	name, password, ok := proxyBasicAuth(r)
+	if !ok {
+		return "", ErrNoCredentials
+	}
+
+	if err := s.users.Authenticate(name, password); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// proxyBasicAuth returns the username and password of the Basic Proxy-Authorization header,
+// it's parsed the same way as the Authorization header by http.Request.BasicAuth.
+func proxyBasicAuth(r *http.Request) (name, password string, ok bool) {
+	auth := r.Header.Get("Proxy-Authorization")
+	if len(auth) < len(basicAuthPrefix) || !strings.EqualFold(auth[:len(basicAuthPrefix)], basicAuthPrefix) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(auth[len(basicAuthPrefix):])
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
+
+func hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, ErrHijackNotSupported
+	}
+
+	return hj.Hijack()
+}
+
+// handleForwardError responds with the status code that corresponds to the error of forwarding the request.
+func (s *Service) handleForwardError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.DebugContext(r.Context(), "failed to forward request", slog.Any("error", err), slog.String("host", r.URL.Host))
+	w.WriteHeader(dialStatusCode(err))
+}
+
+// bufferedConn is a connection that reads through the buffered reader of the hijacked connection.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// CloseWrite closes the sending direction of the hijacked connection, if it supports half-close.
+func (c *bufferedConn) CloseWrite() error {
+	return network.CloseWrite(c.Conn)
+}
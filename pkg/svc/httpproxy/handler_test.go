@@ -0,0 +1,394 @@
+package httpproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func addressWithPort(service, nameSpace string, port uint16) *network.Address {
+	addr := network.NewAddress(service, nameSpace)
+	addr.Port = port
+
+	return addr
+}
+
+// startProxy serves the HTTP proxy and returns its address.
+func startProxy(t *testing.T, exchangeSvc ExchangeService) string {
+	t.Helper()
+
+	return startAuthProxy(t, exchangeSvc, nil)
+}
+
+// startAuthProxy serves the HTTP proxy that authenticates clients as the users of the store and returns its address.
+func startAuthProxy(t *testing.T, exchangeSvc ExchangeService, users UserStore) string {
+	t.Helper()
+
+	srv := httptest.NewServer(New(&Config{}, exchangeSvc, network.NewAddressParser(nil), users))
+	t.Cleanup(srv.Close)
+
+	return srv.Listener.Addr().String()
+}
+
+// serviceConn returns the connection to the service that responds to a single request with the body.
+func serviceConn(t *testing.T, body string) net.Conn {
+	t.Helper()
+
+	conn, service := net.Pipe()
+
+	go func() {
+		defer service.Close()
+
+		req, err := http.ReadRequest(bufio.NewReader(service))
+		if err != nil {
+			return
+		}
+
+		resp := &http.Response{
+			StatusCode:    http.StatusOK,
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"X-Path": []string{req.URL.Path}},
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Close:         true,
+		}
+
+		_ = resp.Write(service)
+	}()
+
+	return conn
+}
+
+// connect sends the CONNECT request to the proxy and returns the connection and the response.
+// The headers are added to the request as is.
+func connect(t *testing.T, proxyAddr, host string, headers ...string) (net.Conn, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", proxyAddr)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	var extra strings.Builder
+	for _, h := range headers {
+		extra.WriteString(h + "\r\n")
+	}
+
+	_, err = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n%s\r\n", host, host, extra.String())
+	require.NoError(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	require.NoError(t, err)
+
+	return conn, resp
+}
+
+func TestService_Connect(t *testing.T) {
+	exchangeSvc := NewMockExchangeService(t)
+
+	conn, service := net.Pipe()
+
+	exchangeSvc.EXPECT().NewConnection(mock.Anything, addressWithPort("service1", "example", 8080)).Return(conn, nil)
+	exchangeSvc.EXPECT().BridgeTimeouts().Return(network.Timeouts{})
+
+	client, resp := connect(t, startProxy(t, exchangeSvc), "service1.example:8080")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	go func() {
+		defer service.Close()
+
+		_, _ = io.Copy(service, io.LimitReader(service, 4))
+	}()
+
+	_, err := client.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	echo, err := io.ReadAll(client)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(echo))
+}
+
+func TestService_Connect_Errors(t *testing.T) {
+	tests := []struct {
+		err        error
+		name       string
+		host       string
+		wantStatus int
+	}{
+		{name: "invalid address", host: "service1:80", wantStatus: http.StatusBadRequest},
+		{name: "access denied", host: "service1.example:80", err: exchange.ErrAccessDenied, wantStatus: http.StatusForbidden},
+		{name: "rate limited", host: "service1.example:80", err: exchange.ErrRateLimited, wantStatus: http.StatusTooManyRequests},
+		{name: "quota exceeded", host: "service1.example:80", err: exchange.ErrQuotaExceeded, wantStatus: http.StatusTooManyRequests},
+		{name: "request expired", host: "service1.example:80", err: exchange.ErrConnReqExpired, wantStatus: http.StatusGatewayTimeout},
+		{name: "connection timeout", host: "service1.example:80", err: network.ErrConnTimeout, wantStatus: http.StatusGatewayTimeout},
+		{name: "revproxy not found", host: "service1.example:80", err: exchange.ErrRevProxyNotFound, wantStatus: http.StatusBadGateway},
+		{name: "connection refused", host: "service1.example:80", err: network.ErrConnRefused, wantStatus: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchangeSvc := NewMockExchangeService(t)
+
+			if tt.err != nil {
+				exchangeSvc.EXPECT().NewConnection(mock.Anything, mock.Anything).Return(nil, tt.err)
+			}
+
+			_, resp := connect(t, startProxy(t, exchangeSvc), tt.host)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+// proxyClient returns the HTTP client that sends requests through the proxy.
+func proxyClient(proxyAddr string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(&url.URL{Scheme: "http", Host: proxyAddr})},
+		Timeout:   5 * time.Second,
+	}
+}
+
+func TestService_Forward(t *testing.T) {
+	exchangeSvc := NewMockExchangeService(t)
+
+	exchangeSvc.EXPECT().NewConnection(mock.Anything, addressWithPort("service1", "example", 80)).
+		Return(serviceConn(t, "hello"), nil)
+	exchangeSvc.EXPECT().BridgeTimeouts().Return(network.Timeouts{})
+
+	resp, err := proxyClient(startProxy(t, exchangeSvc)).Get("http://service1.example/path")
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/path", resp.Header.Get("X-Path"))
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestService_Forward_Errors(t *testing.T) {
+	tests := []struct {
+		err        error
+		name       string
+		url        string
+		wantStatus int
+	}{
+		{name: "invalid address", url: "http://service1/", wantStatus: http.StatusBadRequest},
+		{name: "access denied", url: "http://service1.example/", err: exchange.ErrAccessDenied, wantStatus: http.StatusForbidden},
+		{name: "quota exceeded", url: "http://service1.example/", err: exchange.ErrQuotaExceeded, wantStatus: http.StatusTooManyRequests},
+		{name: "request expired", url: "http://service1.example/", err: exchange.ErrConnReqExpired, wantStatus: http.StatusGatewayTimeout},
+		{name: "service not found", url: "http://service1.example/", err: exchange.ErrServiceNotFound, wantStatus: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchangeSvc := NewMockExchangeService(t)
+
+			if tt.err != nil {
+				exchangeSvc.EXPECT().NewConnection(mock.Anything, mock.Anything).Return(nil, tt.err)
+			}
+
+			resp, err := proxyClient(startProxy(t, exchangeSvc)).Get(tt.url)
+			require.NoError(t, err)
+
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestService_Forward_BridgeTimeouts(t *testing.T) {
+	exchangeSvc := NewMockExchangeService(t)
+
+	conn, service := net.Pipe()
+	defer service.Close()
+
+	// The service reads the request, but never responds.
+	go func() { _, _ = io.Copy(io.Discard, service) }()
+
+	exchangeSvc.EXPECT().NewConnection(mock.Anything, mock.Anything).Return(conn, nil)
+	exchangeSvc.EXPECT().BridgeTimeouts().Return(network.Timeouts{Idle: 100 * time.Millisecond})
+
+	start := time.Now()
+
+	resp, err := proxyClient(startProxy(t, exchangeSvc)).Get("http://service1.example/")
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+// limitedConn is the connection to the service that is subject to the bandwidth limit.
+type limitedConn struct {
+	net.Conn
+	limiter *network.RateLimiter
+}
+
+func (c *limitedConn) RateLimiters() []*network.RateLimiter {
+	return []*network.RateLimiter{c.limiter}
+}
+
+func TestService_Forward_BandwidthLimit(t *testing.T) {
+	const bandwidth = 20_000
+
+	exchangeSvc := NewMockExchangeService(t)
+
+	// The burst is one second of the bandwidth, the rest of the body takes at least half a second.
+	body := strings.Repeat("x", bandwidth*3/2)
+	limiter := network.RateLimit{Bandwidth: bandwidth}.BandwidthLimiter(nil)
+
+	exchangeSvc.EXPECT().NewConnection(mock.Anything, mock.Anything).
+		Return(&limitedConn{Conn: serviceConn(t, body), limiter: limiter}, nil)
+	exchangeSvc.EXPECT().BridgeTimeouts().Return(network.Timeouts{})
+
+	start := time.Now()
+
+	resp, err := proxyClient(startProxy(t, exchangeSvc)).Get("http://service1.example/")
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	received, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Len(t, received, len(body))
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestService_UnsupportedRequest(t *testing.T) {
+	proxyAddr := startProxy(t, NewMockExchangeService(t))
+
+	resp, err := http.Get("http://" + proxyAddr + "/path")
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// proxyAuth returns the Proxy-Authorization header with the Basic credentials.
+func proxyAuth(name, password string) string {
+	return "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(name+":"+password))
+}
+
+func TestService_Connect_Authentication(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		host       string
+		wantUser   string
+		wantStatus int
+	}{
+		{name: "no credentials", host: "service1.example:80", wantStatus: http.StatusProxyAuthRequired},
+		{name: "not basic", host: "service1.example:80", header: "Proxy-Authorization: Bearer token", wantStatus: http.StatusProxyAuthRequired},
+		{name: "invalid encoding", host: "service1.example:80", header: "Proxy-Authorization: Basic !!!", wantStatus: http.StatusProxyAuthRequired},
+		{name: "wrong password", host: "service1.example:80", header: proxyAuth("alice", "wrong"), wantStatus: http.StatusProxyAuthRequired},
+		{name: "not allowed", host: "service1.private:80", header: proxyAuth("alice", "password"), wantStatus: http.StatusForbidden},
+		{name: "allowed", host: "service1.example:80", header: proxyAuth("alice", "password"), wantUser: "alice", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchangeSvc := NewMockExchangeService(t)
+			users := NewMockUserStore(t)
+
+			users.EXPECT().Authenticate(mock.Anything, mock.Anything).RunAndReturn(func(name, password string) error {
+				if name != "alice" || password != "password" {
+					return fmt.Errorf("invalid credentials of %s", name)
+				}
+
+				return nil
+			}).Maybe()
+			users.EXPECT().Allowed("alice", mock.Anything).RunAndReturn(func(_ string, addr *network.Address) bool {
+				return addr.NameSpace == "example"
+			}).Maybe()
+
+			if tt.wantStatus == http.StatusOK {
+				conn, service := net.Pipe()
+				defer service.Close()
+
+				// The authenticated user is passed to the exchange, so the policy and the rate limits of the user apply.
+				withUser := mock.MatchedBy(func(ctx context.Context) bool {
+					caller, ok := exchange.CallerFromContext(ctx)
+					return ok && caller.User == tt.wantUser
+				})
+
+				exchangeSvc.EXPECT().NewConnection(withUser, mock.Anything).Return(conn, nil)
+				exchangeSvc.EXPECT().BridgeTimeouts().Return(network.Timeouts{})
+			}
+
+			var headers []string
+			if tt.header != "" {
+				headers = append(headers, tt.header)
+			}
+
+			_, resp := connect(t, startAuthProxy(t, exchangeSvc, users), tt.host, headers...)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+
+			if tt.wantStatus == http.StatusProxyAuthRequired {
+				assert.Equal(t, authRealm, resp.Header.Get("Proxy-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestService_Forward_Authentication(t *testing.T) {
+	exchangeSvc := NewMockExchangeService(t)
+	users := NewMockUserStore(t)
+
+	users.EXPECT().Authenticate("alice", "password").Return(nil)
+	users.EXPECT().Allowed("alice", addressWithPort("service1", "example", 80)).Return(true)
+
+	exchangeSvc.EXPECT().NewConnection(mock.Anything, addressWithPort("service1", "example", 80)).
+		Return(serviceConn(t, "hello"), nil)
+	exchangeSvc.EXPECT().BridgeTimeouts().Return(network.Timeouts{})
+
+	proxyAddr := startAuthProxy(t, exchangeSvc, users)
+
+	resp, err := proxyClient(proxyAddr).Get("http://service1.example/path")
+	require.NoError(t, err)
+
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusProxyAuthRequired, resp.StatusCode)
+
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(&url.URL{
+			Scheme: "http",
+			User:   url.UserPassword("alice", "password"),
+			Host:   proxyAddr,
+		})},
+		Timeout: 5 * time.Second,
+	}
+
+	resp, err = client.Get("http://service1.example/path")
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+}
@@ -0,0 +1,204 @@
+package httpproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"go.opentelemetry.io/otel"
+)
+
+const readHeaderTimeout = 10 * time.Second
+
+type ExchangeService interface {
+	NewConnection(ctx context.Context, address *network.Address) (net.Conn, error)
+	BridgeTimeouts() network.Timeouts
+}
+
+// UserStore authenticates the proxy clients and checks the destinations they may connect to.
+type UserStore interface {
+	Authenticate(name, password string) error
+	Allowed(name string, addr *network.Address) bool
+}
+
+type Config struct {
+	TLS    *network.TLSConfig `mapstructure:"tls"`
+	Listen string
+}
+
+// Service is the HTTP proxy server of the exchange.
+// It tunnels CONNECT requests and forwards plain HTTP requests with absolute URIs to the services
+// that are exposed by reverse proxies, hosts are addressed as service.namespace[:port].
+// The connections of both are bridged with the services, so the bandwidth limits and the bridge timeouts apply to them.
+// If users are set, clients must authenticate with the Basic Proxy-Authorization header
+// and may connect only to the destinations allowed for them.
+type Service struct {
+	listener net.Listener
+	exchange ExchangeService
+	users    UserStore
+	parser   *network.AddressParser
+	tls      *network.TLSConfig
+	forward  *httputil.ReverseProxy
+	addr     string
+	l        sync.Mutex
+}
+
+// New creates a new HTTP proxy service, the requested hosts are parsed into service addresses by the parser.
+// Clients are authenticated as the users of the store, if it's nil, no authentication is required.
+func New(cfg *Config, exchange ExchangeService, parser *network.AddressParser, users UserStore) *Service {
+	svc := &Service{
+		addr:     cfg.Listen,
+		tls:      cfg.TLS,
+		exchange: exchange,
+		parser:   parser,
+		users:    users,
+		l:        sync.Mutex{},
+	}
+
+	svc.forward = &httputil.ReverseProxy{
+		// The request URI is absolute already, so it's forwarded as is.
+		Rewrite: func(*httputil.ProxyRequest) {},
+		// Connections are not reused, because every connection is authorized for the caller that has requested it.
+		Transport:    &http.Transport{DialContext: svc.dialForward, DisableKeepAlives: true},
+		ErrorHandler: svc.handleForwardError,
+	}
+
+	return svc
+}
+
+var tracer = otel.Tracer("github.com/ksysoev/oneway/pkg/svc/httpproxy")
+
+func (s *Service) dial(ctx context.Context, _, address string) (net.Conn, error) {
+	ctx, span := tracer.Start(ctx, "HTTPProxy.Dial")
+	defer span.End()
+
+	addr, err := s.parser.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse address: %w", err)
+	}
+
+	if s.users != nil {
+		if caller, _ := exchange.CallerFromContext(ctx); !s.users.Allowed(caller.User, addr) {
+			return nil, fmt.Errorf("%w: user %s is not allowed to connect to %s", ErrNotAllowed, caller.User, addr)
+		}
+	}
+
+	conn, err := s.exchange.NewConnection(ctx, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service for %s: %w", address, err)
+	}
+
+	return conn, nil
+}
+
+// dialForward connects to the service for the forwarded request.
+// The transport gets the end of the pipe that is bridged with the connection to the service,
+// so the forwarded traffic is limited the same way as the traffic of CONNECT tunnels.
+func (s *Service) dialForward(ctx context.Context, _, address string) (net.Conn, error) {
+	dest, err := s.dial(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	local, remote := net.Pipe()
+
+	bridge := network.NewBridge(remote, dest)
+	bridge.SetTimeouts(s.exchange.BridgeTimeouts())
+
+	// The bridge is closed by the transport when the response is read, it outlives the dial context.
+	go func() {
+		if _, err := bridge.Run(context.WithoutCancel(ctx)); err != nil {
+			slog.DebugContext(ctx, "forwarded connection is closed with error", slog.Any("error", err))
+		}
+	}()
+
+	return local, nil
+}
+
+// dialStatusCode returns the HTTP status code that corresponds to the error of connecting to the service.
+func dialStatusCode(err error) int {
+	switch {
+	case errors.Is(err, network.ErrInvalidAddress):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotAllowed), errors.Is(err, exchange.ErrAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, exchange.ErrRateLimited), errors.Is(err, exchange.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, network.ErrConnTimeout), errors.Is(err, exchange.ErrConnReqExpired):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func (s *Service) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tlsConfig, err := s.tls.ServerConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS config: %w", err)
+	}
+
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+
+	s.l.Lock()
+	s.listener = lis
+	s.l.Unlock()
+
+	srv := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	slog.Info("HTTP Proxy Server started", slog.String("address", lis.Addr().String()))
+
+	go func() {
+		<-ctx.Done()
+
+		// Forwarded requests are let to finish, they are cut by the exchange drain if they run too long.
+		if err := srv.Shutdown(context.Background()); err != nil {
+			slog.Error("Failed to close HTTP Proxy server", slog.Any("error", err))
+		}
+	}()
+
+	if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Service) Close() error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	return s.listener.Close()
+}
+
+func (s *Service) Addr() string {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+
+	return s.listener.Addr().String()
+}
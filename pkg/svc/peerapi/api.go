@@ -0,0 +1,269 @@
+package peerapi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ksysoev/oneway/api/peer"
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+)
+
+var (
+	ErrInvalidNodeID = fmt.Errorf("node id must be between 1 and 65535")
+	ErrNoToken       = fmt.Errorf("cluster token is required")
+)
+
+const (
+	defaultSyncInterval = 5 * time.Second
+	syncTimeout         = 5 * time.Second
+)
+
+type ExchangeService interface {
+	NewPeerConnection(ctx context.Context, addr *network.Address) (net.Conn, error)
+	AddPeerConnection(nameSpace string, id uint64, conn net.Conn) error
+	RejectPeerConnection(nameSpace string, id uint64, reason error) error
+	Services() map[string][]string
+	BridgeTimeouts() network.Timeouts
+}
+
+type PeerRegistry interface {
+	Update(node uint16, peer exchange.Peer, services map[string][]string)
+	Remove(node uint16)
+}
+
+// Config is the configuration of the cluster of exchanges.
+// NodeID must be unique in the cluster, it's kept in the connection request ids to route reverse connections
+// that arrive to other nodes back to the node that has issued the request.
+// Peers are the addresses of the other nodes, the address of the node itself can be listed too,
+// so all nodes can share the same list. The services registered on the peers are synced every SyncInterval.
+// TLS is used by the peer server and PeerTLS by the connections to the peers, Token authenticates the nodes
+// and their requests, it's required.
+type Config struct {
+	TLS          *network.TLSConfig `mapstructure:"tls"`
+	PeerTLS      *network.TLSConfig `mapstructure:"peer_tls"`
+	Listen       string
+	Token        string
+	Peers        []string      `mapstructure:"peers"`
+	SyncInterval time.Duration `mapstructure:"sync_interval"`
+	NodeID       uint16        `mapstructure:"node_id"`
+}
+
+type API struct {
+	exchange     ExchangeService
+	peers        PeerRegistry
+	tls          *network.TLSConfig
+	peerTLS      *network.TLSConfig
+	listen       string
+	token        string
+	addrs        []string
+	syncInterval time.Duration
+	nodeID       uint16
+}
+
+// New creates the API of the exchange node for the other nodes of the cluster.
+// The services of the peers are kept in the registry.
+// It returns an error if the node id or the token is not set.
+func New(cfg *Config, exchangeSvc ExchangeService, peers PeerRegistry) (*API, error) {
+	if cfg.NodeID == 0 {
+		return nil, ErrInvalidNodeID
+	}
+
+	if cfg.Token == "" {
+		return nil, ErrNoToken
+	}
+
+	syncInterval := cfg.SyncInterval
+	if syncInterval <= 0 {
+		syncInterval = defaultSyncInterval
+	}
+
+	return &API{
+		exchange:     exchangeSvc,
+		peers:        peers,
+		tls:          cfg.TLS,
+		peerTLS:      cfg.PeerTLS,
+		listen:       cfg.Listen,
+		token:        cfg.Token,
+		addrs:        cfg.Peers,
+		syncInterval: syncInterval,
+		nodeID:       cfg.NodeID,
+	}, nil
+}
+
+// Run serves the requests of the peers and syncs the services registered on them until the context is done.
+func (a *API) Run(ctx context.Context) error {
+	tlsConfig, err := a.tls.ServerConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS config: %w", err)
+	}
+
+	peerTLSConfig, err := a.peerTLS.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load peer TLS config: %w", err)
+	}
+
+	lis, err := net.Listen("tcp", a.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	for _, addr := range a.addrs {
+		client := peer.NewClient(addr, peer.WithClientToken(a.token), peer.WithClientTLS(peerTLSConfig))
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			a.sync(ctx, client)
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		if err := lis.Close(); err != nil {
+			slog.Error("Failed to close listener", slog.Any("error", err))
+		}
+	}()
+
+	slog.Info("Peer API started", slog.String("address", lis.Addr().String()), slog.Any("node", a.nodeID))
+
+	srv := peer.NewServer(func(req *peer.Request, conn *peer.Conn) {
+		a.handle(ctx, req, conn)
+	}, peer.WithServerToken(a.token))
+
+	err = srv.Serve(lis)
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.EPIPE) {
+		return nil
+	}
+
+	return err
+}
+
+// sync polls the services registered on the peer until the context is done.
+// The peer is removed from the registry while it's unavailable.
+// If the address belongs to this node, syncing stops.
+func (a *API) sync(ctx context.Context, client *peer.Client) {
+	var node uint16
+
+	defer func() {
+		if node != 0 {
+			a.peers.Remove(node)
+		}
+	}()
+
+	ticker := time.NewTicker(a.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		syncCtx, cancel := context.WithTimeout(ctx, syncTimeout)
+		members, err := client.Members(syncCtx)
+
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			if node != 0 {
+				slog.WarnContext(ctx, "peer is unavailable", slog.String("address", client.Addr()), slog.Any("node", node), slog.Any("error", err))
+				a.peers.Remove(node)
+				node = 0
+			}
+		case members.Node == a.nodeID:
+			return
+		default:
+			if node != members.Node {
+				if node != 0 {
+					a.peers.Remove(node)
+				}
+
+				slog.InfoContext(ctx, "peer is available", slog.String("address", client.Addr()), slog.Any("node", members.Node))
+			}
+
+			node = members.Node
+			a.peers.Update(node, &remotePeer{client: client}, members.Services)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handle serves the request of the peer.
+func (a *API) handle(ctx context.Context, req *peer.Request, conn *peer.Conn) {
+	var err error
+
+	switch req.Op {
+	case peer.OpMembers:
+		err = conn.Reply(&peer.Members{Node: a.nodeID, Services: a.exchange.Services()})
+	case peer.OpDial:
+		err = a.dial(ctx, req, conn)
+	case peer.OpDeliver:
+		// The connection is replied before it's delivered, because the requester may start writing to it immediately.
+		if err = conn.Reply(nil); err == nil {
+			if err = a.exchange.AddPeerConnection(req.NameSpace, req.ID, conn); err == nil {
+				return
+			}
+		}
+	case peer.OpReject:
+		reason := errorOf(&peer.Error{Status: req.Status, Message: req.Message})
+
+		if err = a.exchange.RejectPeerConnection(req.NameSpace, req.ID, reason); err != nil {
+			err = errors.Join(err, fail(conn, err))
+		} else {
+			err = conn.Reply(nil)
+		}
+	default:
+		err = conn.Fail(peer.StatusBadRequest, "unsupported operation")
+	}
+
+	if err != nil {
+		slog.DebugContext(ctx, "failed to handle peer request", slog.Any("error", err), slog.Any("op", req.Op))
+	}
+
+	_ = conn.Close()
+}
+
+// dial connects to the requested service on behalf of the caller the peer has accepted the request from
+// and bridges the connection of the peer with it within the bridge timeouts of the exchange.
+func (a *API) dial(ctx context.Context, req *peer.Request, conn *peer.Conn) error {
+	addr := &network.Address{NameSpace: req.NameSpace, Service: req.Service, Port: req.Port}
+
+	dest, err := a.exchange.NewPeerConnection(withCaller(ctx, req.Caller), addr)
+	if err != nil {
+		return errors.Join(err, fail(conn, err))
+	}
+
+	defer dest.Close()
+
+	if err := conn.Reply(nil); err != nil {
+		return err
+	}
+
+	bridge := network.NewBridge(conn, dest)
+	bridge.SetTimeouts(a.exchange.BridgeTimeouts())
+
+	_, err = bridge.Run(ctx)
+
+	return err
+}
@@ -0,0 +1,63 @@
+package peerapi
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/ksysoev/oneway/api/peer"
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		cfg     *Config
+		name    string
+	}{
+		{name: "valid config", cfg: &Config{NodeID: 1, Token: "token"}},
+		{name: "no node id", cfg: &Config{Token: "token"}, wantErr: ErrInvalidNodeID},
+		{name: "no token", cfg: &Config{NodeID: 1}, wantErr: ErrNoToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.cfg, nil, nil)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, a)
+
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotNil(t, a)
+		})
+	}
+}
+
+func TestCaller(t *testing.T) {
+	caller := exchange.Caller{Source: netip.MustParseAddr("10.0.0.1"), User: "alice", Certificate: "client1"}
+
+	c := callerOf(exchange.WithCaller(context.Background(), caller))
+	assert.Equal(t, &peer.Caller{Source: "10.0.0.1", User: "alice", Certificate: "client1"}, c)
+
+	got, ok := exchange.CallerFromContext(withCaller(context.Background(), c))
+	assert.True(t, ok)
+	assert.Equal(t, caller, got)
+
+	// The request without the caller is served without it.
+	assert.Nil(t, callerOf(context.Background()))
+
+	_, ok = exchange.CallerFromContext(withCaller(context.Background(), nil))
+	assert.False(t, ok)
+}
+
+func TestStatusOf(t *testing.T) {
+	for _, err := range []error{exchange.ErrAccessDenied, exchange.ErrRateLimited, exchange.ErrQuotaExceeded} {
+		status, _ := statusOf(err)
+		assert.ErrorIs(t, errorOf(&peer.Error{Status: status}), err)
+	}
+}
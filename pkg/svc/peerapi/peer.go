@@ -0,0 +1,140 @@
+package peerapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+
+	"github.com/ksysoev/oneway/api/peer"
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+)
+
+var ErrPeerFailed = fmt.Errorf("peer failed")
+
+// statusErrors maps the statuses of the peer protocol to the errors,
+// the errors of the destination go first because the rejected requests wrap them.
+var statusErrors = []struct {
+	err    error
+	status peer.Status
+}{
+	{network.ErrConnRefused, peer.StatusConnRefused},
+	{network.ErrHostUnreachable, peer.StatusHostUnreachable},
+	{network.ErrNetUnreachable, peer.StatusNetUnreachable},
+	{network.ErrConnTimeout, peer.StatusTimeout},
+	{network.ErrInvalidAddress, peer.StatusInvalidAddress},
+	{exchange.ErrRevProxyNotFound, peer.StatusRevProxyNotFound},
+	{exchange.ErrServiceNotFound, peer.StatusServiceNotFound},
+	{exchange.ErrConnReqNotFound, peer.StatusRequestNotFound},
+	{exchange.ErrNameSpaceMismatch, peer.StatusNameSpaceMismatch},
+	{exchange.ErrConnReqExpired, peer.StatusRequestExpired},
+	{exchange.ErrConnRejected, peer.StatusRejected},
+	{exchange.ErrAccessDenied, peer.StatusAccessDenied},
+	{exchange.ErrRateLimited, peer.StatusRateLimited},
+	{exchange.ErrQuotaExceeded, peer.StatusQuotaExceeded},
+}
+
+// remotePeer is the other node of the cluster that is reached over the peer protocol.
+type remotePeer struct {
+	client *peer.Client
+}
+
+// NewConnection requests the connection to the service from the peer on behalf of the caller from the context.
+func (p *remotePeer) NewConnection(ctx context.Context, addr *network.Address) (net.Conn, error) {
+	conn, err := p.client.Dial(ctx, addr.NameSpace, addr.Service, addr.Port, callerOf(ctx))
+	if err != nil {
+		return nil, errorOf(err)
+	}
+
+	return conn, nil
+}
+
+// AddConnection delivers the reverse connection to the peer and bridges it with the peer in the background.
+func (p *remotePeer) AddConnection(ctx context.Context, nameSpace string, id uint64, conn net.Conn) error {
+	dest, err := p.client.Deliver(ctx, nameSpace, id)
+	if err != nil {
+		return errorOf(err)
+	}
+
+	go func() {
+		if _, err := network.NewBridge(conn, dest).Run(context.Background()); err != nil {
+			slog.Debug("delivered connection is closed with error", slog.Any("error", err), slog.Uint64("id", id))
+		}
+	}()
+
+	return nil
+}
+
+// RejectConnection rejects the connection request issued by the peer.
+func (p *remotePeer) RejectConnection(ctx context.Context, nameSpace string, id uint64, reason error) error {
+	status, message := statusOf(reason)
+
+	return errorOf(p.client.Reject(ctx, nameSpace, id, status, message))
+}
+
+// callerOf returns the caller from the context in the form of the peer protocol.
+func callerOf(ctx context.Context) *peer.Caller {
+	caller, ok := exchange.CallerFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	c := &peer.Caller{User: caller.User, Certificate: caller.Certificate}
+
+	if caller.Source.IsValid() {
+		c.Source = caller.Source.String()
+	}
+
+	return c
+}
+
+// withCaller returns the context that carries the caller received from the peer.
+// The source address that can't be parsed is left unset.
+func withCaller(ctx context.Context, c *peer.Caller) context.Context {
+	if c == nil {
+		return ctx
+	}
+
+	caller := exchange.Caller{User: c.User, Certificate: c.Certificate}
+	caller.Source, _ = netip.ParseAddr(c.Source)
+
+	return exchange.WithCaller(ctx, caller)
+}
+
+// statusOf returns the status of the peer protocol and the message for the error.
+func statusOf(err error) (peer.Status, string) {
+	for _, se := range statusErrors {
+		if errors.Is(err, se.err) {
+			return se.status, err.Error()
+		}
+	}
+
+	return peer.StatusFailed, err.Error()
+}
+
+// errorOf converts the error status replied by the peer to the corresponding error.
+// Other errors are returned as is.
+func errorOf(err error) error {
+	var perr *peer.Error
+	if !errors.As(err, &perr) {
+		return err
+	}
+
+	for _, se := range statusErrors {
+		if se.status == perr.Status {
+			return fmt.Errorf("%w: %s", se.err, perr.Message)
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrPeerFailed, perr.Message)
+}
+
+// fail replies to the request of the peer with the status of the error.
+func fail(conn *peer.Conn, err error) error {
+	status, message := statusOf(err)
+
+	return conn.Fail(status, message)
+}
@@ -0,0 +1,366 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+)
+
+const socks5Version = 5
+
+const (
+	defaultHandshakeTimeout = 10 * time.Second
+	minAcceptDelay          = 5 * time.Millisecond
+	maxAcceptDelay          = time.Second
+)
+
+type authMethod byte
+
+const (
+	noAuthRequired      authMethod = 0
+	usernamePassword    authMethod = 2
+	noAcceptableMethods authMethod = 255
+)
+
+// The username/password authentication is defined by RFC 1929.
+const (
+	userPassVersion byte = 1
+	userPassSuccess byte = 0
+	userPassFailure byte = 1
+)
+
+type commandType byte
+
+const connectCommand commandType = 1
+
+type addrType byte
+
+const (
+	ipv4Addr   addrType = 1
+	domainAddr addrType = 3
+	ipv6Addr   addrType = 4
+)
+
+// replyCode is the status of the SOCKS5 request, defined by RFC 1928.
+type replyCode byte
+
+const (
+	replySucceeded            replyCode = 0
+	replyGeneralFailure       replyCode = 1
+	replyNotAllowed           replyCode = 2
+	replyNetworkUnreachable   replyCode = 3
+	replyHostUnreachable      replyCode = 4
+	replyConnectionRefused    replyCode = 5
+	replyTTLExpired           replyCode = 6
+	replyCommandNotSupported  replyCode = 7
+	replyAddrTypeNotSupported replyCode = 8
+)
+
+var (
+	ErrUnsupportedVersion = fmt.Errorf("unsupported SOCKS version")
+	ErrNoAcceptableMethod = fmt.Errorf("no acceptable authentication method")
+	ErrUnsupportedCommand = fmt.Errorf("unsupported SOCKS command")
+	ErrUnsupportedAddr    = fmt.Errorf("unsupported address type")
+	ErrInvalidAuthVersion = fmt.Errorf("invalid username/password authentication version")
+)
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// socks5Server is a SOCKS5 server that supports only the CONNECT command.
+// If users are configured, clients must authenticate with username and password,
+// and the authenticated user is passed to dial in the context, otherwise no authentication is required.
+// Errors of dialing the destination are reported to the client with the corresponding reply code.
+// The client has handshakeTimeout to authenticate and send the request,
+// the established connections are bridged with the destinations within the timeouts.
+type socks5Server struct {
+	dial             dialFunc
+	users            *UserStore
+	timeouts         network.Timeouts
+	handshakeTimeout time.Duration
+}
+
+// Serve accepts connections on the listener and serves them in separate goroutines.
+// Temporary errors of accepting connections, like running out of file descriptors, are retried with the growing delay,
+// as net/http does. It returns when the listener fails with other errors, for example when the listener is closed.
+func (s *socks5Server) Serve(l net.Listener) error {
+	var delay time.Duration
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Temporary() { //nolint:staticcheck // net/http retries the same errors
+				return err
+			}
+
+			delay = min(max(2*delay, minAcceptDelay), maxAcceptDelay)
+
+			slog.Warn("failed to accept SOCKS5 connection, retrying", slog.Any("error", err), slog.Duration("delay", delay))
+			time.Sleep(delay)
+
+			continue
+		}
+
+		delay = 0
+
+		go func() {
+			defer conn.Close()
+
+			if err := s.serveConn(context.Background(), conn); err != nil {
+				slog.Debug("failed to serve SOCKS5 connection", slog.Any("error", err), slog.String("remote", conn.RemoteAddr().String()))
+			}
+		}()
+	}
+}
+
+// serveConn performs the SOCKS5 handshake, connects to the requested destination
+// and bridges the client connection with it.
+// The deadline of the connection is set until the request is read, so stalled clients don't hold the connection.
+func (s *socks5Server) serveConn(ctx context.Context, conn net.Conn) error {
+	if s.handshakeTimeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(s.handshakeTimeout)); err != nil {
+			return fmt.Errorf("failed to set handshake deadline: %w", err)
+		}
+	}
+
+	ctx, err := s.negotiateAuth(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	ctx = exchange.WithCaller(ctx, newCaller(conn, userFromContext(ctx)))
+
+	addr, err := readRequest(conn)
+	if err != nil {
+		code := replyGeneralFailure
+
+		switch {
+		case errors.Is(err, ErrUnsupportedCommand):
+			code = replyCommandNotSupported
+		case errors.Is(err, ErrUnsupportedAddr):
+			code = replyAddrTypeNotSupported
+		}
+
+		return errors.Join(err, writeReply(conn, code))
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("failed to clear handshake deadline: %w", err)
+	}
+
+	dest, err := s.dial(ctx, "tcp", addr)
+	if err != nil {
+		return errors.Join(err, writeReply(conn, dialReplyCode(err)))
+	}
+
+	defer dest.Close()
+
+	if err := writeReply(conn, replySucceeded); err != nil {
+		return err
+	}
+
+	bridge := network.NewBridge(conn, dest)
+	bridge.SetTimeouts(s.timeouts)
+
+	_, err = bridge.Run(ctx)
+
+	return err
+}
+
+// newCaller returns the identity of the client for the access control policy:
+// the name of the authenticated user, the common name of the client certificate and the source address.
+func newCaller(conn net.Conn, u *user) exchange.Caller {
+	var caller exchange.Caller
+
+	if u != nil {
+		caller.User = u.name
+	}
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+			caller.Certificate = certs[0].Subject.CommonName
+		}
+	}
+
+	if addr, err := netip.ParseAddrPort(conn.RemoteAddr().String()); err == nil {
+		caller.Source = addr.Addr().Unmap()
+	}
+
+	return caller
+}
+
+// negotiateAuth reads the client greeting, selects the authentication method and authenticates the client.
+// It returns the context that carries the authenticated user.
+func (s *socks5Server) negotiateAuth(ctx context.Context, conn net.Conn) (context.Context, error) {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return nil, fmt.Errorf("failed to read greeting: %w", err)
+	}
+
+	if hdr[0] != socks5Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, hdr[0])
+	}
+
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, fmt.Errorf("failed to read authentication methods: %w", err)
+	}
+
+	required := noAuthRequired
+	if s.users != nil {
+		required = usernamePassword
+	}
+
+	for _, method := range methods {
+		if authMethod(method) != required {
+			continue
+		}
+
+		if _, err := conn.Write([]byte{socks5Version, byte(required)}); err != nil {
+			return nil, err
+		}
+
+		if required == noAuthRequired {
+			return ctx, nil
+		}
+
+		u, err := s.authenticate(conn)
+		if err != nil {
+			return nil, err
+		}
+
+		return withUser(ctx, u), nil
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, byte(noAcceptableMethods)}); err != nil {
+		return nil, err
+	}
+
+	return nil, ErrNoAcceptableMethod
+}
+
+// authenticate reads the username/password request, checks the credentials and writes the status.
+func (s *socks5Server) authenticate(conn net.Conn) (*user, error) {
+	ver := make([]byte, 1)
+	if _, err := io.ReadFull(conn, ver); err != nil {
+		return nil, fmt.Errorf("failed to read authentication request: %w", err)
+	}
+
+	if ver[0] != userPassVersion {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidAuthVersion, ver[0])
+	}
+
+	name, err := readAuthField(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read username: %w", err)
+	}
+
+	password, err := readAuthField(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password: %w", err)
+	}
+
+	u, err := s.users.authenticate(name, password)
+
+	status := userPassSuccess
+	if err != nil {
+		status = userPassFailure
+	}
+
+	if _, werr := conn.Write([]byte{userPassVersion, status}); werr != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to write authentication status: %w", werr))
+	}
+
+	return u, err
+}
+
+// readAuthField reads the length-prefixed field of the username/password request.
+func readAuthField(conn net.Conn) (string, error) {
+	length := make([]byte, 1)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return "", err
+	}
+
+	field := make([]byte, length[0])
+	if _, err := io.ReadFull(conn, field); err != nil {
+		return "", err
+	}
+
+	return string(field), nil
+}
+
+// readRequest reads the SOCKS5 request and returns the requested destination in the host:port format.
+func readRequest(conn net.Conn) (string, error) {
+	hdr := make([]byte, 4)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return "", fmt.Errorf("failed to read request: %w", err)
+	}
+
+	if hdr[0] != socks5Version {
+		return "", fmt.Errorf("%w: %d", ErrUnsupportedVersion, hdr[0])
+	}
+
+	if commandType(hdr[1]) != connectCommand {
+		return "", fmt.Errorf("%w: %d", ErrUnsupportedCommand, hdr[1])
+	}
+
+	var host string
+
+	switch addrType(hdr[3]) {
+	case ipv4Addr, ipv6Addr:
+		ip := make(net.IP, net.IPv4len)
+		if addrType(hdr[3]) == ipv6Addr {
+			ip = make(net.IP, net.IPv6len)
+		}
+
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", fmt.Errorf("failed to read address: %w", err)
+		}
+
+		host = ip.String()
+	case domainAddr:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", fmt.Errorf("failed to read address: %w", err)
+		}
+
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", fmt.Errorf("failed to read address: %w", err)
+		}
+
+		host = string(domain)
+	default:
+		return "", fmt.Errorf("%w: %d", ErrUnsupportedAddr, hdr[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", fmt.Errorf("failed to read port: %w", err)
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// writeReply writes the SOCKS5 reply with the given code.
+// The bound address is always reported as 0.0.0.0:0, because the connection to the destination is made by the revproxy.
+func writeReply(conn net.Conn, code replyCode) error {
+	reply := []byte{socks5Version, byte(code), 0, byte(ipv4Addr), 0, 0, 0, 0, 0, 0}
+
+	if _, err := conn.Write(reply); err != nil {
+		return fmt.Errorf("failed to write reply: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,433 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ksysoev/oneway/pkg/core/exchange"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bufConn is the connection that reads the prepared input and records the output.
+type bufConn struct {
+	net.Conn
+	in  io.Reader
+	out bytes.Buffer
+}
+
+func newBufConn(in ...byte) *bufConn {
+	return &bufConn{in: bytes.NewReader(in)}
+}
+
+func (c *bufConn) Read(b []byte) (int, error)  { return c.in.Read(b) }
+func (c *bufConn) Write(b []byte) (int, error) { return c.out.Write(b) }
+func (c *bufConn) SetDeadline(time.Time) error { return nil }
+func (c *bufConn) RemoteAddr() net.Addr        { return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 12345} }
+
+func TestSocks5Server_NegotiateAuth(t *testing.T) {
+	tests := []struct {
+		wantErr   error
+		name      string
+		greeting  []byte
+		wantReply []byte
+	}{
+		{
+			name:      "no authentication",
+			greeting:  []byte{socks5Version, 1, byte(noAuthRequired)},
+			wantReply: []byte{socks5Version, byte(noAuthRequired)},
+		},
+		{
+			name:      "no authentication among other methods",
+			greeting:  []byte{socks5Version, 3, 1, byte(usernamePassword), byte(noAuthRequired)},
+			wantReply: []byte{socks5Version, byte(noAuthRequired)},
+		},
+		{
+			name:      "no acceptable method",
+			greeting:  []byte{socks5Version, 1, byte(usernamePassword)},
+			wantReply: []byte{socks5Version, byte(noAcceptableMethods)},
+			wantErr:   ErrNoAcceptableMethod,
+		},
+		{
+			name:      "no methods",
+			greeting:  []byte{socks5Version, 0},
+			wantReply: []byte{socks5Version, byte(noAcceptableMethods)},
+			wantErr:   ErrNoAcceptableMethod,
+		},
+		{
+			name:     "unsupported version",
+			greeting: []byte{4, 1, byte(noAuthRequired)},
+			wantErr:  ErrUnsupportedVersion,
+		},
+		{
+			name:     "truncated methods",
+			greeting: []byte{socks5Version, 2, byte(noAuthRequired)},
+			wantErr:  io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &socks5Server{}
+			conn := newBufConn(tt.greeting...)
+
+			_, err := srv.negotiateAuth(context.Background(), conn)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantReply, conn.out.Bytes())
+		})
+	}
+}
+
+// userPassRequest returns the username/password request defined by RFC 1929.
+func userPassRequest(name, password string) []byte {
+	req := append([]byte{userPassVersion, byte(len(name))}, name...)
+	req = append(req, byte(len(password)))
+
+	return append(req, password...)
+}
+
+func TestSocks5Server_NegotiateAuth_UserPass(t *testing.T) {
+	greeting := []byte{socks5Version, 2, byte(noAuthRequired), byte(usernamePassword)}
+	selected := []byte{socks5Version, byte(usernamePassword)}
+
+	tests := []struct {
+		wantErr   error
+		name      string
+		wantUser  string
+		request   []byte
+		wantReply []byte
+	}{
+		{
+			name:      "valid password",
+			request:   append(greeting, userPassRequest("alice", "alice-password")...),
+			wantReply: append(selected, userPassVersion, userPassSuccess),
+			wantUser:  "alice",
+		},
+		{
+			name:      "wrong password",
+			request:   append(greeting, userPassRequest("alice", "wrong")...),
+			wantReply: append(selected, userPassVersion, userPassFailure),
+			wantErr:   ErrAuthFailed,
+		},
+		{
+			name:      "unknown user",
+			request:   append(greeting, userPassRequest("mallory", "alice-password")...),
+			wantReply: append(selected, userPassVersion, userPassFailure),
+			wantErr:   ErrAuthFailed,
+		},
+		{
+			name:      "invalid sub-negotiation version",
+			request:   append(greeting, append([]byte{5}, userPassRequest("alice", "alice-password")[1:]...)...),
+			wantReply: selected,
+			wantErr:   ErrInvalidAuthVersion,
+		},
+		{
+			name:      "truncated password",
+			request:   append(greeting, userPassRequest("alice", "alice-password")[:10]...),
+			wantReply: selected,
+			wantErr:   io.ErrUnexpectedEOF,
+		},
+		{
+			name:      "no authentication is not accepted",
+			request:   []byte{socks5Version, 1, byte(noAuthRequired)},
+			wantReply: []byte{socks5Version, byte(noAcceptableMethods)},
+			wantErr:   ErrNoAcceptableMethod,
+		},
+	}
+
+	srv := &socks5Server{users: newTestUserStore(t)}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newBufConn(tt.request...)
+
+			ctx, err := srv.negotiateAuth(context.Background(), conn)
+
+			assert.Equal(t, tt.wantReply, conn.out.Bytes())
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantUser, userFromContext(ctx).name)
+		})
+	}
+}
+
+func TestSocks5Server_ServeConn_NameSpaceDenied(t *testing.T) {
+	svc := &Service{authRequired: true, parser: network.NewAddressParser(nil)}
+	srv := &socks5Server{dial: svc.dial, users: newTestUserStore(t)}
+
+	// alice is allowed to connect only to the example name space.
+	request := append([]byte{socks5Version, 1, byte(usernamePassword)}, userPassRequest("alice", "alice-password")...)
+	request = append(request, socks5Version, byte(connectCommand), 0, byte(domainAddr), 16)
+	request = append(append(request, "service1.private"...), 0, 80)
+
+	conn := newBufConn(request...)
+
+	err := srv.serveConn(context.Background(), conn)
+	assert.ErrorIs(t, err, ErrNotAllowed)
+
+	reply := conn.out.Bytes()
+	require.Len(t, reply, 14)
+	assert.Equal(t, []byte{socks5Version, byte(usernamePassword), userPassVersion, userPassSuccess}, reply[:4])
+	assert.Equal(t, byte(replyNotAllowed), reply[5])
+}
+
+func TestReadRequest(t *testing.T) {
+	tests := []struct {
+		wantErr error
+		name    string
+		want    string
+		request []byte
+	}{
+		{
+			name:    "IPv4",
+			request: []byte{socks5Version, byte(connectCommand), 0, byte(ipv4Addr), 127, 0, 0, 1, 0x1f, 0x90},
+			want:    "127.0.0.1:8080",
+		},
+		{
+			name: "domain",
+			request: append(
+				append([]byte{socks5Version, byte(connectCommand), 0, byte(domainAddr), 16}, "service1.example"...),
+				0, 80,
+			),
+			want: "service1.example:80",
+		},
+		{
+			name: "IPv6",
+			request: []byte{
+				socks5Version, byte(connectCommand), 0, byte(ipv6Addr),
+				0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+				0x01, 0xbb,
+			},
+			want: "[2001:db8::1]:443",
+		},
+		{
+			name:    "unsupported version",
+			request: []byte{4, byte(connectCommand), 0, byte(ipv4Addr), 127, 0, 0, 1, 0, 80},
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "bind command",
+			request: []byte{socks5Version, 2, 0, byte(ipv4Addr), 127, 0, 0, 1, 0, 80},
+			wantErr: ErrUnsupportedCommand,
+		},
+		{
+			name:    "UDP associate command",
+			request: []byte{socks5Version, 3, 0, byte(ipv4Addr), 127, 0, 0, 1, 0, 80},
+			wantErr: ErrUnsupportedCommand,
+		},
+		{
+			name:    "unsupported address type",
+			request: []byte{socks5Version, byte(connectCommand), 0, 2, 127, 0, 0, 1, 0, 80},
+			wantErr: ErrUnsupportedAddr,
+		},
+		{
+			name:    "truncated address",
+			request: []byte{socks5Version, byte(connectCommand), 0, byte(domainAddr), 16, 's'},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "missing port",
+			request: []byte{socks5Version, byte(connectCommand), 0, byte(ipv4Addr), 127, 0, 0, 1},
+			wantErr: io.EOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := readRequest(newBufConn(tt.request...))
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, addr)
+		})
+	}
+}
+
+func TestDialReplyCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		name string
+		want replyCode
+	}{
+		{name: "not allowed", err: ErrNotAllowed, want: replyNotAllowed},
+		{name: "access denied", err: exchange.ErrAccessDenied, want: replyNotAllowed},
+		{name: "connection refused", err: network.ErrConnRefused, want: replyConnectionRefused},
+		{name: "network unreachable", err: network.ErrNetUnreachable, want: replyNetworkUnreachable},
+		{name: "invalid address", err: network.ErrInvalidAddress, want: replyHostUnreachable},
+		{name: "host unreachable", err: network.ErrHostUnreachable, want: replyHostUnreachable},
+		{name: "revproxy not found", err: exchange.ErrRevProxyNotFound, want: replyHostUnreachable},
+		{name: "service not found", err: exchange.ErrServiceNotFound, want: replyHostUnreachable},
+		{name: "connection timeout", err: network.ErrConnTimeout, want: replyTTLExpired},
+		{name: "request expired", err: exchange.ErrConnReqExpired, want: replyTTLExpired},
+		{name: "rate limited", err: exchange.ErrRateLimited, want: replyNotAllowed},
+		{name: "quota exceeded", err: exchange.ErrQuotaExceeded, want: replyNotAllowed},
+		{name: "wrapped error", err: fmt.Errorf("failed to get service: %w", exchange.ErrQuotaExceeded), want: replyNotAllowed},
+		{name: "unknown error", err: assert.AnError, want: replyGeneralFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, dialReplyCode(tt.err))
+		})
+	}
+}
+
+func TestSocks5Server_ServeConn_DialError(t *testing.T) {
+	srv := &socks5Server{
+		dial: func(_ context.Context, _, address string) (net.Conn, error) {
+			assert.Equal(t, "service1.example:80", address)
+			return nil, exchange.ErrQuotaExceeded
+		},
+	}
+
+	request := append([]byte{socks5Version, 1, byte(noAuthRequired)}, socks5Version, byte(connectCommand), 0, byte(domainAddr), 16)
+	request = append(append(request, "service1.example"...), 0, 80)
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		_, _ = client.Write(request)
+	}()
+
+	done := make(chan error, 1)
+
+	go func() {
+		defer server.Close()
+		done <- srv.serveConn(context.Background(), server)
+	}()
+
+	reply := make([]byte, 12)
+	_, err := io.ReadFull(client, reply)
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte{socks5Version, byte(noAuthRequired)}, reply[:2])
+	assert.Equal(t, byte(replyNotAllowed), reply[3])
+	assert.ErrorIs(t, <-done, exchange.ErrQuotaExceeded)
+}
+
+func TestSocks5Server_ServeConn_HandshakeTimeout(t *testing.T) {
+	srv := &socks5Server{
+		handshakeTimeout: 50 * time.Millisecond,
+		dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, assert.AnError
+		},
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// The client sends the greeting, but never sends the request.
+	go func() {
+		_, _ = client.Write([]byte{socks5Version, 1, byte(noAuthRequired)})
+		_, _ = io.Copy(io.Discard, client)
+	}()
+
+	start := time.Now()
+	err := srv.serveConn(context.Background(), server)
+
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// flakyListener fails to accept connections with the errors before it accepts the connection.
+type flakyListener struct {
+	net.Listener
+	conn   net.Conn
+	closed chan struct{}
+	errs   []error
+	mu     sync.Mutex
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.errs) > 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+
+		return nil, err
+	}
+
+	if l.conn != nil {
+		conn := l.conn
+		l.conn = nil
+
+		return conn, nil
+	}
+
+	<-l.closed
+
+	return nil, net.ErrClosed
+}
+
+func TestSocks5Server_Serve_RetriesTemporaryErrors(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	lis := &flakyListener{
+		conn:   server,
+		closed: make(chan struct{}),
+		errs: []error{
+			&net.OpError{Op: "accept", Net: "tcp", Err: syscall.EMFILE},
+			&net.OpError{Op: "accept", Net: "tcp", Err: syscall.ECONNABORTED},
+		},
+	}
+
+	srv := &socks5Server{}
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- srv.Serve(lis)
+	}()
+
+	// The connection accepted after the temporary errors is served.
+	_, err := client.Write([]byte{socks5Version, 1, byte(noAuthRequired)})
+	require.NoError(t, err)
+
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(client, reply)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{socks5Version, byte(noAuthRequired)}, reply)
+
+	close(lis.closed)
+
+	assert.ErrorIs(t, <-done, net.ErrClosed)
+}
+
+func TestSocks5Server_Serve_StopsOnPermanentError(t *testing.T) {
+	lis := &flakyListener{errs: []error{errors.New("permanent error")}}
+
+	srv := &socks5Server{}
+
+	assert.EqualError(t, srv.Serve(lis), "permanent error")
+}
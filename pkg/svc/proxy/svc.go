@@ -2,62 +2,108 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
 	"sync"
+	"time"
 
+	"github.com/ksysoev/oneway/pkg/core/exchange"
 	"github.com/ksysoev/oneway/pkg/core/network"
 	"go.opentelemetry.io/otel"
-	"tailscale.com/net/socks5"
 )
 
 type ExchangeService interface {
 	NewConnection(ctx context.Context, address *network.Address) (net.Conn, error)
+	BridgeTimeouts() network.Timeouts
 }
 
 type Server interface {
 	Serve(net.Listener) error
 }
 
+// Config is the configuration of the SOCKS5 proxy server.
+// If Users is not empty, clients must authenticate and may connect only to the destinations allowed for them.
+// HandshakeTimeout is the time that the client has to authenticate and send the request, 10 seconds by default.
 type Config struct {
-	Listen string
+	TLS              *network.TLSConfig `mapstructure:"tls"`
+	Listen           string
+	Users            []UserConfig  `mapstructure:"users"`
+	HandshakeTimeout time.Duration `mapstructure:"handshake_timeout"`
 }
 
 type Service struct {
-	srv      Server
-	listener net.Listener
-	exchange ExchangeService
-	addr     string
-	l        sync.Mutex
+	srv          Server
+	users        *UserStore
+	listener     net.Listener
+	exchange     ExchangeService
+	parser       *network.AddressParser
+	tls          *network.TLSConfig
+	addr         string
+	l            sync.Mutex
+	authRequired bool
 }
 
-func New(cfg *Config, exchange ExchangeService) *Service {
+// New creates a new SOCKS5 proxy service, the requested hosts are parsed into service addresses by the parser.
+// It returns an error if the configuration of the users is invalid.
+func New(cfg *Config, exchange ExchangeService, parser *network.AddressParser) (*Service, error) {
 	svc := &Service{
 		addr:     cfg.Listen,
+		tls:      cfg.TLS,
 		exchange: exchange,
+		parser:   parser,
 		l:        sync.Mutex{},
 	}
 
-	svc.srv = &socks5.Server{
-		Logf:   svc.proxyLogf,
-		Dialer: svc.dial,
+	handshakeTimeout := cfg.HandshakeTimeout
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = defaultHandshakeTimeout
 	}
 
-	return svc
+	srv := &socks5Server{
+		dial:             svc.dial,
+		timeouts:         exchange.BridgeTimeouts(),
+		handshakeTimeout: handshakeTimeout,
+	}
+
+	if len(cfg.Users) > 0 {
+		users, err := newUserStore(cfg.Users)
+		if err != nil {
+			return nil, err
+		}
+
+		srv.users = users
+		svc.users = users
+		svc.authRequired = true
+	}
+
+	svc.srv = srv
+
+	return svc, nil
 }
 
 var tracer = otel.Tracer("github.com/ksysoev/oneway/pkg/svc/proxy")
 
+// Users returns the users of the proxy, or nil if the authentication is not required.
+func (s *Service) Users() *UserStore {
+	return s.users
+}
+
 func (s *Service) dial(ctx context.Context, _, address string) (net.Conn, error) {
 	ctx, span := tracer.Start(ctx, "Proxy.Dial")
 	defer span.End()
 
-	addr, err := network.ParseAddress(address)
+	addr, err := s.parser.Parse(address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse address: %w", err)
 	}
 
+	if err := s.authorize(ctx, addr); err != nil {
+		return nil, err
+	}
+
 	conn, err := s.exchange.NewConnection(ctx, addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get service for %s: %w", address, err)
@@ -66,19 +112,66 @@ func (s *Service) dial(ctx context.Context, _, address string) (net.Conn, error)
 	return conn, nil
 }
 
-func (s *Service) proxyLogf(format string, args ...any) {
-	slog.Info(fmt.Sprintf(format, args...))
+// authorize checks that the authenticated user is allowed to connect to the address.
+// All connections are allowed if the authentication is not required.
+func (s *Service) authorize(ctx context.Context, addr *network.Address) error {
+	if !s.authRequired {
+		return nil
+	}
+
+	u := userFromContext(ctx)
+	if u == nil {
+		return fmt.Errorf("%w: client is not authenticated", ErrNotAllowed)
+	}
+
+	if !u.allowed(addr) {
+		return fmt.Errorf("%w: user %s is not allowed to connect to %s", ErrNotAllowed, u.name, addr)
+	}
+
+	return nil
+}
+
+// dialReplyCode returns the SOCKS5 reply code that corresponds to the error of connecting to the service.
+func dialReplyCode(err error) replyCode {
+	switch {
+	// Exceeded rate limits and quotas are refused by the ruleset too, RFC 1928 has no dedicated reply for them.
+	case errors.Is(err, ErrNotAllowed), errors.Is(err, exchange.ErrAccessDenied),
+		errors.Is(err, exchange.ErrRateLimited), errors.Is(err, exchange.ErrQuotaExceeded):
+		return replyNotAllowed
+	case errors.Is(err, network.ErrConnRefused):
+		return replyConnectionRefused
+	case errors.Is(err, network.ErrNetUnreachable):
+		return replyNetworkUnreachable
+	case errors.Is(err, network.ErrInvalidAddress),
+		errors.Is(err, network.ErrHostUnreachable),
+		errors.Is(err, exchange.ErrRevProxyNotFound),
+		errors.Is(err, exchange.ErrServiceNotFound):
+		return replyHostUnreachable
+	case errors.Is(err, network.ErrConnTimeout), errors.Is(err, exchange.ErrConnReqExpired):
+		return replyTTLExpired
+	default:
+		return replyGeneralFailure
+	}
 }
 
 func (s *Service) Run(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	tlsConfig, err := s.tls.ServerConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS config: %w", err)
+	}
+
 	lis, err := net.Listen("tcp", s.addr)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+
 	s.l.Lock()
 	s.listener = lis
 	s.l.Unlock()
@@ -93,7 +186,11 @@ func (s *Service) Run(ctx context.Context) error {
 		}
 	}()
 
-	return s.srv.Serve(lis)
+	if err := s.srv.Serve(lis); !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+
+	return nil
 }
 
 func (s *Service) Close() error {
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const allowAll = "*"
+
+var (
+	ErrAuthFailed  = fmt.Errorf("authentication failed")
+	ErrNotAllowed  = fmt.Errorf("connection is not allowed")
+	ErrInvalidUser = fmt.Errorf("invalid user configuration")
+)
+
+// UserConfig is the configuration of the proxy user.
+// Password is the bcrypt hash of the user password.
+// Allow is the list of destinations the user may connect to: a namespace allows all its services,
+// service.namespace allows the single service and "*" allows all destinations.
+// As namespaces can have several labels, an entry matches either the namespace or the service.namespace.
+type UserConfig struct {
+	Name     string   `mapstructure:"name"`
+	Password string   `mapstructure:"password"`
+	Allow    []string `mapstructure:"allow"`
+}
+
+type user struct {
+	allow map[string]struct{}
+	name  string
+	hash  []byte
+	all   bool
+}
+
+// UserStore authenticates the proxy users and checks their permissions.
+// It's shared by the SOCKS5 proxy and the HTTP proxy, so their clients are authenticated as the same users.
+type UserStore struct {
+	users map[string]*user
+	// dummyHash is compared with the password of unknown users, it has the highest cost of the users' hashes,
+	// so the time of the failed authentication doesn't reveal whether the user exists.
+	dummyHash []byte
+}
+
+// newUserStore creates the user store from the configuration.
+// It returns an error if a user has no name, is defined twice or the password is not a valid bcrypt hash.
+func newUserStore(cfg []UserConfig) (*UserStore, error) {
+	store := &UserStore{
+		users: make(map[string]*user, len(cfg)),
+	}
+
+	dummyCost := bcrypt.MinCost
+
+	for _, uc := range cfg {
+		if uc.Name == "" {
+			return nil, fmt.Errorf("%w: user name is empty", ErrInvalidUser)
+		}
+
+		if _, ok := store.users[uc.Name]; ok {
+			return nil, fmt.Errorf("%w: user %s is defined more than once", ErrInvalidUser, uc.Name)
+		}
+
+		cost, err := bcrypt.Cost([]byte(uc.Password))
+		if err != nil {
+			return nil, fmt.Errorf("%w: password of user %s is not a bcrypt hash: %w", ErrInvalidUser, uc.Name, err)
+		}
+
+		dummyCost = max(dummyCost, cost)
+
+		u := &user{
+			name:  uc.Name,
+			hash:  []byte(uc.Password),
+			allow: make(map[string]struct{}, len(uc.Allow)),
+		}
+
+		for _, dest := range uc.Allow {
+			if dest == allowAll {
+				u.all = true
+			}
+
+			u.allow[dest] = struct{}{}
+		}
+
+		store.users[uc.Name] = u
+	}
+
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte(allowAll), dummyCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dummy hash: %w", err)
+	}
+
+	store.dummyHash = dummyHash
+
+	return store, nil
+}
+
+// authenticate checks the credentials and returns the authenticated user.
+// It returns ErrAuthFailed if the user doesn't exist or the password doesn't match.
+func (s *UserStore) authenticate(name, password string) (*user, error) {
+	u, ok := s.users[name]
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return nil, fmt.Errorf("%w: unknown user %s", ErrAuthFailed, name)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(u.hash, []byte(password)); err != nil {
+		return nil, fmt.Errorf("%w: invalid password of user %s", ErrAuthFailed, name)
+	}
+
+	return u, nil
+}
+
+// Authenticate checks the credentials of the user, it returns ErrAuthFailed if they don't match.
+func (s *UserStore) Authenticate(name, password string) error {
+	_, err := s.authenticate(name, password)
+	return err
+}
+
+// Allowed reports whether the user may connect to the address, unknown users may not connect anywhere.
+func (s *UserStore) Allowed(name string, addr *network.Address) bool {
+	u, ok := s.users[name]
+
+	return ok && u.allowed(addr)
+}
+
+// allowed reports whether the user may connect to the address.
+func (u *user) allowed(addr *network.Address) bool {
+	if u.all {
+		return true
+	}
+
+	if _, ok := u.allow[addr.NameSpace]; ok {
+		return true
+	}
+
+	_, ok := u.allow[addr.String()]
+
+	return ok
+}
+
+type userKey struct{}
+
+// withUser returns the context that carries the authenticated user.
+func withUser(ctx context.Context, u *user) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// userFromContext returns the authenticated user from the context, or nil if there is none.
+func userFromContext(ctx context.Context) *user {
+	u, _ := ctx.Value(userKey{}).(*user)
+	return u
+}
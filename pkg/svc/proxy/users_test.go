@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return string(hash)
+}
+
+func newTestUserStore(t *testing.T) *UserStore {
+	t.Helper()
+
+	store, err := newUserStore([]UserConfig{
+		{Name: "alice", Password: hashPassword(t, "alice-password"), Allow: []string{"example", "service1.other"}},
+		{Name: "bob", Password: hashPassword(t, "bob-password"), Allow: []string{"*"}},
+		{Name: "carol", Password: hashPassword(t, "carol-password")},
+	})
+	require.NoError(t, err)
+
+	return store
+}
+
+func TestNewUserStore_Invalid(t *testing.T) {
+	hash := hashPassword(t, "password")
+
+	tests := []struct {
+		name  string
+		users []UserConfig
+	}{
+		{name: "empty name", users: []UserConfig{{Password: hash}}},
+		{name: "duplicate user", users: []UserConfig{{Name: "alice", Password: hash}, {Name: "alice", Password: hash}}},
+		{name: "plain text password", users: []UserConfig{{Name: "alice", Password: "password"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newUserStore(tt.users)
+			assert.ErrorIs(t, err, ErrInvalidUser)
+		})
+	}
+}
+
+func TestUserStore_Authenticate(t *testing.T) {
+	store := newTestUserStore(t)
+
+	// Unknown users are checked against the hash of the same cost as the users' hashes.
+	cost, err := bcrypt.Cost(store.dummyHash)
+	require.NoError(t, err)
+	assert.Equal(t, bcrypt.MinCost, cost)
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		wantErr  bool
+	}{
+		{name: "valid password", user: "alice", password: "alice-password"},
+		{name: "other user", user: "bob", password: "bob-password"},
+		{name: "wrong password", user: "alice", password: "bob-password", wantErr: true},
+		{name: "empty password", user: "alice", password: "", wantErr: true},
+		{name: "unknown user", user: "mallory", password: "alice-password", wantErr: true},
+		{name: "empty user", user: "", password: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := store.authenticate(tt.user, tt.password)
+
+			// The HTTP proxy authenticates the same users.
+			assert.Equal(t, err, store.Authenticate(tt.user, tt.password))
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrAuthFailed)
+				assert.Nil(t, u)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.user, u.name)
+		})
+	}
+}
+
+func TestUser_Allowed(t *testing.T) {
+	store := newTestUserStore(t)
+
+	tests := []struct {
+		addr *network.Address
+		name string
+		user string
+		want bool
+	}{
+		{name: "allowed name space", user: "alice", addr: network.NewAddress("service1", "example"), want: true},
+		{name: "other service of allowed name space", user: "alice", addr: network.NewAddress("service2", "example"), want: true},
+		{name: "allowed service", user: "alice", addr: network.NewAddress("service1", "other"), want: true},
+		{name: "other service of name space", user: "alice", addr: network.NewAddress("service2", "other"), want: false},
+		{name: "denied name space", user: "alice", addr: network.NewAddress("service1", "private"), want: false},
+		{name: "name space is matched exactly", user: "alice", addr: network.NewAddress("service1", "example.private"), want: false},
+		{name: "all destinations", user: "bob", addr: network.NewAddress("service1", "private"), want: true},
+		{name: "no destinations", user: "carol", addr: network.NewAddress("service1", "example"), want: false},
+		{name: "unknown user", user: "mallory", addr: network.NewAddress("service1", "example"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, store.Allowed(tt.user, tt.addr))
+		})
+	}
+}
+
+func TestService_Authorize(t *testing.T) {
+	store := newTestUserStore(t)
+	addr := network.NewAddress("service1", "private")
+
+	svc := &Service{authRequired: true}
+
+	err := svc.authorize(context.Background(), addr)
+	assert.ErrorIs(t, err, ErrNotAllowed)
+
+	err = svc.authorize(withUser(context.Background(), store.users["alice"]), addr)
+	assert.ErrorIs(t, err, ErrNotAllowed)
+
+	err = svc.authorize(withUser(context.Background(), store.users["bob"]), addr)
+	assert.NoError(t, err)
+
+	// All connections are allowed without authentication.
+	svc = &Service{}
+
+	err = svc.authorize(context.Background(), addr)
+	assert.NoError(t, err)
+}
@@ -2,41 +2,127 @@ package revconnapi
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ksysoev/oneway/api/revconn"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ExchangeService interface {
-	AddConnection(id uint64, conn net.Conn) error
+	AddConnection(nameSpace string, id uint64, conn net.Conn) error
 }
 
 type API struct {
-	exchange ExchangeService
-	listen   string
+	exchange         ExchangeService
+	tls              *network.TLSConfig
+	tokens           map[string]string
+	listen           string
+	token            string
+	maxConcurrency   int
+	maxStreams       int
+	handshakeTimeout time.Duration
 }
 
+// Config is the configuration of the connection API.
+// Token is shared by all reverse proxies, connections authenticated with it are not bound to a name space.
+// Tokens are keyed by name space, connections authenticated with them are accepted
+// only for requests sent to the same name space. The keys are lowercased, as the configuration keys are case-insensitive,
+// so the name spaces that have own tokens must be lowercase. Every token must identify a single name space.
+// MaxConcurrency is the number of handshakes of reverse connections that are performed concurrently, 25 by default,
+// as many accepted connections can wait for the handshake, the connections above that are rejected.
+// HandshakeTimeout is the time that the client has to complete the handshake, 10 seconds by default.
+// MaxSessionStreams is the number of streams that can be open in a single mux session, 1024 by default.
 type Config struct {
-	Listen string
+	TLS               *network.TLSConfig `mapstructure:"tls"`
+	Tokens            map[string]string  `mapstructure:"tokens"`
+	Listen            string
+	Token             string
+	MaxConcurrency    int           `mapstructure:"max_concurrency"`
+	MaxSessionStreams int           `mapstructure:"max_session_streams"`
+	HandshakeTimeout  time.Duration `mapstructure:"handshake_timeout"`
 }
 
-func New(cfg *Config, exchange ExchangeService) *API {
+var ErrDuplicateToken = fmt.Errorf("token is used by more than one name space")
+
+// New creates the connection API, it returns ErrDuplicateToken if the same token is configured
+// for several name spaces or for a name space and as the shared token.
+func New(cfg *Config, exchange ExchangeService) (*API, error) {
+	tokens, err := nameSpaceTokens(cfg.Token, cfg.Tokens)
+	if err != nil {
+		return nil, err
+	}
+
 	return &API{
-		listen:   cfg.Listen,
-		exchange: exchange,
+		listen:           cfg.Listen,
+		token:            cfg.Token,
+		tokens:           tokens,
+		tls:              cfg.TLS,
+		exchange:         exchange,
+		maxConcurrency:   cfg.MaxConcurrency,
+		maxStreams:       cfg.MaxSessionStreams,
+		handshakeTimeout: cfg.HandshakeTimeout,
+	}, nil
+}
+
+// nameSpaceTokens returns the tokens keyed by the lowercased name spaces,
+// the connection authenticated with a token has to be attributed to a single name space.
+func nameSpaceTokens(shared string, tokens map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(tokens))
+	owners := make(map[string]string, len(tokens))
+
+	for nameSpace, token := range tokens {
+		nameSpace = strings.ToLower(nameSpace)
+
+		if _, ok := result[nameSpace]; ok {
+			return nil, fmt.Errorf("name space %q is configured more than once", nameSpace)
+		}
+
+		result[nameSpace] = token
+
+		if token == "" {
+			continue
+		}
+
+		if token == shared {
+			return nil, fmt.Errorf("%w: %s and the shared token", ErrDuplicateToken, nameSpace)
+		}
+
+		if owner, ok := owners[token]; ok {
+			return nil, fmt.Errorf("%w: %s and %s", ErrDuplicateToken, owner, nameSpace)
+		}
+
+		owners[token] = nameSpace
 	}
+
+	return result, nil
 }
 
 func (a *API) Run(ctx context.Context) error {
+	tlsConfig, err := a.tls.ServerConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS config: %w", err)
+	}
+
 	lis, err := net.Listen("tcp", a.listen)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+
 	go func() {
 		<-ctx.Done()
 
@@ -47,7 +133,14 @@ func (a *API) Run(ctx context.Context) error {
 
 	slog.Info("Connection API started", slog.String("address", lis.Addr().String()))
 
-	connAPI := revconn.NewServer(a.ConnectionHandler)
+	connAPI := revconn.NewServer(
+		a.ConnectionHandler,
+		revconn.WithServerToken(a.token),
+		revconn.WithServerTokens(a.tokens),
+		revconn.WithMaxConcurrency(a.maxConcurrency),
+		revconn.WithMaxSessionStreams(a.maxStreams),
+		revconn.WithHandshakeTimeout(a.handshakeTimeout),
+	)
 
 	err = connAPI.Serve(lis)
 	if errors.Is(err, net.ErrClosed) || errors.Is(err, syscall.EPIPE) {
@@ -57,6 +150,24 @@ func (a *API) Run(ctx context.Context) error {
 	return err
 }
 
-func (a *API) ConnectionHandler(id uint64, conn net.Conn) error {
-	return a.exchange.AddConnection(id, conn)
+var tracer = otel.Tracer("github.com/ksysoev/oneway/pkg/svc/revconnapi")
+
+// ConnectionHandler passes the reverse connection to the exchange.
+// The metadata sent by the revproxy is recorded in the span that continues the trace of the revproxy.
+func (a *API) ConnectionHandler(info revconn.ConnInfo, conn net.Conn) error {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier(info.Metadata))
+
+	_, span := tracer.Start(ctx, "ConnAPI.Connect", trace.WithAttributes(
+		attribute.String("namespace", info.Identity),
+		attribute.String("service", info.Metadata[revconn.MetaService]),
+		attribute.String("revproxy.instance", info.Metadata[revconn.MetaInstanceID]),
+		attribute.String("revproxy.destination", info.Metadata[revconn.MetaDestination]),
+	))
+	defer span.End()
+
+	if len(info.Metadata) > 0 {
+		slog.Debug("Reverse connection is accepted", slog.Any("id", info.ID), slog.Any("metadata", info.Metadata))
+	}
+
+	return a.exchange.AddConnection(info.Identity, info.ID, conn)
 }
@@ -0,0 +1,73 @@
+package revconnapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Tokens(t *testing.T) {
+	tests := []struct {
+		tokens  map[string]string
+		want    map[string]string
+		errIs   error
+		name    string
+		shared  string
+		wantErr bool
+	}{
+		{
+			name:   "unique tokens",
+			shared: "shared",
+			tokens: map[string]string{"example": "token1", "other": "token2"},
+			want:   map[string]string{"example": "token1", "other": "token2"},
+		},
+		{
+			name:   "name spaces are lowercased",
+			tokens: map[string]string{"Example": "token1"},
+			want:   map[string]string{"example": "token1"},
+		},
+		{
+			name:   "empty tokens are not duplicates",
+			tokens: map[string]string{"example": "", "other": ""},
+			want:   map[string]string{"example": "", "other": ""},
+		},
+		{
+			name:    "same token for two name spaces",
+			tokens:  map[string]string{"example": "token", "other": "token"},
+			wantErr: true,
+			errIs:   ErrDuplicateToken,
+		},
+		{
+			name:    "name space token is the shared token",
+			shared:  "token",
+			tokens:  map[string]string{"example": "token"},
+			wantErr: true,
+			errIs:   ErrDuplicateToken,
+		},
+		{
+			name:    "name space differs only in case",
+			tokens:  map[string]string{"example": "token1", "EXAMPLE": "token2"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, err := New(&Config{Token: tt.shared, Tokens: tt.tokens}, nil)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+
+				if tt.errIs != nil {
+					assert.ErrorIs(t, err, tt.errIs)
+				}
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, api.tokens)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package revconproxy
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+	maxBackoffShift   = 32
+)
+
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+}
+
+func newBackoff(minDelay, maxDelay time.Duration) *backoff {
+	if minDelay <= 0 {
+		minDelay = defaultMinBackoff
+	}
+
+	if maxDelay < minDelay {
+		maxDelay = max(defaultMaxBackoff, minDelay)
+	}
+
+	return &backoff{
+		min: minDelay,
+		max: maxDelay,
+	}
+}
+
+// next returns the delay before the next reconnect attempt.
+// The delay doubles with every attempt up to the maximum, and only a random part of its second half is used,
+// so revproxies don't reconnect in lockstep after an exchange restart.
+func (b *backoff) next() time.Duration {
+	delay := b.max
+
+	if b.attempt < maxBackoffShift {
+		if d := b.min << b.attempt; d > 0 && d < b.max {
+			delay = d
+		}
+	}
+
+	b.attempt++
+
+	half := delay / 2
+
+	return half + rand.N(half+1)
+}
+
+// reset resets the backoff to the minimum delay.
+func (b *backoff) reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,113 @@
+package revconproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		minDelay time.Duration
+		maxDelay time.Duration
+		wantMin  time.Duration
+		wantMax  time.Duration
+	}{
+		{name: "defaults", wantMin: defaultMinBackoff, wantMax: defaultMaxBackoff},
+		{name: "custom", minDelay: time.Second, maxDelay: time.Minute, wantMin: time.Second, wantMax: time.Minute},
+		{name: "negative minimum", minDelay: -time.Second, maxDelay: time.Minute, wantMin: defaultMinBackoff, wantMax: time.Minute},
+		{name: "maximum below minimum", minDelay: time.Second, maxDelay: time.Millisecond, wantMin: time.Second, wantMax: defaultMaxBackoff},
+		{name: "minimum above default maximum", minDelay: time.Minute, wantMin: time.Minute, wantMax: time.Minute},
+		{name: "equal minimum and maximum", minDelay: time.Second, maxDelay: time.Second, wantMin: time.Second, wantMax: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackoff(tt.minDelay, tt.maxDelay)
+
+			assert.Equal(t, tt.wantMin, b.min)
+			assert.Equal(t, tt.wantMax, b.max)
+		})
+	}
+}
+
+func TestBackoff_Next(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     []time.Duration
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{
+			name:     "doubles up to the maximum",
+			minDelay: 100 * time.Millisecond,
+			maxDelay: time.Second,
+			want: []time.Duration{
+				100 * time.Millisecond,
+				200 * time.Millisecond,
+				400 * time.Millisecond,
+				800 * time.Millisecond,
+				time.Second,
+				time.Second,
+			},
+		},
+		{
+			name:     "maximum is not a power of two of the minimum",
+			minDelay: time.Second,
+			maxDelay: 3 * time.Second,
+			want:     []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second},
+		},
+		{
+			name:     "equal minimum and maximum",
+			minDelay: time.Second,
+			maxDelay: time.Second,
+			want:     []time.Duration{time.Second, time.Second, time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBackoff(tt.minDelay, tt.maxDelay)
+
+			for i, delay := range tt.want {
+				// The jitter keeps the delay in the second half of the nominal delay.
+				got := b.next()
+				assert.GreaterOrEqual(t, got, delay/2, "attempt %d", i)
+				assert.LessOrEqual(t, got, delay, "attempt %d", i)
+			}
+		})
+	}
+}
+
+func TestBackoff_NextOverflow(t *testing.T) {
+	b := newBackoff(time.Second, time.Hour)
+
+	// The shift overflows the duration long before maxBackoffShift attempts, the delay stays at the maximum.
+	for i := range 2 * maxBackoffShift {
+		got := b.next()
+
+		if i >= 12 {
+			assert.GreaterOrEqual(t, got, time.Hour/2, "attempt %d", i)
+		}
+
+		assert.LessOrEqual(t, got, time.Hour, "attempt %d", i)
+	}
+}
+
+func TestBackoff_Reset(t *testing.T) {
+	b := newBackoff(100*time.Millisecond, time.Minute)
+
+	for range 10 {
+		b.next()
+	}
+
+	assert.GreaterOrEqual(t, b.next(), 30*time.Second)
+
+	b.reset()
+
+	got := b.next()
+	assert.GreaterOrEqual(t, got, 50*time.Millisecond)
+	assert.LessOrEqual(t, got, 100*time.Millisecond)
+}
@@ -2,14 +2,80 @@ package revconproxy
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
+	"time"
 
 	"github.com/ksysoev/oneway/api"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"github.com/ksysoev/oneway/pkg/core/revconproxy"
 )
 
-func (s *Proxy) ConnectCommandHandler(ctx context.Context, cmd *api.ConnectCommand) {
-	err := s.rcpServ.CreateConnection(ctx, s.rcpServ.NameSpace(), cmd.ServiceName, cmd.Id)
+const rejectTimeout = 5 * time.Second
+
+var ErrInvalidPort = fmt.Errorf("invalid port")
+
+// ConnectCommandHandler creates the connection requested by the exchange.
+// If the connection can't be established, the connection request is rejected on the exchange with the reason of the failure.
+// Warm connections are never rejected, the failure of the destination is reported over the connection itself.
+func (s *Proxy) ConnectCommandHandler(ctx context.Context, exchangeService api.ExchangeServiceClient, cmd *api.ConnectCommand) {
+	if cmd.Warm {
+		if err := s.rcpServ.CreateWarmConnection(ctx, s.rcpServ.NameSpace(), cmd.ServiceName, cmd.Id); err != nil {
+			slog.Error("failed to create warm connection", slog.Any("error", err))
+		}
+
+		return
+	}
+
+	var err error
+
+	if cmd.Port > math.MaxUint16 {
+		err = fmt.Errorf("%w: %d", ErrInvalidPort, cmd.Port)
+	} else {
+		err = s.rcpServ.CreateConnection(ctx, s.rcpServ.NameSpace(), cmd.ServiceName, cmd.Id, uint16(cmd.Port))
+	}
+
+	if err == nil {
+		return
+	}
+
+	slog.Error("failed to create connection", slog.Any("error", err))
+
+	if errors.Is(err, revconproxy.ErrBridgeFailed) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rejectTimeout)
+	defer cancel()
+
+	_, err = exchangeService.RejectConnection(ctx, &api.RejectRequest{
+		NameSpace: s.rcpServ.NameSpace(),
+		Id:        cmd.Id,
+		Reason:    rejectReason(err),
+		Message:   err.Error(),
+	})
+
 	if err != nil {
-		slog.Error("failed to create connection", slog.Any("error", err))
+		slog.Error("failed to reject connection", slog.Any("error", err))
+	}
+}
+
+// rejectReason returns the reject reason that corresponds to the error of creating the connection.
+func rejectReason(err error) api.RejectReason {
+	switch {
+	case errors.Is(err, revconproxy.ErrServiceNotFound):
+		return api.RejectReason_REJECT_REASON_SERVICE_NOT_FOUND
+	case errors.Is(err, network.ErrConnRefused):
+		return api.RejectReason_REJECT_REASON_CONNECTION_REFUSED
+	case errors.Is(err, network.ErrHostUnreachable):
+		return api.RejectReason_REJECT_REASON_HOST_UNREACHABLE
+	case errors.Is(err, network.ErrNetUnreachable):
+		return api.RejectReason_REJECT_REASON_NETWORK_UNREACHABLE
+	case errors.Is(err, network.ErrConnTimeout):
+		return api.RejectReason_REJECT_REASON_TIMEOUT
+	default:
+		return api.RejectReason_REJECT_REASON_UNSPECIFIED
 	}
 }
@@ -2,63 +2,312 @@ package revconproxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ksysoev/oneway/api"
+	"github.com/ksysoev/oneway/pkg/core/network"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+var meter = otel.GetMeterProvider().Meter("oneway")
+
+var (
+	ErrExchangeNotResponding = fmt.Errorf("exchange is not responding")
+	ErrExchangeDraining      = fmt.Errorf("exchange is draining")
+)
+
+const (
+	defaultHeartbeatInterval = 10 * time.Second
+	defaultStatusInterval    = 30 * time.Second
+	heartbeatTimeoutFactor   = 3
+)
+
 type rcpService interface {
 	NameSpace() string
 	ServiceNames() []string
-	CreateConnection(ctx context.Context, nameSpace string, serviceName string, id uint64) error
+	ActiveBridges() map[string]int64
+	WarmPool() map[string]int
+	CreateConnection(ctx context.Context, nameSpace string, serviceName string, id uint64, port uint16) error
+	CreateWarmConnection(ctx context.Context, nameSpace string, serviceName string, id uint64) error
+	Drain(ctx context.Context) map[string]int64
 }
 
 type Proxy struct {
-	rcpServ rcpService
-	ctrlAPI string
+	startedAt         time.Time
+	rcpServ           rcpService
+	tls               *network.TLSConfig
+	ctrlAPI           string
+	minBackoff        time.Duration
+	maxBackoff        time.Duration
+	heartbeatInterval time.Duration
+	heartbeatTimeout  time.Duration
+	statusInterval    time.Duration
 }
 
-func New(rcpServ rcpService, ctrlAPI string) *Proxy {
+// Config is the configuration of the connection to the exchange control API.
+// MinBackoff and MaxBackoff limit the delay between reconnect attempts when the control stream is lost.
+// HeartbeatInterval is the interval of heartbeats sent to the exchange, HeartbeatTimeout is the time after which
+// the silent exchange is considered dead, by default it's three heartbeat intervals.
+// StatusInterval is the interval of status reports sent to the exchange.
+type Config struct {
+	TLS               *network.TLSConfig `mapstructure:"tls"`
+	Address           string
+	MinBackoff        time.Duration `mapstructure:"min_backoff"`
+	MaxBackoff        time.Duration `mapstructure:"max_backoff"`
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"`
+	HeartbeatTimeout  time.Duration `mapstructure:"heartbeat_timeout"`
+	StatusInterval    time.Duration `mapstructure:"status_interval"`
+}
+
+func New(rcpServ rcpService, cfg *Config) *Proxy {
+	heartbeatInterval := cfg.HeartbeatInterval
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+
+	heartbeatTimeout := cfg.HeartbeatTimeout
+	if heartbeatTimeout <= 0 {
+		heartbeatTimeout = heartbeatTimeoutFactor * heartbeatInterval
+	}
+
+	statusInterval := cfg.StatusInterval
+	if statusInterval <= 0 {
+		statusInterval = defaultStatusInterval
+	}
+
 	return &Proxy{
-		ctrlAPI: ctrlAPI,
-		rcpServ: rcpServ,
+		ctrlAPI:           cfg.Address,
+		tls:               cfg.TLS,
+		minBackoff:        cfg.MinBackoff,
+		maxBackoff:        cfg.MaxBackoff,
+		heartbeatInterval: heartbeatInterval,
+		heartbeatTimeout:  heartbeatTimeout,
+		statusInterval:    statusInterval,
+		rcpServ:           rcpServ,
 	}
 }
 
+// Run keeps the services registered on the exchange and handles connect commands until the context is done.
+// If the exchange is draining, the revproxy reconnects right away, so it's registered on the exchange that replaces it.
+// On shutdown, the active bridges are drained before Run returns.
 func (s *Proxy) Run(ctx context.Context) error {
-	conn, err := grpc.NewClient(s.ctrlAPI, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	tlsConfig, err := s.tls.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load TLS config: %w", err)
+	}
+
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	conn, err := grpc.NewClient(s.ctrlAPI, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		return fmt.Errorf("failed to dial control api: %w", err)
 	}
 
+	defer conn.Close()
+
 	exchangeService := api.NewExchangeServiceClient(conn)
+	s.startedAt = time.Now()
+
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	defer s.rcpServ.Drain(context.Background())
+
+	reconnects, _ := meter.Int64Counter("revproxy_reconnects", metric.WithDescription("Number of reconnects to the exchange control API"))
+	bo := newBackoff(s.minBackoff, s.maxBackoff)
+
+	for {
+		err := s.serve(ctx, exchangeService, &wg, bo.reset)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if errors.Is(err, ErrExchangeDraining) {
+			slog.InfoContext(ctx, "exchange is draining, reconnecting", slog.String("address", s.ctrlAPI))
+			bo.reset()
+
+			continue
+		}
+
+		delay := bo.next()
+
+		reconnects.Add(ctx, 1)
+		slog.WarnContext(ctx, "control stream is lost, reconnecting",
+			slog.Any("error", err),
+			slog.Int("attempt", bo.attempt),
+			slog.Duration("delay", delay),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// serve registers services on the exchange and handles connect commands until the control stream fails.
+// onRegistered is called once the exchange has confirmed the registration.
+// Connect commands are handled with the parent context, so bridges keep running after the control stream is lost.
+// If nothing is received from the exchange within the heartbeat timeout, the control stream is closed
+// with ErrExchangeNotResponding. If the exchange is draining, the control stream is closed with ErrExchangeDraining.
+func (s *Proxy) serve(ctx context.Context, exchangeService api.ExchangeServiceClient, wg *sync.WaitGroup, onRegistered func()) error {
+	streamCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	stream, err := exchangeService.Control(streamCtx)
+	if err != nil {
+		return fmt.Errorf("failed to open control stream: %w", err)
+	}
+
+	warmPool := make(map[string]uint32)
+	for service, size := range s.rcpServ.WarmPool() {
+		warmPool[service] = uint32(size)
+	}
 
-	sub, err := exchangeService.RegisterService(ctx, &api.RegisterRequest{
-		NameSpace:   s.rcpServ.NameSpace(),
-		ServiceName: s.rcpServ.ServiceNames(),
+	err = stream.Send(&api.ControlMessage{
+		Message: &api.ControlMessage_Register{Register: &api.RegisterRequest{
+			NameSpace:   s.rcpServ.NameSpace(),
+			ServiceName: s.rcpServ.ServiceNames(),
+			WarmPool:    warmPool,
+		}},
 	})
 
 	if err != nil {
 		return fmt.Errorf("failed to register service: %w", err)
 	}
 
-	wg := sync.WaitGroup{}
-	defer wg.Wait()
+	md, err := stream.Header()
+	if err != nil {
+		return fmt.Errorf("failed to register service: %w", err)
+	}
+
+	if md != nil {
+		onRegistered()
+
+		connected, _ := meter.Int64Gauge("revproxy_connected", metric.WithDescription("Whether the revproxy is registered on the exchange"))
+		connected.Record(ctx, 1)
+
+		defer connected.Record(ctx, 0)
+
+		slog.InfoContext(ctx, "services are registered on exchange", slog.String("address", s.ctrlAPI))
+	}
+
+	var lastSeen atomic.Int64
+
+	lastSeen.Store(time.Now().UnixNano())
+
+	done := make(chan struct{})
+	defer func() { <-done }()
+
+	go func() {
+		defer close(done)
+
+		if err := s.sendHeartbeats(streamCtx, stream, &lastSeen); err != nil {
+			cancel(err)
+		}
+	}()
 
 	for {
-		cmd, err := sub.Recv()
+		msg, err := stream.Recv()
 		if err != nil {
+			if cause := context.Cause(streamCtx); cause != nil && !errors.Is(cause, context.Canceled) {
+				err = cause
+			}
+
+			cancel(nil)
+
 			return fmt.Errorf("failed to receive command: %w", err)
 		}
 
+		lastSeen.Store(time.Now().UnixNano())
+
+		if msg.GetDrain() != nil {
+			cancel(nil)
+			return ErrExchangeDraining
+		}
+
+		cmd := msg.GetConnect()
+		if cmd == nil {
+			continue
+		}
+
 		wg.Add(1)
 
 		go func() {
 			defer wg.Done()
-			s.ConnectCommandHandler(ctx, cmd)
+			s.ConnectCommandHandler(ctx, exchangeService, cmd)
 		}()
 	}
 }
+
+// sendHeartbeats sends heartbeats and status reports to the exchange until the context is done.
+// The first status report is sent right after the registration.
+// It returns ErrExchangeNotResponding if nothing is received from the exchange within the heartbeat timeout.
+func (s *Proxy) sendHeartbeats(ctx context.Context, stream grpc.BidiStreamingClient[api.ControlMessage, api.ExchangeMessage], lastSeen *atomic.Int64) error {
+	heartbeat := time.NewTicker(s.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	status := time.NewTicker(s.statusInterval)
+	defer status.Stop()
+
+	if err := stream.Send(s.statusReport()); err != nil {
+		return fmt.Errorf("failed to send status: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if time.Since(time.Unix(0, lastSeen.Load())) > s.heartbeatTimeout {
+				return ErrExchangeNotResponding
+			}
+
+			err := stream.Send(&api.ControlMessage{
+				Message: &api.ControlMessage_Heartbeat{Heartbeat: &api.Heartbeat{Timestamp: time.Now().UnixNano()}},
+			})
+
+			if err != nil {
+				return fmt.Errorf("failed to send heartbeat: %w", err)
+			}
+		case <-status.C:
+			if err := stream.Send(s.statusReport()); err != nil {
+				return fmt.Errorf("failed to send status: %w", err)
+			}
+		}
+	}
+}
+
+// statusReport builds the status report of the revproxy.
+func (s *Proxy) statusReport() *api.ControlMessage {
+	return &api.ControlMessage{
+		Message: &api.ControlMessage_Status{Status: &api.StatusReport{
+			Version:       buildVersion(),
+			UptimeSeconds: int64(time.Since(s.startedAt).Seconds()),
+			ActiveBridges: s.rcpServ.ActiveBridges(),
+		}},
+	}
+}
+
+// buildVersion returns the version of the main module the binary is built from.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	return info.Main.Version
+}